@@ -0,0 +1,235 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+type AlertConfig struct {
+	// Repeated mismatches of the same item within the dedup window are only alerted once
+	DedupWindow time.Duration
+	// Mismatches at the same height confirmed within the group window are sent in a single alert
+	GroupWindow time.Duration
+	// Maximum alerts sent per minute, 0 for no limit. Alerts over the limit are held back and sent in a single
+	// summary alert once the rate window reopens
+	RateLimit int
+}
+
+// Group is an alert of the confirmed mismatches at a block height, or the summary alert of the mismatches held back
+// by the rate limit, from the height up to the to height
+type Group struct {
+	Height     uint64           `json:"height"`
+	ToHeight   uint64           `json:"toHeight,omitempty"`
+	Summary    bool             `json:"summary"`
+	Mismatches []store.Mismatch `json:"mismatches"`
+}
+
+// Sink delivers the alerts, e.g. to a webhook
+type Sink interface {
+	Name() string
+	Send(ctx context.Context, group Group) error
+}
+
+// Alerter deduplicates, groups per block and rate limits the confirmed mismatches before sending them to the sinks
+type Alerter struct {
+	config AlertConfig
+	sinks  []Sink
+	logger *log.Logger
+
+	mismatchChan chan store.Mismatch
+}
+
+func NewAlerter(config AlertConfig, sinks []Sink, logger *log.Logger) *Alerter {
+	return &Alerter{
+		config:       config,
+		sinks:        sinks,
+		logger:       logger,
+		mismatchChan: make(chan store.Mismatch, DefaultChannelSize),
+	}
+}
+
+// Notify queues the confirmed mismatch for alerting without blocking the comparator
+func (alerter *Alerter) Notify(mismatch store.Mismatch) {
+	select {
+	case alerter.mismatchChan <- mismatch:
+	default:
+		alerter.logger.Printf("alert queue full, dropping %s mismatch alert at height %d\n", mismatch.Comparator, mismatch.Height)
+	}
+}
+
+type pendingGroup struct {
+	group     Group
+	createdAt time.Time
+}
+
+// Run processes the queued mismatches until the context is cancelled
+func (alerter *Alerter) Run(ctx context.Context) {
+	state := newAlertState(alerter.config, alerter.logger)
+	ticker := time.NewTicker(time.Duration(DefaultFlushIntervalMS) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case mismatch := <-alerter.mismatchChan:
+			state.add(mismatch, time.Now())
+		case now := <-ticker.C:
+			for _, group := range state.flush(now) {
+				alerter.send(ctx, group)
+			}
+		}
+	}
+}
+
+// alertState deduplicates, groups and rate limits the confirmed mismatches of the alerter
+type alertState struct {
+	config AlertConfig
+	logger *log.Logger
+
+	// Last alert time of each deduplicated item
+	alerted map[string]time.Time
+	pending map[uint64]*pendingGroup
+	// Send times within the last minute, for the rate limit
+	sendTimes []time.Time
+	// Groups held back by the rate limit, sent in a summary once the rate window reopens
+	held []Group
+}
+
+func newAlertState(config AlertConfig, logger *log.Logger) *alertState {
+	return &alertState{
+		config:    config,
+		logger:    logger,
+		alerted:   make(map[string]time.Time),
+		pending:   make(map[uint64]*pendingGroup),
+		sendTimes: make([]time.Time, 0),
+		held:      make([]Group, 0),
+	}
+}
+
+// add adds the mismatch to the pending group of its height, and returns false if the item was already alerted
+// within the dedup window
+func (state *alertState) add(mismatch store.Mismatch, now time.Time) bool {
+	key := dedupKey(mismatch)
+	if last, ok := state.alerted[key]; ok && now.Sub(last) < state.config.DedupWindow {
+		return false
+	}
+	state.alerted[key] = now
+
+	pendingGroup, ok := state.pending[mismatch.Height]
+	if !ok {
+		pendingGroup = newPendingGroup(mismatch.Height, now)
+		state.pending[mismatch.Height] = pendingGroup
+	}
+	pendingGroup.group.Mismatches = append(pendingGroup.group.Mismatches, mismatch)
+	return true
+}
+
+// flush returns the alerts to send at the time, the summary of the held groups once the rate window reopens,
+// followed by the groups whose group window elapsed. Groups over the rate limit are held for the next summary
+func (state *alertState) flush(now time.Time) []Group {
+	for key, last := range state.alerted {
+		if now.Sub(last) >= state.config.DedupWindow {
+			delete(state.alerted, key)
+		}
+	}
+	state.sendTimes = pruneSendTimes(state.sendTimes, now)
+
+	groups := []Group{}
+	if len(state.held) > 0 && !state.rateLimited() {
+		state.sendTimes = append(state.sendTimes, now)
+		groups = append(groups, summarize(state.held))
+		state.held = make([]Group, 0)
+	}
+	for _, height := range sortedHeights(state.pending) {
+		pendingGroup := state.pending[height]
+		if now.Sub(pendingGroup.createdAt) < state.config.GroupWindow {
+			continue
+		}
+		delete(state.pending, height)
+
+		if state.rateLimited() {
+			state.logger.Printf("alert rate limit reached, holding back %d mismatch alerts at height %d\n", len(pendingGroup.group.Mismatches), height)
+			state.held = append(state.held, pendingGroup.group)
+			continue
+		}
+		state.sendTimes = append(state.sendTimes, now)
+		groups = append(groups, pendingGroup.group)
+	}
+	return groups
+}
+
+func (state *alertState) rateLimited() bool {
+	return state.config.RateLimit > 0 && len(state.sendTimes) >= state.config.RateLimit
+}
+
+func (alerter *Alerter) send(ctx context.Context, group Group) {
+	for _, sink := range alerter.sinks {
+		sendCtx, cancel := context.WithTimeout(ctx, time.Duration(DefaultSendTimeoutMS)*time.Millisecond)
+		if err := sink.Send(sendCtx, group); err != nil {
+			alerter.logger.Printf("error sending mismatch alert at height %d to %s sink: %v\n", group.Height, sink.Name(), err)
+		}
+		cancel()
+	}
+}
+
+func newPendingGroup(height uint64, now time.Time) *pendingGroup {
+	return &pendingGroup{
+		group: Group{
+			Height:     height,
+			Mismatches: []store.Mismatch{},
+		},
+		createdAt: now,
+	}
+}
+
+// summarize merges the groups held back by the rate limit into a single summary alert
+func summarize(groups []Group) Group {
+	summary := Group{
+		Height:     groups[0].Height,
+		ToHeight:   groups[0].Height,
+		Summary:    true,
+		Mismatches: []store.Mismatch{},
+	}
+	for _, group := range groups {
+		if group.Height < summary.Height {
+			summary.Height = group.Height
+		}
+		if group.Height > summary.ToHeight {
+			summary.ToHeight = group.Height
+		}
+		summary.Mismatches = append(summary.Mismatches, group.Mismatches...)
+	}
+	return summary
+}
+
+// dedupKey identifies the compared item of the mismatch. Items without an address, token or key, e.g. blocks, are
+// identified by their height
+func dedupKey(mismatch store.Mismatch) string {
+	if mismatch.Address == "" && mismatch.Token == "" && mismatch.Key == "" {
+		return fmt.Sprintf("%s|%s|%d", mismatch.RealtimeUrl, mismatch.Comparator, mismatch.Height)
+	}
+	return fmt.Sprintf("%s|%s|%s|%s|%s", mismatch.RealtimeUrl, mismatch.Comparator, mismatch.Address, mismatch.Token, mismatch.Key)
+}
+
+func pruneSendTimes(sendTimes []time.Time, now time.Time) []time.Time {
+	for len(sendTimes) > 0 && now.Sub(sendTimes[0]) >= time.Minute {
+		sendTimes = sendTimes[1:]
+	}
+	return sendTimes
+}
+
+func sortedHeights(pending map[uint64]*pendingGroup) []uint64 {
+	heights := make([]uint64, 0, len(pending))
+	for height := range pending {
+		heights = append(heights, height)
+	}
+	sort.Slice(heights, func(i, j int) bool {
+		return heights[i] < heights[j]
+	})
+	return heights
+}
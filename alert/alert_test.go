@@ -0,0 +1,170 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+func newTestState(config AlertConfig) *alertState {
+	return newAlertState(config, log.New(io.Discard, "", 0))
+}
+
+func TestAlertStateDedup(t *testing.T) {
+	start := time.Unix(0, 0)
+	state := newTestState(AlertConfig{DedupWindow: time.Minute})
+	mismatch := store.Mismatch{Comparator: "balance", Address: "0x01", Height: 10}
+
+	tests := []struct {
+		name     string
+		mismatch store.Mismatch
+		at       time.Duration
+		added    bool
+	}{
+		{"first alert", mismatch, 0, true},
+		{"repeated within window", mismatch, 30 * time.Second, false},
+		{"same item at a new height within window", store.Mismatch{Comparator: "balance", Address: "0x01", Height: 11}, 40 * time.Second, false},
+		{"other item", store.Mismatch{Comparator: "balance", Address: "0x02", Height: 10}, 40 * time.Second, true},
+		{"repeated after window", mismatch, 2 * time.Minute, true},
+	}
+	for _, test := range tests {
+		if added := state.add(test.mismatch, start.Add(test.at)); added != test.added {
+			t.Errorf("%s: got added %v, want %v", test.name, added, test.added)
+		}
+	}
+}
+
+func TestAlertStateGrouping(t *testing.T) {
+	start := time.Unix(0, 0)
+	state := newTestState(AlertConfig{GroupWindow: 5 * time.Second})
+	state.add(store.Mismatch{Comparator: "balance", Address: "0x01", Height: 10}, start)
+	state.add(store.Mismatch{Comparator: "nonce", Address: "0x01", Height: 10}, start)
+	state.add(store.Mismatch{Comparator: "block", Height: 11}, start.Add(time.Second))
+
+	if groups := state.flush(start.Add(4 * time.Second)); len(groups) != 0 {
+		t.Fatalf("got %d groups within the group window, want 0", len(groups))
+	}
+	groups := state.flush(start.Add(6 * time.Second))
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2", len(groups))
+	}
+	tests := []struct {
+		height     uint64
+		mismatches int
+	}{
+		{10, 2},
+		{11, 1},
+	}
+	for i, test := range tests {
+		if groups[i].Height != test.height || len(groups[i].Mismatches) != test.mismatches {
+			t.Errorf("group %d: got height %d with %d mismatches, want height %d with %d mismatches", i, groups[i].Height, len(groups[i].Mismatches), test.height, test.mismatches)
+		}
+	}
+}
+
+func TestAlertStateRateLimit(t *testing.T) {
+	start := time.Unix(0, 0)
+	state := newTestState(AlertConfig{RateLimit: 1})
+	for height := uint64(10); height <= 12; height++ {
+		state.add(store.Mismatch{Comparator: "block", Height: height}, start)
+	}
+
+	groups := state.flush(start)
+	if len(groups) != 1 || groups[0].Height != 10 || groups[0].Summary {
+		t.Fatalf("got %+v, want the single alert at height 10", groups)
+	}
+	if groups := state.flush(start.Add(30 * time.Second)); len(groups) != 0 {
+		t.Fatalf("got %d groups within the rate window, want 0", len(groups))
+	}
+
+	// The held groups are flushed in a summary once the rate window reopens
+	groups = state.flush(start.Add(time.Minute))
+	if len(groups) != 1 {
+		t.Fatalf("got %d groups after the rate window, want 1", len(groups))
+	}
+	summary := groups[0]
+	if !summary.Summary || summary.Height != 11 || summary.ToHeight != 12 || len(summary.Mismatches) != 2 {
+		t.Errorf("got summary %+v, want the summary of heights 11 to 12 with 2 mismatches", summary)
+	}
+	if groups := state.flush(start.Add(2 * time.Minute)); len(groups) != 0 {
+		t.Errorf("got %d groups after the summary, want 0", len(groups))
+	}
+}
+
+func TestWebhookSinkPayloads(t *testing.T) {
+	group := Group{
+		Height: 10,
+		Mismatches: []store.Mismatch{
+			{Comparator: "balance", Address: "0x01", Height: 10, Eth: "1", Realtime: "2", RealtimeUrl: "http://realtime"},
+		},
+	}
+	summary := Group{
+		Height:     10,
+		ToHeight:   12,
+		Summary:    true,
+		Mismatches: group.Mismatches,
+	}
+
+	tests := []struct {
+		name     string
+		chat     bool
+		group    Group
+		contains []string
+	}{
+		{"json", false, group, []string{`"height":10`, `"comparator":"balance"`, `"address":"0x01"`, `"summary":false`}},
+		{"json summary", false, summary, []string{`"height":10`, `"toHeight":12`, `"summary":true`}},
+		{"chat", true, group, []string{"1 confirmed mismatches at height 10", "balance mismatch on http://realtime", "address 0x01", "eth: 1, realtime: 2"}},
+		{"chat summary", true, summary, []string{"mismatch summary", "heights 10 to 12", "at height 10"}},
+	}
+	for _, test := range tests {
+		var body []byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Content-Type") != "application/json" {
+				t.Errorf("%s: got content type %q", test.name, r.Header.Get("Content-Type"))
+			}
+			body, _ = io.ReadAll(r.Body)
+		}))
+		sink := NewWebhookSink(server.URL)
+		if test.chat {
+			sink = NewChatWebhookSink(server.URL)
+		}
+		err := sink.Send(context.Background(), test.group)
+		server.Close()
+		if err != nil {
+			t.Fatalf("%s: send: %v", test.name, err)
+		}
+
+		payload := string(body)
+		if test.chat {
+			var message map[string]string
+			if err := json.Unmarshal(body, &message); err != nil {
+				t.Fatalf("%s: invalid chat payload %s: %v", test.name, body, err)
+			}
+			payload = message["text"]
+		}
+		for _, expected := range test.contains {
+			if !strings.Contains(payload, expected) {
+				t.Errorf("%s: payload %q does not contain %q", test.name, payload, expected)
+			}
+		}
+	}
+}
+
+func TestWebhookSinkErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	if err := NewWebhookSink(server.URL).Send(context.Background(), Group{Height: 10}); err == nil {
+		t.Error("got nil error for the failed webhook request")
+	}
+}
@@ -0,0 +1,7 @@
+package alert
+
+const (
+	DefaultChannelSize     = 1000
+	DefaultFlushIntervalMS = 1000
+	DefaultSendTimeoutMS   = 10000
+)
@@ -0,0 +1,101 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// WebhookSink posts the alerts to a webhook url, either as the generic json alert group, or formatted as a chat
+// message for the slack compatible incoming webhooks
+type WebhookSink struct {
+	url        string
+	chat       bool
+	httpClient *http.Client
+}
+
+// NewWebhookSink creates the generic json webhook sink
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{
+		url:        url,
+		httpClient: &http.Client{},
+	}
+}
+
+// NewChatWebhookSink creates the chat webhook sink, posting the alerts as {"text": ...} messages
+func NewChatWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{
+		url:        url,
+		chat:       true,
+		httpClient: &http.Client{},
+	}
+}
+
+func (sink *WebhookSink) Name() string {
+	if sink.chat {
+		return "chat webhook"
+	}
+	return "webhook"
+}
+
+func (sink *WebhookSink) Send(ctx context.Context, group Group) error {
+	var payload interface{} = group
+	if sink.chat {
+		payload = map[string]string{"text": FormatChatMessage(group)}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, sink.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := sink.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook request failed with status %s", response.Status)
+	}
+	return nil
+}
+
+// FormatChatMessage formats the alert group as a human readable chat message
+func FormatChatMessage(group Group) string {
+	var builder strings.Builder
+	if group.Summary {
+		fmt.Fprintf(&builder, "*Realtime state mismatch summary*: %d confirmed mismatches at heights %d to %d were held back by the alert rate limit\n", len(group.Mismatches), group.Height, group.ToHeight)
+	} else {
+		fmt.Fprintf(&builder, "*Realtime state mismatch*: %d confirmed mismatches at height %d\n", len(group.Mismatches), group.Height)
+	}
+	for _, mismatch := range group.Mismatches {
+		fmt.Fprintf(&builder, "• %s mismatch on %s", mismatch.Comparator, mismatch.RealtimeUrl)
+		if group.Summary {
+			fmt.Fprintf(&builder, " at height %d", mismatch.Height)
+		}
+		if mismatch.Address != "" {
+			fmt.Fprintf(&builder, ", address %s", mismatch.Address)
+		}
+		if mismatch.Token != "" {
+			fmt.Fprintf(&builder, ", token %s", mismatch.Token)
+		}
+		if mismatch.Key != "" {
+			fmt.Fprintf(&builder, ", %s", mismatch.Key)
+		}
+		if mismatch.Eth != "" || mismatch.Realtime != "" {
+			fmt.Fprintf(&builder, ", eth: %s, realtime: %s", mismatch.Eth, mismatch.Realtime)
+		}
+		if mismatch.Diffs != "" {
+			fmt.Fprintf(&builder, ", diffs: %s", mismatch.Diffs)
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
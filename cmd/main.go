@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/sieniven/realtime-compare-tool/compare"
+	"github.com/sieniven/realtime-compare-tool/metrics"
 	"github.com/urfave/cli/v2"
 	"gopkg.in/yaml.v2"
 )
@@ -17,6 +18,7 @@ func main() {
 	app.Name = "realtime-comparator"
 	app.Action = run
 	app.Flags = compare.DefaultFlags
+	app.Commands = []*cli.Command{mismatchesCommand}
 	if err := app.Run(os.Args); err != nil {
 		_, printErr := fmt.Fprintln(os.Stderr, err)
 		if printErr != nil {
@@ -37,6 +39,21 @@ func run(ctx *cli.Context) error {
 	}
 
 	compareCfg := compare.NewCompareConfig(ctx)
+	if compareCfg.MetricsAddr != "" {
+		metrics.StartServer(ctx.Context, compareCfg.MetricsAddr, logger)
+	}
+	if len(compareCfg.Rpc.RealtimeUrls) > 1 {
+		// Fan out the comparison across the fleet of realtime nodes
+		fleet, err := compare.NewFleetService(compareCfg, logger)
+		if err != nil {
+			logger.Printf("failed creating fleet service, err: %v\n", err)
+			return err
+		}
+
+		fleet.Start(ctx.Context)
+		return nil
+	}
+
 	service, err := compare.NewCompareService(compareCfg, logger)
 	if err != nil {
 		logger.Printf("failed creating compare service, err: %v\n", err)
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/sieniven/realtime-compare-tool/store"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	mismatchStoreFlag = cli.StringFlag{
+		Name:     "store",
+		Usage:    "Path of the mismatch store database file",
+		Required: true,
+	}
+	mismatchComparatorFlag = cli.StringFlag{
+		Name:  "comparator",
+		Usage: "Only list the mismatches of the comparator, e.g. balance, nonce, storage",
+	}
+	mismatchAddressFlag = cli.StringFlag{
+		Name:  "address",
+		Usage: "Only list the mismatches of the address",
+	}
+	mismatchTokenFlag = cli.StringFlag{
+		Name:  "token",
+		Usage: "Only list the mismatches of the token address",
+	}
+	mismatchFromHeightFlag = cli.Uint64Flag{
+		Name:  "from-height",
+		Usage: "Only list the mismatches at or above the height",
+	}
+	mismatchToHeightFlag = cli.Uint64Flag{
+		Name:  "to-height",
+		Usage: "Only list the mismatches at or below the height",
+	}
+	mismatchSinceFlag = cli.DurationFlag{
+		Name:  "since",
+		Usage: "Only list the mismatches confirmed within the duration, e.g. 24h",
+	}
+	mismatchLimitFlag = cli.IntFlag{
+		Name:  "limit",
+		Usage: "Maximum number of mismatches to list, 0 for no limit",
+		Value: 100,
+	}
+	mismatchFormatFlag = cli.StringFlag{
+		Name:  "format",
+		Usage: "Output format, one of table, json or csv",
+		Value: "table",
+	}
+	mismatchOutputFlag = cli.StringFlag{
+		Name:  "output",
+		Usage: "File to export the mismatches to, defaults to stdout",
+	}
+)
+
+var mismatchesCommand = &cli.Command{
+	Name:   "mismatches",
+	Usage:  "List, filter and export the confirmed mismatches recorded by the comparator",
+	Action: listMismatches,
+	Flags: []cli.Flag{
+		&mismatchStoreFlag,
+		&mismatchComparatorFlag,
+		&mismatchAddressFlag,
+		&mismatchTokenFlag,
+		&mismatchFromHeightFlag,
+		&mismatchToHeightFlag,
+		&mismatchSinceFlag,
+		&mismatchLimitFlag,
+		&mismatchFormatFlag,
+		&mismatchOutputFlag,
+	},
+}
+
+func listMismatches(ctx *cli.Context) error {
+	filter := store.Filter{
+		Comparator: ctx.String(mismatchComparatorFlag.Name),
+		FromHeight: ctx.Uint64(mismatchFromHeightFlag.Name),
+		ToHeight:   ctx.Uint64(mismatchToHeightFlag.Name),
+		Limit:      ctx.Int(mismatchLimitFlag.Name),
+	}
+	// Addresses are stored checksummed
+	if address := ctx.String(mismatchAddressFlag.Name); address != "" {
+		filter.Address = common.HexToAddress(address).Hex()
+	}
+	if token := ctx.String(mismatchTokenFlag.Name); token != "" {
+		filter.Token = common.HexToAddress(token).Hex()
+	}
+	if since := ctx.Duration(mismatchSinceFlag.Name); since > 0 {
+		filter.Since = time.Now().Add(-since)
+	}
+
+	mismatchStore, err := store.OpenMismatchStore(ctx.String(mismatchStoreFlag.Name))
+	if err != nil {
+		return err
+	}
+	mismatches, err := mismatchStore.List(filter)
+	if err != nil {
+		return err
+	}
+
+	var writer io.Writer = os.Stdout
+	if output := ctx.String(mismatchOutputFlag.Name); output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writer = file
+	}
+
+	switch format := ctx.String(mismatchFormatFlag.Name); format {
+	case "table":
+		return writeMismatchesTable(writer, mismatches)
+	case "json":
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(mismatches)
+	case "csv":
+		return writeMismatchesCSV(writer, mismatches)
+	default:
+		return fmt.Errorf("unknown mismatch output format %s", format)
+	}
+}
+
+func writeMismatchesTable(writer io.Writer, mismatches []store.Mismatch) error {
+	table := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "ID\tTIME\tCOMPARATOR\tHEIGHT\tADDRESS\tTOKEN\tKEY\tETH\tREALTIME\tDIFFS\tATTEMPTS\tREALTIME URL")
+	for _, mismatch := range mismatches {
+		fmt.Fprintf(table, "%d\t%s\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+			mismatch.ID, mismatch.Timestamp.Format(time.RFC3339), mismatch.Comparator, mismatch.Height, mismatch.Address,
+			mismatch.Token, mismatch.Key, mismatch.Eth, mismatch.Realtime, mismatch.Diffs, mismatch.Attempts, mismatch.RealtimeUrl)
+	}
+	return table.Flush()
+}
+
+func writeMismatchesCSV(writer io.Writer, mismatches []store.Mismatch) error {
+	csvWriter := csv.NewWriter(writer)
+	header := []string{"id", "timestamp", "comparator", "height", "address", "token", "key", "eth", "realtime", "diffs", "attempts", "realtime_url", "reference_url"}
+	if err := csvWriter.Write(header); err != nil {
+		return err
+	}
+	for _, mismatch := range mismatches {
+		record := []string{
+			strconv.FormatUint(mismatch.ID, 10),
+			mismatch.Timestamp.Format(time.RFC3339),
+			mismatch.Comparator,
+			strconv.FormatUint(mismatch.Height, 10),
+			mismatch.Address,
+			mismatch.Token,
+			mismatch.Key,
+			mismatch.Eth,
+			mismatch.Realtime,
+			mismatch.Diffs,
+			strconv.Itoa(mismatch.Attempts),
+			mismatch.RealtimeUrl,
+			mismatch.ReferenceUrl,
+		}
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
@@ -0,0 +1,402 @@
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/sieniven/realtime-compare-tool/kafka"
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+// NodeStatus is the running state of the compare service of a realtime node
+type NodeStatus struct {
+	RealtimeUrl        string        `json:"realtimeUrl"`
+	ReferenceUrl       string        `json:"referenceUrl"`
+	InitFlag           bool          `json:"initFlag"`
+	SyncFlag           bool          `json:"syncFlag"`
+	Paused             bool          `json:"paused"`
+	NodeHeight         int64         `json:"nodeHeight"`
+	RealtimeAvailable  bool          `json:"realtimeAvailable"`
+	ReferenceAvailable bool          `json:"referenceAvailable"`
+	LatestHeights      *HeightSample `json:"latestHeights,omitempty"`
+}
+
+// PendingEntry is an entry pending comparison in a compare cache, with its consecutive mismatch count
+type PendingEntry struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+}
+
+// CompareRequest is an ad-hoc comparison of the native balance, nonce and code of the address, or of the token
+// balance of the address if the token address is set
+type CompareRequest struct {
+	Address common.Address  `json:"address"`
+	Token   *common.Address `json:"token,omitempty"`
+}
+
+type SkipAddressRequest struct {
+	Address common.Address `json:"address"`
+}
+
+// AdminServer serves the status and admin API of the compare services of the realtime nodes. Requests apply to all
+// nodes, unless a single node is selected with the node query parameter set to its realtime url
+type AdminServer struct {
+	nodes  []*CompareService
+	logger *log.Logger
+}
+
+func NewAdminServer(nodes []*CompareService, logger *log.Logger) *AdminServer {
+	return &AdminServer{
+		nodes:  nodes,
+		logger: logger,
+	}
+}
+
+// Start serves the admin API on the address until the context is cancelled
+func (server *AdminServer) Start(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", server.handleStatus)
+	mux.HandleFunc("GET /caches", server.handleCaches)
+	mux.HandleFunc("GET /mismatches", server.handleMismatches)
+	mux.HandleFunc("POST /compare", server.handleCompare)
+	mux.HandleFunc("GET /skip-addresses", server.handleGetSkipAddresses)
+	mux.HandleFunc("POST /skip-addresses", server.handleAddSkipAddress)
+	mux.HandleFunc("DELETE /skip-addresses", server.handleRemoveSkipAddress)
+	mux.HandleFunc("POST /pause", server.handlePause)
+	mux.HandleFunc("POST /resume", server.handleResume)
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: DefaultAdminReadHeaderTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+		httpServer.Close()
+	}()
+	go func() {
+		server.logger.Printf("serving admin api on %s\n", addr)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			server.logger.Printf("error serving admin api: %v\n", err)
+		}
+	}()
+}
+
+// selectNodes returns the nodes selected by the node query parameter
+func (server *AdminServer) selectNodes(r *http.Request) ([]*CompareService, error) {
+	url := r.URL.Query().Get("node")
+	if url == "" {
+		return server.nodes, nil
+	}
+	for _, node := range server.nodes {
+		if node.RpcClient.RealtimeUrl() == url {
+			return []*CompareService{node}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown node %s", url)
+}
+
+func (server *AdminServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	nodes, err := server.selectNodes(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	statuses := make([]NodeStatus, 0, len(nodes))
+	for _, node := range nodes {
+		statuses = append(statuses, node.status())
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (server *AdminServer) handleCaches(w http.ResponseWriter, r *http.Request) {
+	nodes, err := server.selectNodes(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	caches := make(map[string]map[string][]PendingEntry)
+	for _, node := range nodes {
+		caches[node.RpcClient.RealtimeUrl()] = node.pendingEntries()
+	}
+	writeJSON(w, http.StatusOK, caches)
+}
+
+func (server *AdminServer) handleMismatches(w http.ResponseWriter, r *http.Request) {
+	// The mismatch store is shared by all nodes
+	mismatchStore := server.nodes[0].mismatchStore
+	if mismatchStore == nil {
+		writeError(w, http.StatusNotFound, errors.New("mismatch store is disabled"))
+		return
+	}
+	query := r.URL.Query()
+	filter := store.Filter{
+		Comparator: query.Get("comparator"),
+		Limit:      DefaultAdminMismatchLimit,
+	}
+	if address := query.Get("address"); address != "" {
+		filter.Address = common.HexToAddress(address).Hex()
+	}
+	if limit := query.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		filter.Limit = value
+	}
+	mismatches, err := mismatchStore.List(filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, mismatches)
+}
+
+func (server *AdminServer) handleCompare(w http.ResponseWriter, r *http.Request) {
+	nodes, err := server.selectNodes(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	var request CompareRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	for _, node := range nodes {
+		if !node.InitFlag.Load() {
+			writeError(w, http.StatusServiceUnavailable, fmt.Errorf("node %s is not initialized", node.RpcClient.RealtimeUrl()))
+			return
+		}
+	}
+
+	// Enqueue through the node channels, as if the address was received from kafka
+	for _, node := range nodes {
+		var ok bool
+		if request.Token != nil {
+			ok = sendToNode(r.Context(), node.TokenHolderChan, kafka.TokenHolderData{TokenAddress: *request.Token, Address: request.Address})
+		} else {
+			ok = sendToNode(r.Context(), node.AddrBalanceChan, request.Address)
+		}
+		if !ok {
+			writeError(w, http.StatusServiceUnavailable, r.Context().Err())
+			return
+		}
+	}
+	writeJSON(w, http.StatusAccepted, request)
+}
+
+func (server *AdminServer) handleGetSkipAddresses(w http.ResponseWriter, r *http.Request) {
+	nodes, err := server.selectNodes(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	skipAddresses := make(map[string][]common.Address)
+	for _, node := range nodes {
+		skipAddresses[node.RpcClient.RealtimeUrl()] = node.GetSkipAddresses()
+	}
+	writeJSON(w, http.StatusOK, skipAddresses)
+}
+
+func (server *AdminServer) handleAddSkipAddress(w http.ResponseWriter, r *http.Request) {
+	nodes, err := server.selectNodes(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	var request SkipAddressRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	for _, node := range nodes {
+		node.AddSkipAddress(request.Address)
+	}
+	server.logger.Printf("admin api: skipping address %s\n", request.Address)
+	writeJSON(w, http.StatusOK, request)
+}
+
+func (server *AdminServer) handleRemoveSkipAddress(w http.ResponseWriter, r *http.Request) {
+	nodes, err := server.selectNodes(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	var request SkipAddressRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	removed := false
+	for _, node := range nodes {
+		if node.RemoveSkipAddress(request.Address) {
+			removed = true
+		}
+	}
+	if !removed {
+		writeError(w, http.StatusNotFound, fmt.Errorf("address %s is not skipped", request.Address))
+		return
+	}
+	server.logger.Printf("admin api: resuming comparisons of address %s\n", request.Address)
+	writeJSON(w, http.StatusOK, request)
+}
+
+func (server *AdminServer) handlePause(w http.ResponseWriter, r *http.Request) {
+	server.setPaused(w, r, true)
+}
+
+func (server *AdminServer) handleResume(w http.ResponseWriter, r *http.Request) {
+	server.setPaused(w, r, false)
+}
+
+func (server *AdminServer) setPaused(w http.ResponseWriter, r *http.Request, paused bool) {
+	nodes, err := server.selectNodes(r)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	statuses := make([]NodeStatus, 0, len(nodes))
+	for _, node := range nodes {
+		node.PauseFlag.Store(paused)
+		if paused {
+			node.Logger.Println("admin api: comparators paused")
+		} else {
+			node.Logger.Println("admin api: comparators resumed")
+		}
+		statuses = append(statuses, node.status())
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func (service *CompareService) status() NodeStatus {
+	status := NodeStatus{
+		RealtimeUrl:        service.RpcClient.RealtimeUrl(),
+		ReferenceUrl:       service.RpcClient.ReferenceUrl(),
+		InitFlag:           service.InitFlag.Load(),
+		SyncFlag:           service.SyncFlag.Load(),
+		Paused:             service.PauseFlag.Load(),
+		NodeHeight:         service.NodeHeight.Load(),
+		RealtimeAvailable:  service.RpcClient.IsRealtimeAvailable(),
+		ReferenceAvailable: service.RpcClient.IsReferenceAvailable(),
+	}
+	if sample, ok := service.lagHistory.Latest(); ok {
+		status.LatestHeights = &sample
+	}
+	return status
+}
+
+// pendingEntries returns the entries pending comparison in each compare cache, with their mismatch counts
+func (service *CompareService) pendingEntries() map[string][]PendingEntry {
+	entries := make(map[string][]PendingEntry)
+	entries["balance"] = addressEntries(service.balanceCache.GetAddresses(), service.balanceCache.GetCount)
+	entries["nonce"] = addressEntries(service.nonceCache.GetKeys(), service.nonceCache.GetCount)
+	entries["code"] = addressEntries(service.codeCache.GetKeys(), service.codeCache.GetCount)
+	entries["token"] = addressEntries(service.tokenCache.GetKeys(), service.tokenCache.GetCount)
+	entries["receipt"] = txHashEntries(service.receiptCache.GetKeys(), service.receiptCache.GetCount)
+	entries["innerTx"] = txHashEntries(service.innerTxCache.GetKeys(), service.innerTxCache.GetCount)
+	entries["tx"] = txHashEntries(service.txCache.GetKeys(), service.txCache.GetCount)
+	entries["block"] = heightEntries(service.blockCache.GetKeys(), service.blockCache.GetCount)
+	entries["logs"] = heightEntries(service.logsCache.GetKeys(), service.logsCache.GetCount)
+	entries["txNotification"] = txHashEntries(service.txNotificationCache.GetTxHashes(), service.txNotificationCache.GetCount)
+	entries["balanceNotification"] = addressEntries(service.balanceNotificationCache.GetAddresses(), service.balanceNotificationCache.GetCount)
+	entries["missedTx"] = txHashEntries(service.missedTxCache.GetTxHashes(), service.missedTxCache.GetCount)
+
+	addrToken := []PendingEntry{}
+	for _, tokenAddress := range service.addrTokenCache.GetTokenAddresses() {
+		for _, address := range service.addrTokenCache.GetAddressesFromTokenAddress(tokenAddress) {
+			addrToken = append(addrToken, PendingEntry{
+				Key:   fmt.Sprintf("token %s address %s", tokenAddress, address),
+				Count: service.addrTokenCache.GetCount(tokenAddress, address),
+			})
+		}
+	}
+	entries["addrToken"] = addrToken
+
+	storage := []PendingEntry{}
+	for _, address := range service.storageCache.GetKeys() {
+		for _, slot := range service.storageCache.GetSubKeys(address) {
+			storage = append(storage, PendingEntry{
+				Key:   fmt.Sprintf("address %s slot %s", address, slot),
+				Count: service.storageCache.GetCount(address, slot),
+			})
+		}
+	}
+	entries["storage"] = storage
+
+	allowance := []PendingEntry{}
+	for _, tokenAddress := range service.allowanceCache.GetKeys() {
+		for _, pair := range service.allowanceCache.GetSubKeys(tokenAddress) {
+			allowance = append(allowance, PendingEntry{
+				Key:   fmt.Sprintf("token %s owner %s spender %s", tokenAddress, pair.Owner, pair.Spender),
+				Count: service.allowanceCache.GetCount(tokenAddress, pair),
+			})
+		}
+	}
+	entries["allowance"] = allowance
+
+	calls := []PendingEntry{}
+	for _, name := range service.callCache.GetKeys() {
+		calls = append(calls, PendingEntry{Key: name, Count: service.callCache.GetCount(name)})
+	}
+	entries["call"] = calls
+
+	entries[ERC721OwnerComparator] = nftEntries(service.erc721OwnerCache, ERC721OwnerComparator)
+	entries[ERC721BalanceComparator] = nftEntries(service.erc721BalanceCache, ERC721BalanceComparator)
+	entries[ERC1155BalanceComparator] = nftEntries(service.erc1155BalanceCache, ERC1155BalanceComparator)
+	return entries
+}
+
+func addressEntries(addresses []common.Address, getCount func(common.Address) int) []PendingEntry {
+	entries := make([]PendingEntry, 0, len(addresses))
+	for _, address := range addresses {
+		entries = append(entries, PendingEntry{Key: address.Hex(), Count: getCount(address)})
+	}
+	return entries
+}
+
+func txHashEntries(txHashes []common.Hash, getCount func(common.Hash) int) []PendingEntry {
+	entries := make([]PendingEntry, 0, len(txHashes))
+	for _, txHash := range txHashes {
+		entries = append(entries, PendingEntry{Key: txHash.Hex(), Count: getCount(txHash)})
+	}
+	return entries
+}
+
+func heightEntries(heights []uint64, getCount func(uint64) int) []PendingEntry {
+	entries := make([]PendingEntry, 0, len(heights))
+	for _, height := range heights {
+		entries = append(entries, PendingEntry{Key: strconv.FormatUint(height, 10), Count: getCount(height)})
+	}
+	return entries
+}
+
+func nftEntries(cache *CompareNestedCountCache[common.Address, NftKey], comparator string) []PendingEntry {
+	entries := []PendingEntry{}
+	for _, tokenAddress := range cache.GetKeys() {
+		for _, key := range cache.GetSubKeys(tokenAddress) {
+			entries = append(entries, PendingEntry{
+				Key:   fmt.Sprintf("token %s %s", tokenAddress, describeNftKey(comparator, key)),
+				Count: cache.GetCount(tokenAddress, key),
+			})
+		}
+	}
+	return entries
+}
@@ -0,0 +1,118 @@
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+// serveAdmin sends the request to the admin handler and decodes the JSON response into the value
+func serveAdmin(t *testing.T, handler http.HandlerFunc, method string, target string, body string, value interface{}) int {
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(method, target, strings.NewReader(body)))
+	if value != nil {
+		if err := json.Unmarshal(recorder.Body.Bytes(), value); err != nil {
+			t.Fatalf("%s %s: decode response %q: %v", method, target, recorder.Body.String(), err)
+		}
+	}
+	return recorder.Code
+}
+
+func TestAdminServerPauseAndStatus(t *testing.T) {
+	service, _ := newTestService(t, func(method string, params []json.RawMessage) interface{} { return nil })
+	server := NewAdminServer([]*CompareService{service}, log.New(io.Discard, "", 0))
+
+	var statuses []NodeStatus
+	if code := serveAdmin(t, server.handlePause, http.MethodPost, "/pause", "", &statuses); code != http.StatusOK {
+		t.Fatalf("pause: got status code %d", code)
+	}
+	if len(statuses) != 1 || !statuses[0].Paused || service.isComparing() {
+		t.Fatalf("got statuses %+v, want the node paused", statuses)
+	}
+
+	serveAdmin(t, server.handleResume, http.MethodPost, "/resume", "", nil)
+	statuses = nil
+	if code := serveAdmin(t, server.handleStatus, http.MethodGet, "/status?node="+service.RpcClient.RealtimeUrl(), "", &statuses); code != http.StatusOK {
+		t.Fatalf("status: got status code %d", code)
+	}
+	if len(statuses) != 1 || statuses[0].Paused || statuses[0].RealtimeUrl != service.RpcClient.RealtimeUrl() {
+		t.Errorf("got statuses %+v, want the resumed node", statuses)
+	}
+
+	if code := serveAdmin(t, server.handleStatus, http.MethodGet, "/status?node=http://unknown", "", nil); code != http.StatusNotFound {
+		t.Errorf("got status code %d for an unknown node, want %d", code, http.StatusNotFound)
+	}
+}
+
+func TestAdminServerSkipAddresses(t *testing.T) {
+	service, _ := newTestService(t, func(method string, params []json.RawMessage) interface{} { return nil })
+	server := NewAdminServer([]*CompareService{service}, log.New(io.Discard, "", 0))
+	address := common.HexToAddress("0x00000000000000000000000000000000000000b7")
+	body := `{"address":"` + address.Hex() + `"}`
+
+	if code := serveAdmin(t, server.handleAddSkipAddress, http.MethodPost, "/skip-addresses", body, nil); code != http.StatusOK {
+		t.Fatalf("add skip address: got status code %d", code)
+	}
+	if !service.isSkipAddress(address) {
+		t.Fatal("got the address compared after it was skipped")
+	}
+	var skipAddresses map[string][]common.Address
+	serveAdmin(t, server.handleGetSkipAddresses, http.MethodGet, "/skip-addresses", "", &skipAddresses)
+	if got := skipAddresses[service.RpcClient.RealtimeUrl()]; len(got) == 0 || got[len(got)-1] != address {
+		t.Errorf("got skip addresses %v, want %s listed", got, address)
+	}
+
+	if code := serveAdmin(t, server.handleRemoveSkipAddress, http.MethodDelete, "/skip-addresses", body, nil); code != http.StatusOK {
+		t.Fatalf("remove skip address: got status code %d", code)
+	}
+	if code := serveAdmin(t, server.handleRemoveSkipAddress, http.MethodDelete, "/skip-addresses", body, nil); code != http.StatusNotFound {
+		t.Errorf("got status code %d removing an address that is not skipped, want %d", code, http.StatusNotFound)
+	}
+	if code := serveAdmin(t, server.handleAddSkipAddress, http.MethodPost, "/skip-addresses", "{", nil); code != http.StatusBadRequest {
+		t.Errorf("got status code %d for a malformed request, want %d", code, http.StatusBadRequest)
+	}
+}
+
+func TestAdminServerCachesAndMismatches(t *testing.T) {
+	service, _ := newTestService(t, func(method string, params []json.RawMessage) interface{} { return nil })
+	mismatchStore := newTestMismatchStore(t)
+	service.mismatchStore = mismatchStore
+	server := NewAdminServer([]*CompareService{service}, log.New(io.Discard, "", 0))
+	address := common.HexToAddress("0x00000000000000000000000000000000000000b8")
+	service.nonceCache.AddWithCount(address, 3)
+	service.missedTxCache.Add(common.HexToHash("0x01"), 10, 1)
+	service.missedTxCache.AddWithCount(common.HexToHash("0x01"), 2)
+	service.recordMismatch(context.Background(), store.Mismatch{Comparator: "nonce", Address: address.Hex(), Eth: "1", Realtime: "2"})
+	service.recordMismatch(context.Background(), store.Mismatch{Comparator: "code", Address: address.Hex()})
+
+	var caches map[string]map[string][]PendingEntry
+	serveAdmin(t, server.handleCaches, http.MethodGet, "/caches", "", &caches)
+	if nonces := caches[service.RpcClient.RealtimeUrl()]["nonce"]; len(nonces) != 1 || nonces[0] != (PendingEntry{Key: address.Hex(), Count: 3}) {
+		t.Errorf("got pending nonces %+v, want %s with count 3", nonces, address.Hex())
+	}
+	if missedTxs := caches[service.RpcClient.RealtimeUrl()]["missedTx"]; len(missedTxs) != 1 || missedTxs[0].Count != 2 {
+		t.Errorf("got pending missed txs %+v, want the missed tx with count 2", missedTxs)
+	}
+
+	var mismatches []store.Mismatch
+	if code := serveAdmin(t, server.handleMismatches, http.MethodGet, "/mismatches?comparator=nonce&address="+address.Hex(), "", &mismatches); code != http.StatusOK {
+		t.Fatalf("mismatches: got status code %d", code)
+	}
+	if len(mismatches) != 1 || mismatches[0].Eth != "1" || mismatches[0].Realtime != "2" {
+		t.Errorf("got mismatches %+v, want the recorded nonce mismatch", mismatches)
+	}
+	if code := serveAdmin(t, server.handleMismatches, http.MethodGet, "/mismatches?limit=many", "", nil); code != http.StatusBadRequest {
+		t.Errorf("got status code %d for an invalid limit, want %d", code, http.StatusBadRequest)
+	}
+	if all, _ := mismatchStore.List(store.Filter{}); len(all) != 2 {
+		t.Errorf("got %d stored mismatches, want 2", len(all))
+	}
+}
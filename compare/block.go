@@ -0,0 +1,114 @@
+package compare
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/sieniven/realtime-compare-tool/metrics"
+	"github.com/sieniven/realtime-compare-tool/rpc"
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+func (service *CompareService) ProcessCompareBlockCache(ctx context.Context) {
+	for {
+		if !service.waitUntilComparing(ctx) {
+			return
+		}
+
+		ethHeight, err := service.RpcClient.EthGetBlockNumber(ctx)
+		if err != nil {
+			service.Logger.Printf("error getting eth block number: %v\n", err)
+			time.Sleep(time.Duration(service.Config.CompareIntervalMS) * time.Millisecond)
+			continue
+		}
+		heights := service.blockCache.GetKeys()
+		service.workerPool.Run(ctx, len(heights), func(ctx context.Context, i int) {
+			height := heights[i]
+			// Only compare once the block is confirmed on the eth node, so reorged blocks are not reported
+			if !isConfirmed(height, ethHeight, service.Config.BlockConfirmations) {
+				return
+			}
+			ethBlock, err := service.RpcClient.EthGetBlockByNumber(ctx, height)
+			if err != nil {
+				service.Logger.Printf("error getting eth block at height %d: %v\n", height, err)
+				return
+			}
+			if ethBlock == nil {
+				return
+			}
+
+			// Run the block comparison
+			diffs, err := service.diffBlock(ctx, ethBlock)
+			if err != nil {
+				service.Logger.Printf("error comparing block at height %d: %v\n", height, err)
+				return
+			}
+			mismatch := store.Mismatch{
+				Comparator: "block",
+				Height:     height,
+				Diffs:      strings.Join(diffs, "; "),
+			}
+			switch settleComparison(ctx, service, service.blockCache, height, len(diffs) == 0, mismatch) {
+			case metrics.ResultConfirmedMismatch:
+				service.Logger.Printf("Error in state comparator: block mismatch at height %d, diffs: %s\n", height, strings.Join(diffs, "; "))
+			case metrics.ResultMatch:
+				service.Logger.Printf("Blocks are equal at height %d\n", height)
+			}
+		})
+
+		time.Sleep(time.Duration(service.Config.CompareIntervalMS) * time.Millisecond)
+	}
+}
+
+// diffBlock compares the realtime view of the block against the eth block, and returns the description of the
+// diverged transaction count and of every position where the realtime transaction differs from the eth transaction
+func (service *CompareService) diffBlock(ctx context.Context, ethBlock *rpc.Block) ([]string, error) {
+	height := uint64(ethBlock.Number)
+	realtimeTxCount, err := service.RpcClient.RealtimeGetBlockTransactionCountByNumber(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+	realtimeTxHashes, errs, err := service.RpcClient.RealtimeBatchGetBlockTransactionHashes(ctx, height, realtimeTxCount)
+	if err != nil {
+		return nil, err
+	}
+	for index, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("error getting realtime tx at index %d: %v", index, err)
+		}
+	}
+
+	diffs := []string{}
+	if uint64(len(ethBlock.Transactions)) != realtimeTxCount {
+		diffs = append(diffs, fmt.Sprintf("tx count eth: %d, realtime: %d", len(ethBlock.Transactions), realtimeTxCount))
+	}
+	return append(diffs, diffTxHashes(ethBlock.Transactions, realtimeTxHashes)...), nil
+}
+
+// diffTxHashes compares the eth and realtime transactions of the block position by position, and returns the
+// description of every dropped, extra or reordered transaction
+func diffTxHashes(ethTxHashes []common.Hash, realtimeTxHashes []common.Hash) []string {
+	diffs := []string{}
+	for index := 0; index < len(ethTxHashes) || index < len(realtimeTxHashes); index++ {
+		var ethTxHash, realtimeTxHash common.Hash
+		if index < len(ethTxHashes) {
+			ethTxHash = ethTxHashes[index]
+		}
+		if index < len(realtimeTxHashes) {
+			realtimeTxHash = realtimeTxHashes[index]
+		}
+		switch {
+		case ethTxHash == realtimeTxHash:
+		case realtimeTxHash == (common.Hash{}):
+			diffs = append(diffs, fmt.Sprintf("dropped tx %s at index %d", ethTxHash, index))
+		case ethTxHash == (common.Hash{}):
+			diffs = append(diffs, fmt.Sprintf("extra realtime tx %s at index %d", realtimeTxHash, index))
+		default:
+			diffs = append(diffs, fmt.Sprintf("tx at index %d eth: %s, realtime: %s", index, ethTxHash, realtimeTxHash))
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,113 @@
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+	"github.com/sieniven/realtime-compare-tool/rpc"
+)
+
+func TestIsConfirmed(t *testing.T) {
+	tests := []struct {
+		height        uint64
+		ethHeight     uint64
+		confirmations int
+		confirmed     bool
+	}{
+		{10, 10, 0, true},
+		{10, 9, 0, false},
+		{10, 14, 5, false},
+		{10, 15, 5, true},
+		{0, 3, 5, false},
+		{10, 10, -1, true},
+	}
+	for _, test := range tests {
+		if confirmed := isConfirmed(test.height, test.ethHeight, test.confirmations); confirmed != test.confirmed {
+			t.Errorf("height %d at eth height %d with %d confirmations: got confirmed %v, want %v", test.height, test.ethHeight, test.confirmations, confirmed, test.confirmed)
+		}
+	}
+}
+
+func TestDiffTxHashes(t *testing.T) {
+	a, b, c := common.HexToHash("0x0a"), common.HexToHash("0x0b"), common.HexToHash("0x0c")
+	diffs := diffTxHashes([]common.Hash{a, b, c}, []common.Hash{a, c, {}, b})
+	want := []string{
+		"tx at index 1 eth: " + b.Hex() + ", realtime: " + c.Hex(),
+		"dropped tx " + c.Hex() + " at index 2",
+		"extra realtime tx " + b.Hex() + " at index 3",
+	}
+	if !reflect.DeepEqual(diffs, want) {
+		t.Errorf("got diffs %q, want %q", diffs, want)
+	}
+}
+
+func TestCompareServiceBlockRound(t *testing.T) {
+	first, second := common.HexToHash("0x01"), common.HexToHash("0x02")
+	var ethBlockReads atomic.Int32
+	service, logs := newTestService(t, func(method string, params []json.RawMessage) interface{} {
+		switch method {
+		case "eth_blockNumber":
+			return "0xc"
+		case "eth_getBlockByNumber":
+			ethBlockReads.Add(1)
+			var height hexutil.Uint64
+			json.Unmarshal(params[0], &height)
+			return rpc.Block{Number: height, Transactions: []common.Hash{first, second}}
+		case "realtime_getBlockTransactionCountByNumber":
+			return "0x2"
+		case "realtime_getTransactionByBlockNumberAndIndex":
+			// Realtime orders the transactions of the block the other way around
+			var index hexutil.Uint64
+			json.Unmarshal(params[1], &index)
+			return map[string]common.Hash{"hash": []common.Hash{second, first}[index]}
+		}
+		return nil
+	})
+	service.Config.BlockConfirmations = 5
+
+	// Block 10 only has 2 confirmations at eth height 12, so it is never read from eth
+	service.blockCache.Add(10)
+	service.blockCache.Add(7)
+	runComparisons(t, service.ProcessCompareBlockCache, func() bool {
+		return service.blockCache.Size() == 1
+	})
+	if ethBlockReads.Load() != 2 {
+		t.Errorf("got %d eth block reads, want 2 for the mismatch attempts of the confirmed block", ethBlockReads.Load())
+	}
+	if service.blockCache.GetCount(10) != 0 {
+		t.Errorf("got count %d for the unconfirmed block, want 0", service.blockCache.GetCount(10))
+	}
+
+	mismatches := logs.find("block mismatch")
+	if len(mismatches) != 1 || !strings.Contains(mismatches[0], "at height 7, diffs: tx at index 0") {
+		t.Errorf("got mismatches %q, want the reordered block at height 7", mismatches)
+	}
+}
+
+func TestOnHeightQueuesNewHeights(t *testing.T) {
+	service, _ := newTestService(t, func(method string, params []json.RawMessage) interface{} { return nil })
+	service.InitFlag.Store(true)
+	service.onHeight(context.Background(), 10)
+	service.blockCache.Remove(10)
+	service.logsCache.Remove(10)
+
+	// Duplicate and stale heights are not compared again
+	service.onHeight(context.Background(), 10)
+	service.onHeight(context.Background(), 9)
+	if service.blockCache.Size() != 0 || service.logsCache.Size() != 0 {
+		t.Errorf("got %v block and %v logs heights queued, want none for the stale heights", service.blockCache.GetKeys(), service.logsCache.GetKeys())
+	}
+	service.onHeight(context.Background(), 11)
+	if keys := service.blockCache.GetKeys(); len(keys) != 1 || keys[0] != 11 {
+		t.Errorf("got block heights %v queued, want the new height 11", keys)
+	}
+	if keys := service.logsCache.GetKeys(); len(keys) != 1 || keys[0] != 11 {
+		t.Errorf("got logs heights %v queued, want the new height 11", keys)
+	}
+}
@@ -1,186 +1,558 @@
 package compare
 
 import (
+	"math/big"
 	"sync"
 
 	lru "github.com/hashicorp/golang-lru/v2"
 	"github.com/ledgerwatch/erigon-lib/common"
 )
 
-type CompareBalanceCache struct {
+// CompareCountCache keeps the pending items of a comparator with the number of consecutive mismatches of each item
+type CompareCountCache[K comparable] struct {
 	mu    sync.RWMutex
-	cache *lru.Cache[common.Address, int]
+	cache *lru.Cache[K, int]
 }
 
-func NewCompareBalanceCache() (*CompareBalanceCache, error) {
-	cache, err := lru.NewWithEvict[common.Address, int](DefaultCacheSize, nil)
+func NewCompareCountCache[K comparable]() (*CompareCountCache[K], error) {
+	cache, err := lru.NewWithEvict[K, int](DefaultCacheSize, nil)
 	if err != nil {
 		return nil, err
 	}
-	return &CompareBalanceCache{
+	return &CompareCountCache[K]{
 		cache: cache,
 	}, nil
 }
 
-func (cache *CompareBalanceCache) Add(address common.Address) {
+func (cache *CompareCountCache[K]) Add(key K) {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
 
 	// Only add if cache miss
-	if _, ok := cache.cache.Get(address); !ok {
-		cache.cache.Add(address, 0)
+	if _, ok := cache.cache.Get(key); !ok {
+		cache.cache.Add(key, 0)
 	}
 }
 
-func (cache *CompareBalanceCache) AddWithCount(address common.Address, count int) {
+func (cache *CompareCountCache[K]) AddWithCount(key K, count int) {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
 
 	// AddWithCount overrides the current count in the current cache
-	cache.cache.Add(address, count)
+	cache.cache.Add(key, count)
 }
 
-func (cache *CompareBalanceCache) Remove(address common.Address) {
+func (cache *CompareCountCache[K]) Remove(key K) {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
 
-	cache.cache.Remove(address)
+	cache.cache.Remove(key)
 }
 
-func (cache *CompareBalanceCache) Clear() {
+func (cache *CompareCountCache[K]) Clear() {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
 
 	cache.cache.Purge()
 }
 
-func (cache *CompareBalanceCache) Size() int {
+func (cache *CompareCountCache[K]) Size() int {
 	cache.mu.RLock()
 	defer cache.mu.RUnlock()
 
 	return cache.cache.Len()
 }
 
-func (cache *CompareBalanceCache) GetCount(address common.Address) int {
+func (cache *CompareCountCache[K]) GetCount(key K) int {
 	cache.mu.RLock()
 	defer cache.mu.RUnlock()
 
-	count, _ := cache.cache.Get(address)
+	count, _ := cache.cache.Get(key)
 	return count
 }
 
-func (cache *CompareBalanceCache) GetAddresses() []common.Address {
+func (cache *CompareCountCache[K]) GetKeys() []K {
 	cache.mu.RLock()
 	defer cache.mu.RUnlock()
 
-	addresses := make([]common.Address, 0, cache.cache.Len())
-	addresses = append(addresses, cache.cache.Keys()...)
-	return addresses
+	keys := make([]K, 0, cache.cache.Len())
+	keys = append(keys, cache.cache.Keys()...)
+	return keys
 }
 
-type CompareAddrTokenCache struct {
+// CompareNestedCountCache keeps the pending items of a comparator grouped by an outer key, e.g. the storage slots
+// of a contract address, with the number of consecutive mismatches of each item
+type CompareNestedCountCache[K comparable, S comparable] struct {
 	mu    sync.RWMutex
-	cache *lru.Cache[common.Address, map[common.Address]int]
+	cache *lru.Cache[K, map[S]int]
 }
 
-func NewCompareAddrTokenCache() (*CompareAddrTokenCache, error) {
-	cache, err := lru.NewWithEvict[common.Address, map[common.Address]int](DefaultCacheSize, nil)
+func NewCompareNestedCountCache[K comparable, S comparable]() (*CompareNestedCountCache[K, S], error) {
+	cache, err := lru.NewWithEvict[K, map[S]int](DefaultCacheSize, nil)
 	if err != nil {
 		return nil, err
 	}
-	return &CompareAddrTokenCache{
+	return &CompareNestedCountCache[K, S]{
 		cache: cache,
 	}, nil
 }
 
-func (cache *CompareAddrTokenCache) Add(tokenAddress common.Address, address common.Address) {
+func (cache *CompareNestedCountCache[K, S]) Add(key K, subKey S) {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
 
-	addresses, ok := cache.cache.Get(tokenAddress)
+	subKeys, ok := cache.cache.Get(key)
 	if !ok {
-		addresses = make(map[common.Address]int)
-		cache.cache.Add(tokenAddress, addresses)
+		subKeys = make(map[S]int)
+		cache.cache.Add(key, subKeys)
 	}
 	// Only add if cache miss
-	if _, ok := addresses[address]; !ok {
-		addresses[address] = 0
+	if _, ok := subKeys[subKey]; !ok {
+		subKeys[subKey] = 0
 	}
 }
 
-func (cache *CompareAddrTokenCache) AddWithCount(tokenAddress common.Address, address common.Address, count int) {
+func (cache *CompareNestedCountCache[K, S]) AddWithCount(key K, subKey S, count int) {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
 
-	addresses, ok := cache.cache.Get(tokenAddress)
+	subKeys, ok := cache.cache.Get(key)
 	if !ok {
-		addresses = make(map[common.Address]int)
-		cache.cache.Add(tokenAddress, addresses)
+		subKeys = make(map[S]int)
+		cache.cache.Add(key, subKeys)
 	}
 	// AddWithCount overrides the current count in the current cache
-	addresses[address] = count
+	subKeys[subKey] = count
 }
 
-func (cache *CompareAddrTokenCache) Remove(tokenAddress common.Address, address common.Address) {
+func (cache *CompareNestedCountCache[K, S]) Remove(key K, subKey S) {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
 
-	addresses, ok := cache.cache.Get(tokenAddress)
+	subKeys, ok := cache.cache.Get(key)
 	if ok {
-		delete(addresses, address)
-		if len(addresses) == 0 {
-			// Token address has no more pending addresses, remove it from the cache
-			cache.cache.Remove(tokenAddress)
+		delete(subKeys, subKey)
+		if len(subKeys) == 0 {
+			// Key has no more pending sub keys, remove it from the cache
+			cache.cache.Remove(key)
 		}
 	}
 }
 
-func (cache *CompareAddrTokenCache) Clear() {
+func (cache *CompareNestedCountCache[K, S]) Clear() {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
 
 	cache.cache.Purge()
 }
 
-func (cache *CompareAddrTokenCache) Size() int {
+func (cache *CompareNestedCountCache[K, S]) Size() int {
 	cache.mu.RLock()
 	defer cache.mu.RUnlock()
 
 	return cache.cache.Len()
 }
 
-func (cache *CompareAddrTokenCache) GetCount(tokenAddress common.Address, address common.Address) int {
+func (cache *CompareNestedCountCache[K, S]) GetCount(key K, subKey S) int {
 	cache.mu.RLock()
 	defer cache.mu.RUnlock()
 
-	addresses, ok := cache.cache.Get(tokenAddress)
+	subKeys, ok := cache.cache.Get(key)
 	if ok {
-		count := addresses[address]
-		return count
+		return subKeys[subKey]
 	}
 	return 0
 }
 
-func (cache *CompareAddrTokenCache) GetTokenAddresses() []common.Address {
+func (cache *CompareNestedCountCache[K, S]) GetKeys() []K {
 	cache.mu.RLock()
 	defer cache.mu.RUnlock()
 
-	tokenAddresses := make([]common.Address, 0, cache.cache.Len())
-	tokenAddresses = append(tokenAddresses, cache.cache.Keys()...)
-	return tokenAddresses
+	keys := make([]K, 0, cache.cache.Len())
+	keys = append(keys, cache.cache.Keys()...)
+	return keys
 }
 
-func (cache *CompareAddrTokenCache) GetAddressesFromTokenAddress(tokenAddress common.Address) []common.Address {
+func (cache *CompareNestedCountCache[K, S]) GetSubKeys(key K) []S {
 	cache.mu.RLock()
 	defer cache.mu.RUnlock()
 
-	addressSet, ok := cache.cache.Get(tokenAddress)
+	subKeySet, ok := cache.cache.Get(key)
 	if !ok {
 		return nil
 	}
-	addresses := make([]common.Address, 0, len(addressSet))
-	for address := range addressSet {
-		addresses = append(addresses, address)
+	subKeys := make([]S, 0, len(subKeySet))
+	for subKey := range subKeySet {
+		subKeys = append(subKeys, subKey)
+	}
+	return subKeys
+}
+
+// CompareBalanceCache keeps the pending addresses of the balance comparator
+type CompareBalanceCache struct {
+	*CompareCountCache[common.Address]
+}
+
+func NewCompareBalanceCache() (*CompareBalanceCache, error) {
+	cache, err := NewCompareCountCache[common.Address]()
+	if err != nil {
+		return nil, err
+	}
+	return &CompareBalanceCache{
+		CompareCountCache: cache,
+	}, nil
+}
+
+func (cache *CompareBalanceCache) GetAddresses() []common.Address {
+	return cache.GetKeys()
+}
+
+// CompareAddrTokenCache keeps the pending token holder addresses of the token balance comparator grouped by the
+// token address
+type CompareAddrTokenCache struct {
+	*CompareNestedCountCache[common.Address, common.Address]
+}
+
+func NewCompareAddrTokenCache() (*CompareAddrTokenCache, error) {
+	cache, err := NewCompareNestedCountCache[common.Address, common.Address]()
+	if err != nil {
+		return nil, err
+	}
+	return &CompareAddrTokenCache{
+		CompareNestedCountCache: cache,
+	}, nil
+}
+
+func (cache *CompareAddrTokenCache) GetTokenAddresses() []common.Address {
+	return cache.GetKeys()
+}
+
+func (cache *CompareAddrTokenCache) GetAddressesFromTokenAddress(tokenAddress common.Address) []common.Address {
+	return cache.GetSubKeys(tokenAddress)
+}
+
+// AllowancePair is the owner and spender pair of an erc20 allowance
+type AllowancePair struct {
+	Owner   common.Address
+	Spender common.Address
+}
+
+// NftKey is the holder and token id of an nft comparison. Fields not used by the comparison are left empty
+type NftKey struct {
+	Holder  common.Address
+	TokenID common.Hash
+}
+
+// TxNotification is the latest realtime transaction notification of the tx. The height is zero while the tx is
+// pending, and the node height is the realtime height when the tx was first notified
+type TxNotification struct {
+	Height     uint64
+	NodeHeight uint64
+	Count      int
+}
+
+type CompareTxNotificationCache struct {
+	mu    sync.RWMutex
+	cache *lru.Cache[common.Hash, TxNotification]
+}
+
+func NewCompareTxNotificationCache() (*CompareTxNotificationCache, error) {
+	cache, err := lru.NewWithEvict[common.Hash, TxNotification](DefaultCacheSize, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &CompareTxNotificationCache{
+		cache: cache,
+	}, nil
+}
+
+func (cache *CompareTxNotificationCache) Add(txHash common.Hash, height uint64, nodeHeight uint64) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	// Only reset the count if the notified height changed, keeping the node height of the first notification
+	notification, ok := cache.cache.Get(txHash)
+	if !ok {
+		cache.cache.Add(txHash, TxNotification{Height: height, NodeHeight: nodeHeight})
+	} else if notification.Height != height {
+		cache.cache.Add(txHash, TxNotification{Height: height, NodeHeight: notification.NodeHeight})
+	}
+}
+
+func (cache *CompareTxNotificationCache) AddWithCount(txHash common.Hash, count int) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	// AddWithCount overrides the current count of the notification in the current cache
+	if notification, ok := cache.cache.Get(txHash); ok {
+		notification.Count = count
+		cache.cache.Add(txHash, notification)
+	}
+}
+
+func (cache *CompareTxNotificationCache) Remove(txHash common.Hash) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.cache.Remove(txHash)
+}
+
+func (cache *CompareTxNotificationCache) Clear() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.cache.Purge()
+}
+
+func (cache *CompareTxNotificationCache) Size() int {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	return cache.cache.Len()
+}
+
+func (cache *CompareTxNotificationCache) Get(txHash common.Hash) (TxNotification, bool) {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	return cache.cache.Get(txHash)
+}
+
+func (cache *CompareTxNotificationCache) GetCount(txHash common.Hash) int {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	notification, _ := cache.cache.Get(txHash)
+	return notification.Count
+}
+
+func (cache *CompareTxNotificationCache) GetTxHashes() []common.Hash {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	txHashes := make([]common.Hash, 0, cache.cache.Len())
+	txHashes = append(txHashes, cache.cache.Keys()...)
+	return txHashes
+}
+
+// MissedTx is a kafka tx awaiting its realtime transaction notification. The node height is the realtime height
+// when the tx was received from kafka, and the subscription is the number of the live tx subscription at the time
+type MissedTx struct {
+	NodeHeight   uint64
+	Subscription uint64
+	Count        int
+}
+
+type CompareMissedTxCache struct {
+	mu    sync.RWMutex
+	cache *lru.Cache[common.Hash, MissedTx]
+}
+
+func NewCompareMissedTxCache() (*CompareMissedTxCache, error) {
+	cache, err := lru.NewWithEvict[common.Hash, MissedTx](DefaultCacheSize, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &CompareMissedTxCache{
+		cache: cache,
+	}, nil
+}
+
+func (cache *CompareMissedTxCache) Add(txHash common.Hash, nodeHeight uint64, subscription uint64) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	// Keep the node height and subscription of the first kafka message of the tx
+	if !cache.cache.Contains(txHash) {
+		cache.cache.Add(txHash, MissedTx{NodeHeight: nodeHeight, Subscription: subscription})
+	}
+}
+
+func (cache *CompareMissedTxCache) AddWithCount(txHash common.Hash, count int) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	// AddWithCount overrides the current count of the tx in the current cache
+	if missedTx, ok := cache.cache.Get(txHash); ok {
+		missedTx.Count = count
+		cache.cache.Add(txHash, missedTx)
 	}
+}
+
+func (cache *CompareMissedTxCache) Remove(txHash common.Hash) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.cache.Remove(txHash)
+}
+
+func (cache *CompareMissedTxCache) Clear() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.cache.Purge()
+}
+
+func (cache *CompareMissedTxCache) Size() int {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	return cache.cache.Len()
+}
+
+func (cache *CompareMissedTxCache) Get(txHash common.Hash) (MissedTx, bool) {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	return cache.cache.Get(txHash)
+}
+
+func (cache *CompareMissedTxCache) GetCount(txHash common.Hash) int {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	missedTx, _ := cache.cache.Get(txHash)
+	return missedTx.Count
+}
+
+func (cache *CompareMissedTxCache) GetTxHashes() []common.Hash {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	txHashes := make([]common.Hash, 0, cache.cache.Len())
+	txHashes = append(txHashes, cache.cache.Keys()...)
+	return txHashes
+}
+
+// BalanceNotification is the latest realtime balance change notification of the address. The height is zero if
+// the notification does not carry the block number
+type BalanceNotification struct {
+	Balance *big.Int
+	Height  uint64
+	Count   int
+}
+
+type CompareBalanceNotificationCache struct {
+	mu    sync.RWMutex
+	cache *lru.Cache[common.Address, BalanceNotification]
+}
+
+func NewCompareBalanceNotificationCache() (*CompareBalanceNotificationCache, error) {
+	cache, err := lru.NewWithEvict[common.Address, BalanceNotification](DefaultCacheSize, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &CompareBalanceNotificationCache{
+		cache: cache,
+	}, nil
+}
+
+func (cache *CompareBalanceNotificationCache) Add(address common.Address, balance *big.Int, height uint64) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	// A newer notification supersedes the pending one of the address
+	cache.cache.Add(address, BalanceNotification{Balance: balance, Height: height})
+}
+
+func (cache *CompareBalanceNotificationCache) AddWithCount(address common.Address, count int) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	// AddWithCount overrides the current count of the notification in the current cache
+	if notification, ok := cache.cache.Get(address); ok {
+		notification.Count = count
+		cache.cache.Add(address, notification)
+	}
+}
+
+func (cache *CompareBalanceNotificationCache) Remove(address common.Address) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.cache.Remove(address)
+}
+
+func (cache *CompareBalanceNotificationCache) Clear() {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cache.cache.Purge()
+}
+
+func (cache *CompareBalanceNotificationCache) Size() int {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	return cache.cache.Len()
+}
+
+func (cache *CompareBalanceNotificationCache) Get(address common.Address) (BalanceNotification, bool) {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	return cache.cache.Get(address)
+}
+
+func (cache *CompareBalanceNotificationCache) GetCount(address common.Address) int {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	notification, _ := cache.cache.Get(address)
+	return notification.Count
+}
+
+func (cache *CompareBalanceNotificationCache) GetAddresses() []common.Address {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	addresses := make([]common.Address, 0, cache.cache.Len())
+	addresses = append(addresses, cache.cache.Keys()...)
 	return addresses
 }
+
+// NotifiedTxSet keeps the latest tx hashes received on the realtime transaction subscription
+type NotifiedTxSet struct {
+	cache *lru.Cache[common.Hash, struct{}]
+}
+
+func NewNotifiedTxSet() (*NotifiedTxSet, error) {
+	cache, err := lru.NewWithEvict[common.Hash, struct{}](DefaultCacheSize, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &NotifiedTxSet{
+		cache: cache,
+	}, nil
+}
+
+func (set *NotifiedTxSet) Add(txHash common.Hash) {
+	set.cache.Add(txHash, struct{}{})
+}
+
+func (set *NotifiedTxSet) Contains(txHash common.Hash) bool {
+	return set.cache.Contains(txHash)
+}
+
+// subKeyCache returns the count cache of the sub keys of the key
+func (cache *CompareNestedCountCache[K, S]) subKeyCache(key K) nestedSubKeyCache[K, S] {
+	return nestedSubKeyCache[K, S]{cache: cache, key: key}
+}
+
+// nestedSubKeyCache is the count cache of the sub keys of a key in a nested compare cache
+type nestedSubKeyCache[K comparable, S comparable] struct {
+	cache *CompareNestedCountCache[K, S]
+	key   K
+}
+
+func (cache nestedSubKeyCache[K, S]) AddWithCount(subKey S, count int) {
+	cache.cache.AddWithCount(cache.key, subKey, count)
+}
+
+func (cache nestedSubKeyCache[K, S]) Remove(subKey S) {
+	cache.cache.Remove(cache.key, subKey)
+}
+
+func (cache nestedSubKeyCache[K, S]) GetCount(subKey S) int {
+	return cache.cache.GetCount(cache.key, subKey)
+}
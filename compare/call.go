@@ -0,0 +1,347 @@
+package compare
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/accounts/abi"
+	"github.com/sieniven/realtime-compare-tool/metrics"
+	"github.com/sieniven/realtime-compare-tool/rpc"
+	"github.com/sieniven/realtime-compare-tool/store"
+	"gopkg.in/yaml.v2"
+)
+
+// CallConfig is a contract view call listed in the call config file. The call data is either set directly as
+// ABI-encoded data, or packed from the ABI json, method name and arguments
+type CallConfig struct {
+	Name    string   `yaml:"name"`
+	Address string   `yaml:"address"`
+	Data    string   `yaml:"data"`
+	ABI     string   `yaml:"abi"`
+	Method  string   `yaml:"method"`
+	Args    []string `yaml:"args"`
+}
+
+// ContractCall is a parsed contract view call. The method is nil if no ABI is configured, in which case the
+// outputs are compared as raw bytes
+type ContractCall struct {
+	Name    string
+	Address common.Address
+	Data    []byte
+	Method  *abi.Method
+}
+
+// LoadContractCalls parses the contract view calls from the YAML call config file
+func LoadContractCalls(filePath string) ([]ContractCall, error) {
+	yamlFile, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var configs []CallConfig
+	if err := yaml.Unmarshal(yamlFile, &configs); err != nil {
+		return nil, err
+	}
+
+	calls := make([]ContractCall, 0, len(configs))
+	names := make(map[string]struct{}, len(configs))
+	for _, config := range configs {
+		if _, ok := names[config.Name]; ok {
+			return nil, fmt.Errorf("duplicate call name %s in call config", config.Name)
+		}
+		names[config.Name] = struct{}{}
+		call, err := config.toContractCall()
+		if err != nil {
+			return nil, fmt.Errorf("invalid call %s in call config: %v", config.Name, err)
+		}
+		calls = append(calls, call)
+	}
+	return calls, nil
+}
+
+func (config CallConfig) toContractCall() (ContractCall, error) {
+	if config.Name == "" {
+		return ContractCall{}, fmt.Errorf("missing call name")
+	}
+	if !common.IsHexAddress(config.Address) {
+		return ContractCall{}, fmt.Errorf("invalid contract address %s", config.Address)
+	}
+	call := ContractCall{
+		Name:    config.Name,
+		Address: common.HexToAddress(config.Address),
+		Data:    common.FromHex(config.Data),
+	}
+	if config.ABI == "" {
+		if len(call.Data) == 0 {
+			return ContractCall{}, fmt.Errorf("missing call data or abi")
+		}
+		return call, nil
+	}
+
+	contractABI, err := abi.JSON(strings.NewReader(config.ABI))
+	if err != nil {
+		return ContractCall{}, err
+	}
+	method, ok := contractABI.Methods[config.Method]
+	if !ok {
+		return ContractCall{}, fmt.Errorf("method %s not found in abi", config.Method)
+	}
+	call.Method = &method
+	if len(call.Data) > 0 {
+		// ABI is only used to decode the outputs of the configured call data
+		return call, nil
+	}
+
+	if len(config.Args) != len(method.Inputs) {
+		return ContractCall{}, fmt.Errorf("method %s expects %d args, got %d", config.Method, len(method.Inputs), len(config.Args))
+	}
+	args := make([]interface{}, 0, len(config.Args))
+	for i, input := range method.Inputs {
+		arg, err := parseCallArg(input.Type, config.Args[i])
+		if err != nil {
+			return ContractCall{}, fmt.Errorf("invalid arg %d: %v", i, err)
+		}
+		args = append(args, arg)
+	}
+	data, err := contractABI.Pack(config.Method, args...)
+	if err != nil {
+		return ContractCall{}, fmt.Errorf("failed to pack %s call: %v", config.Method, err)
+	}
+	call.Data = data
+	return call, nil
+}
+
+// parseCallArg converts the string arg into the go type expected by the abi packer
+func parseCallArg(typ abi.Type, value string) (interface{}, error) {
+	switch typ.T {
+	case abi.AddressTy:
+		if !common.IsHexAddress(value) {
+			return nil, fmt.Errorf("invalid address %s", value)
+		}
+		return common.HexToAddress(value), nil
+	case abi.BoolTy:
+		return strconv.ParseBool(value)
+	case abi.StringTy:
+		return value, nil
+	case abi.BytesTy:
+		return common.FromHex(value), nil
+	case abi.FixedBytesTy:
+		if typ.Size != 32 {
+			return nil, fmt.Errorf("unsupported fixed bytes size %d", typ.Size)
+		}
+		return common.HexToHash(value), nil
+	case abi.UintTy, abi.IntTy:
+		number, ok := new(big.Int).SetString(value, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %s", value)
+		}
+		return toSizedInteger(typ, number)
+	default:
+		return nil, fmt.Errorf("unsupported arg type %s", typ)
+	}
+}
+
+// toSizedInteger converts the integer into the go integer type the abi packer expects for the type size, which is
+// the native integer type for 8, 16, 32 and 64 bit integers and a big integer for every other size
+func toSizedInteger(typ abi.Type, number *big.Int) (interface{}, error) {
+	if typ.T == abi.UintTy {
+		if number.Sign() < 0 || number.BitLen() > typ.Size {
+			return nil, fmt.Errorf("integer %s overflows uint%d", number, typ.Size)
+		}
+		switch typ.Size {
+		case 8:
+			return uint8(number.Uint64()), nil
+		case 16:
+			return uint16(number.Uint64()), nil
+		case 32:
+			return uint32(number.Uint64()), nil
+		case 64:
+			return number.Uint64(), nil
+		default:
+			return number, nil
+		}
+	}
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(typ.Size-1))
+	if number.Cmp(limit) >= 0 || number.Cmp(new(big.Int).Neg(limit)) < 0 {
+		return nil, fmt.Errorf("integer %s overflows int%d", number, typ.Size)
+	}
+	switch typ.Size {
+	case 8:
+		return int8(number.Int64()), nil
+	case 16:
+		return int16(number.Int64()), nil
+	case 32:
+		return int32(number.Int64()), nil
+	case 64:
+		return number.Int64(), nil
+	default:
+		return number, nil
+	}
+}
+
+// FormatOutput decodes the call output with the method ABI for readable diffs, falling back to the raw hex output
+func (call ContractCall) FormatOutput(output []byte) string {
+	if call.Method == nil {
+		return fmt.Sprintf("0x%x", output)
+	}
+	values, err := call.Method.Outputs.Unpack(output)
+	if err != nil {
+		return fmt.Sprintf("0x%x", output)
+	}
+	return fmt.Sprintf("%v", values)
+}
+
+// ProcessContractCallTicker periodically enqueues all configured contract calls for comparison
+func (service *CompareService) ProcessContractCallTicker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		if service.InitFlag.Load() {
+			for name := range service.contractCalls {
+				service.callCache.Add(name)
+			}
+		}
+
+		time.Sleep(time.Duration(service.Config.CallIntervalMS) * time.Millisecond)
+	}
+}
+
+// addContractCalls enqueues the configured contract calls on the address for comparison
+func (service *CompareService) addContractCalls(address common.Address) {
+	for name, call := range service.contractCalls {
+		if call.Address == address {
+			service.callCache.Add(name)
+		}
+	}
+}
+
+func (service *CompareService) ProcessCompareCallCache(ctx context.Context) {
+	for {
+		if !service.waitUntilComparing(ctx) {
+			return
+		}
+
+		names := service.callCache.GetKeys()
+		calls := make([]ContractCall, 0, len(names))
+		for _, name := range names {
+			call, ok := service.contractCalls[name]
+			if !ok {
+				service.callCache.Remove(name)
+				continue
+			}
+			calls = append(calls, call)
+		}
+		// Run the contract call comparison in concurrent batches
+		batches := chunk(calls, service.Config.Rpc.BatchSize)
+		service.workerPool.Run(ctx, len(batches), func(ctx context.Context, i int) {
+			service.compareCalls(ctx, batches[i])
+		})
+
+		time.Sleep(time.Duration(service.Config.CompareIntervalMS) * time.Millisecond)
+	}
+}
+
+// compareCalls compares the outputs of the batch of contract calls
+func (service *CompareService) compareCalls(ctx context.Context, calls []ContractCall) {
+	height, ethOutputs, realtimeOutputs, errs, err := service.getBatchCallOutputs(ctx, calls)
+	if err != nil {
+		service.Logger.Printf("error getting call outputs: %v\n", err)
+		return
+	}
+	for i, call := range calls {
+		name := call.Name
+		if errs[i] != nil {
+			service.Logger.Printf("error getting call outputs for call %s on contract %s: %v\n", name, call.Address, errs[i])
+			continue
+		}
+		mismatch := store.Mismatch{
+			Comparator: "call",
+			Address:    call.Address.Hex(),
+			Key:        name,
+			Height:     height,
+			Eth:        call.FormatOutput(ethOutputs[i]),
+			Realtime:   call.FormatOutput(realtimeOutputs[i]),
+		}
+		switch settleComparison(ctx, service, service.callCache, name, bytes.Equal(ethOutputs[i], realtimeOutputs[i]), mismatch) {
+		case metrics.ResultConfirmedMismatch:
+			service.Logger.Printf("Error in state comparator: call mismatch at height %d for call %s on contract %s, eth: %s, realtime: %s\n", height, name, call.Address, call.FormatOutput(ethOutputs[i]), call.FormatOutput(realtimeOutputs[i]))
+		case metrics.ResultMatch:
+			service.Logger.Printf("Call outputs are equal at height %d for call %s on contract %s\n", height, name, call.Address)
+		}
+	}
+}
+
+// getCallOutputs returns the eth and realtime outputs of the contract call, along with the height they were
+// compared at. In height consistent mode, both calls are executed at the same realtime block height
+func (service *CompareService) getCallOutputs(ctx context.Context, call ContractCall) (uint64, []byte, []byte, error) {
+	data := fmt.Sprintf("0x%x", call.Data)
+	if !service.Config.HeightConsistent {
+		ethOutput, err := service.RpcClient.EthCall(ctx, call.Address, call.Data, nil)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		realtimeOutput, err := service.RpcClient.RealtimeCall(ctx, call.Address, "0x0", data)
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		return uint64(service.NodeHeight.Load()), ethOutput, common.FromHex(realtimeOutput), nil
+	}
+
+	return readAtConsistentHeight(ctx, service,
+		func() ([]byte, uint64, error) {
+			realtimeOutput, height, err := service.RpcClient.RealtimeCallAtHeight(ctx, call.Address, "0x0", data)
+			return common.FromHex(realtimeOutput), height, err
+		},
+		func(height uint64) ([]byte, error) {
+			return service.RpcClient.EthCall(ctx, call.Address, call.Data, new(big.Int).SetUint64(height))
+		})
+}
+
+// getBatchCallOutputs returns the eth and realtime outputs of the contract calls executed in batches, along with the
+// error of each call and the height they were compared at. In height consistent mode, both batches are executed at
+// the same realtime block height
+func (service *CompareService) getBatchCallOutputs(ctx context.Context, calls []ContractCall) (uint64, [][]byte, [][]byte, []error, error) {
+	if len(calls) == 0 {
+		return 0, nil, nil, nil, nil
+	}
+	msgs := make([]rpc.BatchCallMsg, 0, len(calls))
+	for _, call := range calls {
+		msgs = append(msgs, rpc.BatchCallMsg{To: call.Address, Data: call.Data})
+	}
+
+	if !service.Config.HeightConsistent {
+		ethOutputs, ethErrs, err := service.RpcClient.EthBatchCallContracts(ctx, msgs, "latest")
+		if err != nil {
+			return 0, nil, nil, nil, err
+		}
+		realtimeOutputs, realtimeErrs, err := service.RpcClient.RealtimeBatchCallContracts(ctx, msgs)
+		if err != nil {
+			return 0, nil, nil, nil, err
+		}
+		return uint64(service.NodeHeight.Load()), ethOutputs, realtimeOutputs, mergeErrors(ethErrs, realtimeErrs), nil
+	}
+
+	height, ethResult, realtimeResult, err := readAtConsistentHeight(ctx, service,
+		func() (batchResult[[]byte], uint64, error) {
+			outputs, errs, height, err := service.RpcClient.RealtimeBatchCallContractsAtHeight(ctx, msgs)
+			return batchResult[[]byte]{values: outputs, errs: errs}, height, err
+		},
+		func(height uint64) (batchResult[[]byte], error) {
+			outputs, errs, err := service.RpcClient.EthBatchCallContracts(ctx, msgs, rpc.BlockNumberToHex(height))
+			return batchResult[[]byte]{values: outputs, errs: errs}, err
+		})
+	if err != nil {
+		return 0, nil, nil, nil, err
+	}
+
+	return height, ethResult.values, realtimeResult.values, mergeErrors(ethResult.errs, realtimeResult.errs), nil
+}
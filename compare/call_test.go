@@ -0,0 +1,121 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/accounts/abi"
+)
+
+func TestParseCallArg(t *testing.T) {
+	tests := []struct {
+		name  string
+		typ   abi.Type
+		value string
+		want  interface{}
+		err   bool
+	}{
+		{"address", abi.Type{T: abi.AddressTy, Size: 20}, "0x0000000000000000000000000000000000000001", common.Address{19: 1}, false},
+		{"invalid address", abi.Type{T: abi.AddressTy, Size: 20}, "0x01", nil, true},
+		{"bool", abi.Type{T: abi.BoolTy}, "true", true, false},
+		{"invalid bool", abi.Type{T: abi.BoolTy}, "yes", nil, true},
+		{"string", abi.Type{T: abi.StringTy}, "name", "name", false},
+		{"bytes", abi.Type{T: abi.BytesTy}, "0x0102", []byte{1, 2}, false},
+		{"bytes32", abi.Type{T: abi.FixedBytesTy, Size: 32}, "0x01", common.Hash{31: 1}, false},
+		{"unsupported fixed bytes", abi.Type{T: abi.FixedBytesTy, Size: 4}, "0x01", nil, true},
+		{"uint8", abi.Type{T: abi.UintTy, Size: 8}, "255", uint8(255), false},
+		{"hex uint64", abi.Type{T: abi.UintTy, Size: 64}, "0x10", uint64(16), false},
+		{"uint256", abi.Type{T: abi.UintTy, Size: 256}, "1000", big.NewInt(1000), false},
+		{"int32", abi.Type{T: abi.IntTy, Size: 32}, "-5", int32(-5), false},
+		{"uint24", abi.Type{T: abi.UintTy, Size: 24}, "0xffffff", big.NewInt(0xffffff), false},
+		{"int24", abi.Type{T: abi.IntTy, Size: 24}, "-8388608", big.NewInt(-8388608), false},
+		{"uint24 overflow", abi.Type{T: abi.UintTy, Size: 24}, "16777216", nil, true},
+		{"invalid integer", abi.Type{T: abi.UintTy, Size: 256}, "abc", nil, true},
+		{"unsupported type", abi.Type{T: abi.SliceTy}, "[]", nil, true},
+	}
+	for _, test := range tests {
+		got, err := parseCallArg(test.typ, test.value)
+		if (err != nil) != test.err {
+			t.Errorf("%s: got error %v, want error %v", test.name, err, test.err)
+			continue
+		}
+		if !test.err && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %#v, want %#v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestToSizedInteger(t *testing.T) {
+	tests := []struct {
+		name   string
+		typ    abi.Type
+		number int64
+		want   interface{}
+		err    bool
+	}{
+		{"uint8 max", abi.Type{T: abi.UintTy, Size: 8}, 255, uint8(255), false},
+		{"uint8 overflow", abi.Type{T: abi.UintTy, Size: 8}, 256, nil, true},
+		{"uint16", abi.Type{T: abi.UintTy, Size: 16}, 65535, uint16(65535), false},
+		{"uint32", abi.Type{T: abi.UintTy, Size: 32}, 1, uint32(1), false},
+		{"uint64", abi.Type{T: abi.UintTy, Size: 64}, 1, uint64(1), false},
+		{"negative uint", abi.Type{T: abi.UintTy, Size: 64}, -1, nil, true},
+		{"int8 max", abi.Type{T: abi.IntTy, Size: 8}, 127, int8(127), false},
+		{"int8 min", abi.Type{T: abi.IntTy, Size: 8}, -128, int8(-128), false},
+		{"int8 overflow", abi.Type{T: abi.IntTy, Size: 8}, 128, nil, true},
+		{"int8 underflow", abi.Type{T: abi.IntTy, Size: 8}, -129, nil, true},
+		{"int16", abi.Type{T: abi.IntTy, Size: 16}, -1, int16(-1), false},
+		{"int64", abi.Type{T: abi.IntTy, Size: 64}, -1, int64(-1), false},
+		{"uint24 stays big", abi.Type{T: abi.UintTy, Size: 24}, 16777215, big.NewInt(16777215), false},
+		{"uint24 overflow", abi.Type{T: abi.UintTy, Size: 24}, 16777216, nil, true},
+		{"int24 stays big", abi.Type{T: abi.IntTy, Size: 24}, -8388608, big.NewInt(-8388608), false},
+		{"int24 overflow", abi.Type{T: abi.IntTy, Size: 24}, 8388608, nil, true},
+		{"uint40 stays big", abi.Type{T: abi.UintTy, Size: 40}, 1 << 39, big.NewInt(1 << 39), false},
+		{"uint128 stays big", abi.Type{T: abi.UintTy, Size: 128}, 1, big.NewInt(1), false},
+	}
+	for _, test := range tests {
+		got, err := toSizedInteger(test.typ, big.NewInt(test.number))
+		if (err != nil) != test.err {
+			t.Errorf("%s: got error %v, want error %v", test.name, err, test.err)
+			continue
+		}
+		if !test.err && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %#v, want %#v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCompareServiceCallRound(t *testing.T) {
+	contract := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+	paused := ContractCall{Name: "paused", Address: contract, Data: common.FromHex("0x5c975abb")}
+	owner := ContractCall{Name: "owner", Address: contract, Data: common.FromHex("0x8da5cb5b")}
+	ownerOutput := common.HexToHash("0xe2").Hex()
+	service, logs := newTestService(t, func(method string, params []json.RawMessage) interface{} {
+		var call struct {
+			Data string `json:"data"`
+		}
+		json.Unmarshal(params[0], &call)
+		switch call.Data {
+		case "0x5c975abb":
+			// Realtime reports the contract as paused
+			if method == "realtime_call" {
+				return common.HexToHash("0x01").Hex()
+			}
+			return common.HexToHash("0x00").Hex()
+		case "0x8da5cb5b":
+			return ownerOutput
+		}
+		return nil
+	})
+	service.contractCalls = map[string]ContractCall{paused.Name: paused, owner.Name: owner}
+	service.callCache.Add(paused.Name)
+	service.callCache.Add(owner.Name)
+	// Calls removed from the call config are dropped from the cache
+	service.callCache.Add("unpause")
+
+	mismatches := runComparisonRound(t, service.ProcessCompareCallCache, service.callCache.Size, logs, "call mismatch")
+	expectMismatch(t, mismatches, fmt.Sprintf("for call paused on contract %s, eth: %s, realtime: %s", contract, common.HexToHash("0x00").Hex(), common.HexToHash("0x01").Hex()))
+}
@@ -0,0 +1,72 @@
+package compare
+
+import (
+	"context"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/crypto"
+	"github.com/sieniven/realtime-compare-tool/metrics"
+	"github.com/sieniven/realtime-compare-tool/rpc"
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+func (service *CompareService) ProcessCompareCodeCache(ctx context.Context) {
+	for {
+		if !service.waitUntilComparing(ctx) {
+			return
+		}
+
+		addresses := service.codeCache.GetKeys()
+		service.workerPool.Run(ctx, len(addresses), func(ctx context.Context, i int) {
+			address := addresses[i]
+			// Run the contract code comparison
+			height, ethCode, realtimeCode, err := service.getCodes(ctx, address)
+			if err != nil {
+				service.Logger.Printf("error getting codes for address %s: %v\n", address, err)
+				return
+			}
+			ethCodeHash := crypto.Keccak256Hash(common.FromHex(ethCode))
+			realtimeCodeHash := crypto.Keccak256Hash(common.FromHex(realtimeCode))
+			mismatch := store.Mismatch{
+				Comparator: "code",
+				Address:    address.Hex(),
+				Height:     height,
+				Eth:        ethCodeHash.Hex(),
+				Realtime:   realtimeCodeHash.Hex(),
+			}
+			switch settleComparison(ctx, service, service.codeCache, address, ethCodeHash == realtimeCodeHash, mismatch) {
+			case metrics.ResultConfirmedMismatch:
+				service.Logger.Printf("Error in state comparator: code hash mismatch at height %d for address %s, eth: %s, realtime: %s\n", height, address, ethCodeHash, realtimeCodeHash)
+			case metrics.ResultMatch:
+				service.Logger.Printf("Code hashes are equal at height %d for address %s\n", height, address)
+			}
+		})
+
+		time.Sleep(time.Duration(service.Config.CompareIntervalMS) * time.Millisecond)
+	}
+}
+
+// getCodes returns the eth and realtime codes at the address, along with the height they were compared at.
+// In height consistent mode, both codes are read at the same realtime block height
+func (service *CompareService) getCodes(ctx context.Context, address common.Address) (uint64, string, string, error) {
+	if !service.Config.HeightConsistent {
+		ethCode, err := service.RpcClient.EthGetCode(ctx, address, "latest")
+		if err != nil {
+			return 0, "", "", err
+		}
+		realtimeCode, err := service.RpcClient.RealtimeGetCode(ctx, address)
+		if err != nil {
+			return 0, "", "", err
+		}
+		return uint64(service.NodeHeight.Load()), ethCode, realtimeCode, nil
+	}
+
+	return readAtConsistentHeight(ctx, service,
+		func() (string, uint64, error) {
+			return service.RpcClient.RealtimeGetCodeAtHeight(ctx, address)
+		},
+		func(height uint64) (string, error) {
+			return service.RpcClient.EthGetCode(ctx, address, rpc.BlockNumberToHex(height))
+		})
+}
@@ -0,0 +1,35 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/crypto"
+)
+
+func TestCompareServiceCodeRound(t *testing.T) {
+	deployed := common.HexToAddress("0x00000000000000000000000000000000000000c1")
+	destroyed := common.HexToAddress("0x00000000000000000000000000000000000000c2")
+	// Realtime still serves the code of the self-destructed contract
+	ethCodes := map[common.Address]string{deployed: "0x6080604052", destroyed: "0x"}
+	realtimeCodes := map[common.Address]string{deployed: "0x6080604052", destroyed: "0x60806040"}
+	service, logs := newTestService(t, func(method string, params []json.RawMessage) interface{} {
+		var address common.Address
+		json.Unmarshal(params[0], &address)
+		switch method {
+		case "eth_getCode":
+			return ethCodes[address]
+		case "realtime_getCode":
+			return realtimeCodes[address]
+		}
+		return nil
+	})
+	service.codeCache.Add(deployed)
+	service.codeCache.Add(destroyed)
+
+	mismatches := runComparisonRound(t, service.ProcessCompareCodeCache, service.codeCache.Size, logs, "code hash mismatch")
+	// The eth code hash is the empty code hash of the destroyed contract
+	expectMismatch(t, mismatches, fmt.Sprintf("for address %s, eth: %s, realtime: %s", destroyed, crypto.Keccak256Hash(nil), crypto.Keccak256Hash(common.FromHex("0x60806040"))))
+}
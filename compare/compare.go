@@ -2,42 +2,29 @@ package compare
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/big"
 	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/sieniven/realtime-compare-tool/metrics"
+	"github.com/sieniven/realtime-compare-tool/rpc"
+	"github.com/sieniven/realtime-compare-tool/store"
 )
 
 func (service *CompareService) ProcessCompareBalanceCache(ctx context.Context) {
 	for {
-		select {
-		case <-ctx.Done():
-		default:
+		if !service.waitUntilComparing(ctx) {
+			return
 		}
 
 		addresses := service.balanceCache.GetAddresses()
-		for _, address := range addresses {
-			// Run the native balance comparison
-			ethBalance, err := service.RpcClient.EthGetBalance(address, "latest")
-			if err != nil {
-				service.Logger.Printf("error getting eth balance for address %s: %v\n", address, err)
-				continue
-			}
-			realtimeBalance, err := service.RpcClient.RealtimeGetBalance(address)
-			if err != nil {
-				service.Logger.Printf("error getting realtime balance for address %s: %v\n", address, err)
-				continue
-			}
-			if ethBalance.Cmp(realtimeBalance) != 0 {
-				count := service.balanceCache.GetCount(address)
-				if count > service.Config.MismatchCount {
-					service.Logger.Printf("Error in state comparator: balance mismatch at height %d for address %s, eth: %s, realtime: %s\n", service.NodeHeight.Load(), address, ethBalance, realtimeBalance)
-					service.balanceCache.Remove(address)
-				} else {
-					service.balanceCache.AddWithCount(address, count+1)
-				}
-			} else {
-				service.Logger.Printf("Native balance are equal at height %d for address %s\n", service.NodeHeight.Load(), address)
-				service.balanceCache.Remove(address)
-			}
-		}
+		// Run the native balance comparison in concurrent batches
+		batches := chunk(addresses, service.Config.Rpc.BatchSize)
+		service.workerPool.Run(ctx, len(batches), func(ctx context.Context, i int) {
+			service.compareNativeBalances(ctx, batches[i])
+		})
 
 		time.Sleep(time.Duration(service.Config.CompareIntervalMS) * time.Millisecond)
 	}
@@ -46,40 +33,267 @@ func (service *CompareService) ProcessCompareBalanceCache(ctx context.Context) {
 
 func (service *CompareService) ProcessCompareAddrTokenCache(ctx context.Context) {
 	for {
-		select {
-		case <-ctx.Done():
-		default:
+		if !service.waitUntilComparing(ctx) {
+			return
 		}
 		tokenAddresses := service.addrTokenCache.GetTokenAddresses()
+		addresses := make([]common.Address, 0)
+		addressTokens := make([]common.Address, 0)
 		for _, tokenAddress := range tokenAddresses {
-			addresses := service.addrTokenCache.GetAddressesFromTokenAddress(tokenAddress)
-			for _, address := range addresses {
-				// Run the token balance comparison
-				ethBalance, err := service.RpcClient.EthGetTokenBalance(ctx, address, tokenAddress)
-				if err != nil {
-					service.Logger.Printf("error getting eth token balance for token address %s and address %s: %v\n", tokenAddress, address, err)
-					continue
-				}
-				realtimeBalance, err := service.RpcClient.RealtimeGetTokenBalance(ctx, address, tokenAddress)
-				if err != nil {
-					service.Logger.Printf("error getting realtime token balance for token address %s and address %s: %v\n", tokenAddress, address, err)
-					continue
-				}
-				if ethBalance.Cmp(realtimeBalance) != 0 {
-					count := service.addrTokenCache.GetCount(tokenAddress, address)
-					if count > service.Config.MismatchCount {
-						service.Logger.Printf("Error in state comparator: balance mismatch at height %d for address %s, eth: %s, realtime: %s\n", service.NodeHeight.Load(), address, ethBalance, realtimeBalance)
-						service.addrTokenCache.Remove(tokenAddress, address)
-					} else {
-						service.addrTokenCache.AddWithCount(tokenAddress, address, count+1)
-					}
-				} else {
-					service.Logger.Printf("Address token balances are equal at height %d for token address %s and address %s\n", service.NodeHeight.Load(), tokenAddress, address)
-					service.addrTokenCache.Remove(tokenAddress, address)
-				}
+			for _, address := range service.addrTokenCache.GetAddressesFromTokenAddress(tokenAddress) {
+				addresses = append(addresses, address)
+				addressTokens = append(addressTokens, tokenAddress)
 			}
 		}
+		// Run the token balance comparison in concurrent batches
+		addressBatches := chunk(addresses, service.Config.Rpc.BatchSize)
+		tokenBatches := chunk(addressTokens, service.Config.Rpc.BatchSize)
+		service.workerPool.Run(ctx, len(addressBatches), func(ctx context.Context, i int) {
+			service.compareTokenBalances(ctx, addressBatches[i], tokenBatches[i])
+		})
 
 		time.Sleep(time.Duration(service.Config.CompareIntervalMS) * time.Millisecond)
 	}
 }
+
+// compareNativeBalances compares the native balances of the batch of addresses
+func (service *CompareService) compareNativeBalances(ctx context.Context, addresses []common.Address) {
+	height, ethBalances, realtimeBalances, errs, err := service.getNativeBalances(ctx, addresses)
+	if err != nil {
+		service.Logger.Printf("error getting native balances: %v\n", err)
+		return
+	}
+	for i, address := range addresses {
+		if errs[i] != nil {
+			service.Logger.Printf("error getting native balances for address %s: %v\n", address, errs[i])
+			continue
+		}
+		if ethBalances[i].Cmp(realtimeBalances[i]) != 0 {
+			count := service.balanceCache.GetCount(address)
+			if count > service.Config.MismatchCount {
+				service.observeComparison("balance", metrics.ResultConfirmedMismatch)
+				service.Logger.Printf("Error in state comparator: balance mismatch at height %d for address %s, eth: %s, realtime: %s\n", height, address, ethBalances[i], realtimeBalances[i])
+				service.recordMismatch(ctx, store.Mismatch{
+					Comparator: "balance",
+					Address:    address.Hex(),
+					Height:     height,
+					Eth:        ethBalances[i].String(),
+					Realtime:   realtimeBalances[i].String(),
+					Attempts:   count + 1,
+				})
+				service.balanceCache.Remove(address)
+			} else {
+				service.balanceCache.AddWithCount(address, count+1)
+				service.observeComparison("balance", metrics.ResultTransientMismatch)
+			}
+		} else {
+			service.observeComparison("balance", metrics.ResultMatch)
+			service.Logger.Printf("Native balance are equal at height %d for address %s\n", height, address)
+			service.balanceCache.Remove(address)
+		}
+	}
+}
+
+// compareTokenBalances compares the token balances of the batch of addresses in the token address at the same index
+func (service *CompareService) compareTokenBalances(ctx context.Context, addresses []common.Address, addressTokens []common.Address) {
+	height, ethBalances, realtimeBalances, errs, err := service.getTokenBalances(ctx, addresses, addressTokens)
+	if err != nil {
+		service.Logger.Printf("error getting token balances: %v\n", err)
+		return
+	}
+	for i, address := range addresses {
+		tokenAddress := addressTokens[i]
+		if errs[i] != nil {
+			service.Logger.Printf("error getting token balances for token address %s and address %s: %v\n", tokenAddress, address, errs[i])
+			continue
+		}
+		if ethBalances[i].Cmp(realtimeBalances[i]) != 0 {
+			count := service.addrTokenCache.GetCount(tokenAddress, address)
+			if count > service.Config.MismatchCount {
+				service.observeComparison("token balance", metrics.ResultConfirmedMismatch)
+				service.Logger.Printf("Error in state comparator: balance mismatch at height %d for address %s, eth: %s, realtime: %s\n", height, address, ethBalances[i], realtimeBalances[i])
+				service.recordMismatch(ctx, store.Mismatch{
+					Comparator: "token balance",
+					Address:    address.Hex(),
+					Token:      tokenAddress.Hex(),
+					Height:     height,
+					Eth:        ethBalances[i].String(),
+					Realtime:   realtimeBalances[i].String(),
+					Attempts:   count + 1,
+				})
+				service.addrTokenCache.Remove(tokenAddress, address)
+			} else {
+				service.addrTokenCache.AddWithCount(tokenAddress, address, count+1)
+				service.observeComparison("token balance", metrics.ResultTransientMismatch)
+			}
+		} else {
+			service.observeComparison("token balance", metrics.ResultMatch)
+			service.Logger.Printf("Address token balances are equal at height %d for token address %s and address %s\n", height, tokenAddress, address)
+			service.addrTokenCache.Remove(tokenAddress, address)
+		}
+	}
+}
+
+// getNativeBalances returns the eth and realtime native balances of the addresses read in batches, along with the
+// error of each address and the height they were compared at. In height consistent mode, both batches are read at
+// the same realtime block height
+func (service *CompareService) getNativeBalances(ctx context.Context, addresses []common.Address) (uint64, []*big.Int, []*big.Int, []error, error) {
+	if len(addresses) == 0 {
+		return 0, nil, nil, nil, nil
+	}
+	if !service.Config.HeightConsistent {
+		ethBalances, ethErrs, err := service.RpcClient.EthBatchGetBalances(ctx, addresses, "latest")
+		if err != nil {
+			return 0, nil, nil, nil, err
+		}
+		realtimeBalances, realtimeErrs, err := service.RpcClient.RealtimeBatchGetBalances(ctx, addresses)
+		if err != nil {
+			return 0, nil, nil, nil, err
+		}
+		return uint64(service.NodeHeight.Load()), ethBalances, realtimeBalances, mergeErrors(ethErrs, realtimeErrs), nil
+	}
+
+	height, ethResult, realtimeResult, err := readAtConsistentHeight(ctx, service,
+		func() (batchResult[*big.Int], uint64, error) {
+			balances, errs, height, err := service.RpcClient.RealtimeBatchGetBalancesAtHeight(ctx, addresses)
+			return batchResult[*big.Int]{values: balances, errs: errs}, height, err
+		},
+		func(height uint64) (batchResult[*big.Int], error) {
+			balances, errs, err := service.RpcClient.EthBatchGetBalances(ctx, addresses, rpc.BlockNumberToHex(height))
+			return batchResult[*big.Int]{values: balances, errs: errs}, err
+		})
+	if err != nil {
+		return 0, nil, nil, nil, err
+	}
+
+	return height, ethResult.values, realtimeResult.values, mergeErrors(ethResult.errs, realtimeResult.errs), nil
+}
+
+// getTokenBalances returns the eth and realtime token balances of each address in the token address at the same
+// index, read in batches, along with the error of each address and the height they were compared at. In height
+// consistent mode, both batches are read at the same realtime block height
+func (service *CompareService) getTokenBalances(ctx context.Context, addresses []common.Address, tokenAddresses []common.Address) (uint64, []*big.Int, []*big.Int, []error, error) {
+	if len(addresses) == 0 {
+		return 0, nil, nil, nil, nil
+	}
+	calls := make([]rpc.BatchCallMsg, 0, len(addresses))
+	for i, address := range addresses {
+		data, err := rpc.PackERC20("balanceOf", address)
+		if err != nil {
+			return 0, nil, nil, nil, err
+		}
+		calls = append(calls, rpc.BatchCallMsg{To: tokenAddresses[i], Data: data})
+	}
+
+	if !service.Config.HeightConsistent {
+		ethOutputs, ethErrs, err := service.RpcClient.EthBatchCallContracts(ctx, calls, "latest")
+		if err != nil {
+			return 0, nil, nil, nil, err
+		}
+		realtimeOutputs, realtimeErrs, err := service.RpcClient.RealtimeBatchCallContracts(ctx, calls)
+		if err != nil {
+			return 0, nil, nil, nil, err
+		}
+		ethBalances := toTokenBalances(ethOutputs, ethErrs)
+		realtimeBalances := toTokenBalances(realtimeOutputs, realtimeErrs)
+		return uint64(service.NodeHeight.Load()), ethBalances, realtimeBalances, mergeErrors(ethErrs, realtimeErrs), nil
+	}
+
+	height, ethResult, realtimeResult, err := readAtConsistentHeight(ctx, service,
+		func() (batchResult[[]byte], uint64, error) {
+			outputs, errs, height, err := service.RpcClient.RealtimeBatchCallContractsAtHeight(ctx, calls)
+			return batchResult[[]byte]{values: outputs, errs: errs}, height, err
+		},
+		func(height uint64) (batchResult[[]byte], error) {
+			outputs, errs, err := service.RpcClient.EthBatchCallContracts(ctx, calls, rpc.BlockNumberToHex(height))
+			return batchResult[[]byte]{values: outputs, errs: errs}, err
+		})
+	if err != nil {
+		return 0, nil, nil, nil, err
+	}
+	ethBalances := toTokenBalances(ethResult.values, ethResult.errs)
+	realtimeBalances := toTokenBalances(realtimeResult.values, realtimeResult.errs)
+
+	return height, ethBalances, realtimeBalances, mergeErrors(ethResult.errs, realtimeResult.errs), nil
+}
+
+// toTokenBalances decodes the balanceOf call outputs, setting the error of each output that is not a uint256
+func toTokenBalances(outputs [][]byte, errs []error) []*big.Int {
+	balances := make([]*big.Int, len(outputs))
+	for i, output := range outputs {
+		if errs[i] != nil {
+			continue
+		}
+		if len(output) != 32 {
+			errs[i] = fmt.Errorf("invalid balanceOf output 0x%x", output)
+			continue
+		}
+		balances[i] = new(big.Int).SetBytes(output)
+	}
+	return balances
+}
+
+// batchResult is the outputs of a batch read along with the error of each element
+type batchResult[V any] struct {
+	values []V
+	errs   []error
+}
+
+// readAtConsistentHeight reads the realtime value along with the realtime height it was read at, retrying with a short
+// backoff if a block landed during the read, then waits for the eth node to reach the height and reads the eth value
+// at the same height. Returns the height, the eth value and the realtime value
+func readAtConsistentHeight[T any](ctx context.Context, service *CompareService, realtimeRead func() (T, uint64, error), ethRead func(height uint64) (T, error)) (uint64, T, T, error) {
+	var zero T
+	var realtimeValue T
+	var height uint64
+	var err error
+	for i := 0; i < DefaultHeightRetryCount; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return 0, zero, zero, ErrCtxCancelled
+			case <-time.After(time.Duration(i*DefaultHeightRetryBackoffMS) * time.Millisecond):
+			}
+		}
+		realtimeValue, height, err = realtimeRead()
+		if !errors.Is(err, rpc.ErrRealtimeHeightChanged) {
+			break
+		}
+	}
+	if err != nil {
+		return 0, zero, zero, err
+	}
+	if err := service.waitForEthHeight(ctx, height); err != nil {
+		return 0, zero, zero, err
+	}
+	ethValue, err := ethRead(height)
+	if err != nil {
+		return 0, zero, zero, err
+	}
+	return height, ethValue, realtimeValue, nil
+}
+
+// waitForEthHeight polls the eth block number until the eth node has reached the height, and returns
+// ErrEthHeightBehind if it has not caught up after the retries
+func (service *CompareService) waitForEthHeight(ctx context.Context, height uint64) error {
+	for i := 0; i < DefaultHeightRetryCount; i++ {
+		ethHeight, err := service.RpcClient.EthGetBlockNumber(ctx)
+		if err != nil {
+			return err
+		}
+		if ethHeight >= height {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ErrCtxCancelled
+		case <-time.After(time.Duration(DefaultHeightPollMS) * time.Millisecond):
+		}
+	}
+	return ErrEthHeightBehind
+}
+
+// isConfirmed returns true if the eth node has built at least the confirmations blocks on top of the height
+func isConfirmed(height uint64, ethHeight uint64, confirmations int) bool {
+	return height+uint64(max(confirmations, 0)) <= ethHeight
+}
@@ -0,0 +1,121 @@
+package compare
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+)
+
+func TestCompareServiceHeightConsistentBalanceRound(t *testing.T) {
+	address := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	var ethHeight atomic.Value
+	ethHeight.Store("0xf")
+	var ethHeightReads atomic.Int32
+	var mu sync.Mutex
+	ethBlocks := []string{}
+	service, logs := newTestService(t, func(method string, params []json.RawMessage) interface{} {
+		switch method {
+		case "realtime_blockNumber":
+			return "0x10"
+		case "eth_blockNumber":
+			ethHeightReads.Add(1)
+			return ethHeight.Load()
+		case "realtime_getBalance":
+			return "0x64"
+		case "eth_getBalance":
+			var block string
+			json.Unmarshal(params[1], &block)
+			mu.Lock()
+			ethBlocks = append(ethBlocks, block)
+			mu.Unlock()
+			// The latest eth balance has moved on since the realtime height
+			if block == "0x10" {
+				return "0x64"
+			}
+			return "0x1"
+		}
+		return nil
+	})
+	service.Config.HeightConsistent = true
+	service.balanceCache.Add(address)
+
+	// The balance is not compared while the eth node is behind the realtime height
+	runComparisons(t, service.ProcessCompareBalanceCache, func() bool {
+		return ethHeightReads.Load() >= DefaultHeightRetryCount
+	})
+	mu.Lock()
+	if len(ethBlocks) != 0 {
+		t.Fatalf("got eth balance reads at %v, want none until eth reaches the realtime height", ethBlocks)
+	}
+	mu.Unlock()
+	if service.balanceCache.Size() != 1 || service.balanceCache.GetCount(address) != 0 {
+		t.Fatalf("got %d cached addresses with count %d, want the address kept without a mismatch count", service.balanceCache.Size(), service.balanceCache.GetCount(address))
+	}
+
+	ethHeight.Store("0x11")
+	runComparisons(t, service.ProcessCompareBalanceCache, func() bool {
+		return service.balanceCache.Size() == 0
+	})
+	mu.Lock()
+	defer mu.Unlock()
+	for _, block := range ethBlocks {
+		if block != "0x10" {
+			t.Errorf("got eth balance read at block %s, want the realtime height 0x10", block)
+		}
+	}
+	if mismatches := logs.find("balance mismatch"); len(mismatches) != 0 {
+		t.Errorf("got mismatches %q, want the balances equal at the realtime height", mismatches)
+	}
+	if equal := logs.find("Native balance are equal at height 16"); len(equal) == 0 {
+		t.Error("got no equal balance comparison at the realtime height 16")
+	}
+}
+
+func TestToTokenBalances(t *testing.T) {
+	callErr := errors.New("execution reverted")
+	balance := make([]byte, 32)
+	balance[31] = 42
+
+	tests := []struct {
+		name    string
+		output  []byte
+		err     error
+		balance *big.Int
+		invalid bool
+	}{
+		{"balance", balance, nil, big.NewInt(42), false},
+		{"zero balance", make([]byte, 32), nil, big.NewInt(0), false},
+		{"call error", nil, callErr, nil, false},
+		{"empty output", []byte{}, nil, nil, true},
+		{"short output", []byte{42}, nil, nil, true},
+	}
+	outputs := make([][]byte, 0, len(tests))
+	errs := make([]error, 0, len(tests))
+	for _, test := range tests {
+		outputs = append(outputs, test.output)
+		errs = append(errs, test.err)
+	}
+
+	balances := toTokenBalances(outputs, errs)
+	for i, test := range tests {
+		switch {
+		case test.err != nil:
+			if errs[i] != test.err || balances[i] != nil {
+				t.Errorf("%s: got balance %v and error %v, want the call error kept", test.name, balances[i], errs[i])
+			}
+		case test.invalid:
+			if errs[i] == nil || balances[i] != nil {
+				t.Errorf("%s: got balance %v and error %v, want an invalid output error", test.name, balances[i], errs[i])
+			}
+		default:
+			if errs[i] != nil || balances[i].Cmp(test.balance) != 0 {
+				t.Errorf("%s: got balance %v and error %v, want %s", test.name, balances[i], errs[i], test.balance)
+			}
+		}
+	}
+}
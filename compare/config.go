@@ -14,13 +14,67 @@ type CompareConfig struct {
 
 	// Compare configs
 	MismatchCount     int
+	CompareWorkers    int
 	CompareIntervalMS int
 	SkipAddresses     []common.Address
+	HeightConsistent  bool
+	// Eth confirmations of a block before the block and logs comparisons
+	BlockConfirmations int
+
+	// Lag monitor configs
+	LagThreshold  int
+	LagIntervalMS int
+
+	// Call comparison configs
+	CallConfigFile string
+	CallIntervalMS int
+
+	// Realtime subscription comparison configs
+	SubscriptionCompare bool
+	SubscribeMethod     string
+	TxSubscription      string
+	BalanceSubscription string
+
+	// Confirmed mismatch store path, empty to disable
+	MismatchStorePath string
+
+	// Prometheus metrics listen address, empty to disable
+	MetricsAddr string
+
+	// Admin api listen address, empty to disable
+	AdminAddr string
+
+	// Mismatch alert configs
+	AlertWebhookUrl     string
+	AlertChatWebhookUrl string
+	AlertDedupWindowMS  int
+	AlertGroupWindowMS  int
+	AlertRateLimit      int
+
+	// Mismatch evidence capture configs
+	EvidenceDir            string
+	EvidenceDumpStateCache bool
+	EvidenceDumpCooldownMS int
+
+	// Logs comparison configs
+	LogsAddresses []common.Address
+	LogsTopics    []common.Hash
 }
 
 type RpcConfig struct {
-	RpcUrl string
-	WsUrl  string
+	RpcUrl        string
+	RealtimeUrls  []string
+	ReferenceUrl  string
+	BatchSize     int
+	EndpointLimit int
+	// Transport policy
+	TimeoutMS         int
+	MaxRetries        int
+	RetryBackoffMS    int
+	BreakerThreshold  int
+	BreakerCooldownMS int
+	WsUrl             string
+	WsHeightSource    bool
 }
 
 func NewCompareConfig(ctx *cli.Context) CompareConfig {
@@ -32,12 +86,54 @@ func NewCompareConfig(ctx *cli.Context) CompareConfig {
 			ClientID:         ctx.String(KafkaClientID.Name),
 		},
 		Rpc: RpcConfig{
-			RpcUrl: ctx.String(RpcUrl.Name),
-			WsUrl:  ctx.String(WsUrl.Name),
+			RpcUrl:            ctx.String(RpcUrl.Name),
+			RealtimeUrls:      make([]string, 0),
+			ReferenceUrl:      ctx.String(ReferenceRpcUrl.Name),
+			BatchSize:         ctx.Int(RpcBatchSize.Name),
+			EndpointLimit:     ctx.Int(RpcEndpointConcurrency.Name),
+			TimeoutMS:         ctx.Int(RpcTimeoutMS.Name),
+			MaxRetries:        ctx.Int(RpcMaxRetries.Name),
+			RetryBackoffMS:    ctx.Int(RpcRetryBackoffMS.Name),
+			BreakerThreshold:  ctx.Int(RpcBreakerThreshold.Name),
+			BreakerCooldownMS: ctx.Int(RpcBreakerCooldownMS.Name),
+			WsUrl:             ctx.String(WsUrl.Name),
+			WsHeightSource:    ctx.Bool(WsHeightSource.Name),
 		},
-		MismatchCount:     ctx.Int(MismatchCount.Name),
-		CompareIntervalMS: ctx.Int(CompareIntervalMS.Name),
-		SkipAddresses:     make([]common.Address, 0),
+		MismatchCount:          ctx.Int(MismatchCount.Name),
+		CompareWorkers:         ctx.Int(CompareWorkers.Name),
+		CompareIntervalMS:      ctx.Int(CompareIntervalMS.Name),
+		SkipAddresses:          make([]common.Address, 0),
+		HeightConsistent:       ctx.Bool(HeightConsistent.Name),
+		BlockConfirmations:     ctx.Int(BlockConfirmations.Name),
+		MismatchStorePath:      ctx.String(MismatchStorePath.Name),
+		MetricsAddr:            ctx.String(MetricsAddr.Name),
+		AdminAddr:              ctx.String(AdminAddr.Name),
+		AlertWebhookUrl:        ctx.String(AlertWebhookUrl.Name),
+		AlertChatWebhookUrl:    ctx.String(AlertChatWebhookUrl.Name),
+		AlertDedupWindowMS:     ctx.Int(AlertDedupWindowMS.Name),
+		AlertGroupWindowMS:     ctx.Int(AlertGroupWindowMS.Name),
+		AlertRateLimit:         ctx.Int(AlertRateLimit.Name),
+		EvidenceDir:            ctx.String(EvidenceDir.Name),
+		EvidenceDumpStateCache: ctx.Bool(EvidenceDumpStateCache.Name),
+		EvidenceDumpCooldownMS: ctx.Int(EvidenceDumpCooldownMS.Name),
+		LagThreshold:           ctx.Int(LagThreshold.Name),
+		LagIntervalMS:          ctx.Int(LagIntervalMS.Name),
+		CallConfigFile:         ctx.String(CallConfigFile.Name),
+		CallIntervalMS:         ctx.Int(CallIntervalMS.Name),
+		SubscriptionCompare:    ctx.Bool(SubscriptionCompare.Name),
+		SubscribeMethod:        ctx.String(SubscribeMethod.Name),
+		TxSubscription:         ctx.String(TxSubscription.Name),
+		BalanceSubscription:    ctx.String(BalanceSubscription.Name),
+	}
+
+	// Realtime and reference endpoints default to the rpc url
+	if realtimeUrls := ctx.String(RealtimeRpcUrl.Name); realtimeUrls != "" {
+		cfg.Rpc.RealtimeUrls = append(cfg.Rpc.RealtimeUrls, strings.Split(realtimeUrls, ",")...)
+	} else {
+		cfg.Rpc.RealtimeUrls = append(cfg.Rpc.RealtimeUrls, cfg.Rpc.RpcUrl)
+	}
+	if cfg.Rpc.ReferenceUrl == "" {
+		cfg.Rpc.ReferenceUrl = cfg.Rpc.RpcUrl
 	}
 
 	addrsHex := strings.Split(ctx.String(SkipAddresses.Name), ",")
@@ -45,5 +141,16 @@ func NewCompareConfig(ctx *cli.Context) CompareConfig {
 		cfg.SkipAddresses = append(cfg.SkipAddresses, common.HexToAddress(addrHex))
 	}
 
+	if logsAddrs := ctx.String(LogsAddresses.Name); logsAddrs != "" {
+		for _, addrHex := range strings.Split(logsAddrs, ",") {
+			cfg.LogsAddresses = append(cfg.LogsAddresses, common.HexToAddress(addrHex))
+		}
+	}
+	if logsTopics := ctx.String(LogsTopics.Name); logsTopics != "" {
+		for _, topicHex := range strings.Split(logsTopics, ",") {
+			cfg.LogsTopics = append(cfg.LogsTopics, common.HexToHash(topicHex))
+		}
+	}
+
 	return cfg
 }
@@ -1,13 +1,81 @@
 package compare
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
+
+var (
+	ErrCtxCancelled    = fmt.Errorf("context cancelled - stopping")
+	ErrEthHeightBehind = fmt.Errorf("eth height behind realtime height")
+	ErrNoTokenCalls    = fmt.Errorf("no token call succeeded")
+	// Tx comparison errors
+	ErrTxNotComparable = fmt.Errorf("realtime tx cannot be compared")
+	// Logs comparison errors
+	ErrLogsNotComparable = fmt.Errorf("realtime block logs cannot be compared")
+	// Cross node comparison errors
+	ErrNodeHeightsDiffer  = fmt.Errorf("realtime node heights differ")
+	ErrFleetWsUnsupported = fmt.Errorf("ws.url subscribes to a single realtime node and is not supported with multiple realtime rpc urls")
+)
 
 var (
-	ErrCtxCancelled = fmt.Errorf("context cancelled - stopping")
+	// Transaction json fields compared by the transaction comparator, including the extra info l2 hash
+	DefaultTxCompareFields = []string{"blockHash", "blockNumber", "transactionIndex", "from", "nonce", "value", "input", "v", "r", "s", "l2Hash"}
+	// Token level erc20 methods compared by the token comparator
+	DefaultTokenCompareMethods = []string{"totalSupply", "name", "symbol", "decimals"}
+	// Optional erc20 metadata methods, which are skipped if the token does not implement them
+	DefaultTokenOptionalMethods = []string{"name", "symbol", "decimals"}
 )
 
 const (
 	DefaultHeightSyncRange = 5
 	DefaultChannelSize     = 1000
 	DefaultCacheSize       = 1000
+	DefaultBatchSize       = 100
+	// Concurrency defaults
+	DefaultWorkerCount         = 8
+	DefaultEndpointConcurrency = 16
+	// Block comparison defaults
+	DefaultBlockConfirmations = 5
+	// Mismatch store defaults, the store is disabled unless a path is configured
+	DefaultMismatchStorePath = ""
+	// Metrics defaults, the metrics endpoint is disabled unless an address is configured
+	DefaultMetricsAddr = ""
+	// Admin api defaults
+	DefaultAdminReadHeaderTimeout = 10 * time.Second
+	DefaultAdminMismatchLimit     = 100
+	// Alert defaults
+	DefaultAlertDedupWindowMS = 600000
+	DefaultAlertGroupWindowMS = 5000
+	DefaultAlertRateLimit     = 10
+	// Evidence capture defaults
+	DefaultKafkaHistorySize       = 10000
+	DefaultKafkaHistoryPerKey     = 20
+	DefaultEvidenceDumpCooldownMS = 300000
+	DefaultEvidenceConcurrency    = 4
+	// RPC transport defaults
+	DefaultRpcTimeoutMS         = 10000
+	DefaultRpcMaxRetries        = 3
+	DefaultRpcRetryBackoffMS    = 200
+	DefaultRpcBreakerThreshold  = 5
+	DefaultRpcBreakerCooldownMS = 30000
+	// Height consistent comparison defaults
+	DefaultHeightRetryCount     = 3
+	DefaultHeightPollMS         = 200
+	DefaultHeightRetryBackoffMS = 50
+	// Blocks behind the realtime height that a realtime receipt or tx is expected to still be served from the
+	// realtime cache. Missing realtime receipts and txs of older txs are treated as evicted rather than as mismatches
+	DefaultRealtimeReceiptWindow = 64
+	// Lag monitor defaults
+	DefaultLagHistorySize = 300
+	// Metrics node label of the cross node fleet comparisons
+	FleetMetricsNode = "fleet"
+	// Websocket subscription defaults
+	DefaultWsReconnectMS = 1000
+	// Blocks past the notification height the eth node has to reach before a notified tx without a realtime receipt
+	// is no longer treated as pending
+	DefaultPendingTxBlocks = 20
+	// Blocks past the kafka message of a tx that the realtime transaction notification is waited for, before the
+	// tx is counted as a missed notification
+	DefaultMissedTxBlocks = 3
 )
@@ -0,0 +1,265 @@
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/sieniven/realtime-compare-tool/rpc"
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+// KafkaMessage is a kafka message received by the compare service, kept as evidence for the mismatches
+type KafkaMessage struct {
+	Timestamp time.Time `json:"timestamp"`
+	Type      string    `json:"type"`
+	// Hex of the addresses, slots, hashes and token ids the message touches
+	Keys []string    `json:"keys"`
+	Data interface{} `json:"data"`
+	// Order the message was received in
+	seq uint64
+}
+
+// KafkaMessageHistory keeps the latest kafka messages touching each key, for the least recently touched keys up to
+// the cache size. Keeping the history per key holds the messages of a mismatched item until the mismatch is
+// confirmed, regardless of the kafka message rate of the other items
+type KafkaMessageHistory struct {
+	mu             sync.Mutex
+	cache          *lru.Cache[string, []*KafkaMessage]
+	messagesPerKey int
+	seq            uint64
+}
+
+func NewKafkaMessageHistory(size int, messagesPerKey int) (*KafkaMessageHistory, error) {
+	cache, err := lru.NewWithEvict[string, []*KafkaMessage](size, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &KafkaMessageHistory{
+		cache:          cache,
+		messagesPerKey: messagesPerKey,
+	}, nil
+}
+
+// Add keeps the message in the history of each key it touches. Messages without keys are not kept, as they cannot
+// be matched to a mismatch
+func (history *KafkaMessageHistory) Add(messageType string, data interface{}, keys ...string) {
+	keys = nonEmpty(keys...)
+	if len(keys) == 0 {
+		return
+	}
+
+	history.mu.Lock()
+	defer history.mu.Unlock()
+
+	history.seq++
+	message := &KafkaMessage{
+		Timestamp: time.Now().UTC(),
+		Type:      messageType,
+		Keys:      keys,
+		Data:      data,
+		seq:       history.seq,
+	}
+	for i, key := range keys {
+		if slices.Contains(keys[:i], key) {
+			continue
+		}
+		messages, _ := history.cache.Get(key)
+		if len(messages) >= history.messagesPerKey {
+			// Drop the oldest message of the key
+			messages = messages[1:]
+		}
+		history.cache.Add(key, append(messages, message))
+	}
+}
+
+// GetMessagesTouching returns the messages touching any of the keys, in the order they were received
+func (history *KafkaMessageHistory) GetMessagesTouching(keys ...string) []KafkaMessage {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+
+	touching := make(map[uint64]*KafkaMessage)
+	for _, key := range nonEmpty(keys...) {
+		messages, _ := history.cache.Peek(key)
+		for _, message := range messages {
+			touching[message.seq] = message
+		}
+	}
+	messages := make([]KafkaMessage, 0, len(touching))
+	for _, message := range touching {
+		messages = append(messages, *message)
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].seq < messages[j].seq
+	})
+	return messages
+}
+
+// EvidenceHeights are the block heights from every source at the time of the evidence capture
+type EvidenceHeights struct {
+	KafkaHeight    int64         `json:"kafkaHeight"`
+	RealtimeHeight uint64        `json:"realtimeHeight"`
+	EthHeight      uint64        `json:"ethHeight"`
+	LatestSample   *HeightSample `json:"latestSample,omitempty"`
+}
+
+// EvidenceBalances are the balances of the mismatched address re-read while capturing the evidence
+type EvidenceBalances struct {
+	Address          string   `json:"address"`
+	Token            string   `json:"token,omitempty"`
+	EthHeight        uint64   `json:"ethHeight"`
+	RealtimeBalance  *big.Int `json:"realtimeBalance,omitempty"`
+	EthBalance       *big.Int `json:"ethBalance,omitempty"`
+	ComparedEth      string   `json:"comparedEth"`
+	ComparedRealtime string   `json:"comparedRealtime"`
+}
+
+// captureEvidence writes the evidence bundle of the confirmed mismatch into a directory named after the mismatch,
+// optionally dumping the realtime state cache first. The comparison timings are the RPC calls of the comparison that
+// found the mismatch
+func (service *CompareService) captureEvidence(ctx context.Context, mismatch store.Mismatch, comparisonTimings []rpc.CallTiming) {
+	dir := filepath.Join(service.Config.EvidenceDir, evidenceDirName(mismatch))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		service.Logger.Printf("error creating evidence directory %s: %v\n", dir, err)
+		return
+	}
+
+	// Record the RPC calls made while capturing the evidence separately from the comparison calls
+	ctx = rpc.WithCallRecorder(ctx)
+	if service.Config.EvidenceDumpStateCache && service.acquireDumpCooldown() {
+		if err := service.RpcClient.RealtimeDumpStateCache(ctx); err != nil {
+			service.Logger.Printf("error dumping realtime state cache: %v\n", err)
+		} else {
+			service.Logger.Printf("Realtime state cache dumped for %s mismatch at height %d\n", mismatch.Comparator, mismatch.Height)
+		}
+	}
+
+	heights := EvidenceHeights{
+		KafkaHeight: service.NodeHeight.Load(),
+	}
+	// The read errors are kept in the evidence rpc calls
+	heights.RealtimeHeight, _ = service.RpcClient.RealtimeBlockNumber(ctx)
+	heights.EthHeight, _ = service.RpcClient.EthGetBlockNumber(ctx)
+	if sample, ok := service.lagHistory.Latest(); ok {
+		heights.LatestSample = &sample
+	}
+
+	files := map[string]interface{}{
+		"mismatch.json":             mismatch,
+		"heights.json":              heights,
+		"kafka_messages.json":       service.kafkaHistory.GetMessagesTouching(mismatch.Address, mismatch.Token, mismatch.Key),
+		"comparison_rpc_calls.json": comparisonTimings,
+	}
+	if isBalanceComparator(mismatch.Comparator) && mismatch.Address != "" {
+		files["balances.json"] = service.readEvidenceBalances(ctx, mismatch, heights.EthHeight)
+	}
+	files["evidence_rpc_calls.json"] = rpc.CallTimings(ctx)
+
+	for name, value := range files {
+		if err := writeJSONFile(filepath.Join(dir, name), value); err != nil {
+			service.Logger.Printf("error writing evidence file %s: %v\n", name, err)
+		}
+	}
+	service.Logger.Printf("Evidence for %s mismatch at height %d captured in %s\n", mismatch.Comparator, mismatch.Height, dir)
+}
+
+// evidenceDirName names the evidence directory of the mismatch after its timestamp, comparator, height and either
+// its mismatch id or, without a mismatch store, the compared item, so concurrent mismatches never share a directory
+func evidenceDirName(mismatch store.Mismatch) string {
+	item := fmt.Sprintf("%d", mismatch.ID)
+	if mismatch.ID == 0 {
+		item = strings.Join(nonEmpty(mismatch.Address, mismatch.Token, mismatch.Key), "-")
+	}
+	name := fmt.Sprintf("%s-%s-%d", mismatch.Timestamp.Format("20060102T150405.000Z"), sanitizeName(mismatch.Comparator), mismatch.Height)
+	if item != "" {
+		name = fmt.Sprintf("%s-%s", name, sanitizeName(item))
+	}
+	return name
+}
+
+func nonEmpty(values ...string) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// isBalanceComparator returns true if the mismatches of the comparator are native or token balance mismatches of
+// the mismatch address
+func isBalanceComparator(comparator string) bool {
+	switch comparator {
+	case "balance", "token balance", "balance notification", "fleet balance", "fleet token balance":
+		return true
+	}
+	return false
+}
+
+// readEvidenceBalances re-reads the realtime balance and the eth balance at the eth height of the mismatched
+// address, the token balance for the token balance mismatches
+func (service *CompareService) readEvidenceBalances(ctx context.Context, mismatch store.Mismatch, ethHeight uint64) EvidenceBalances {
+	address := common.HexToAddress(mismatch.Address)
+	balances := EvidenceBalances{
+		Address:          mismatch.Address,
+		Token:            mismatch.Token,
+		EthHeight:        ethHeight,
+		ComparedEth:      mismatch.Eth,
+		ComparedRealtime: mismatch.Realtime,
+	}
+	if mismatch.Token != "" {
+		token := common.HexToAddress(mismatch.Token)
+		balances.RealtimeBalance, _ = service.RpcClient.RealtimeGetTokenBalance(ctx, address, token)
+		balances.EthBalance, _ = service.RpcClient.EthGetTokenBalance(ctx, address, token, new(big.Int).SetUint64(ethHeight))
+		return balances
+	}
+	balances.RealtimeBalance, _ = service.RpcClient.RealtimeGetBalance(ctx, address)
+	balances.EthBalance, _ = service.RpcClient.EthGetBalance(ctx, address, rpc.BlockNumberToHex(ethHeight))
+	return balances
+}
+
+// acquireDumpCooldown returns true if the realtime state cache was not dumped within the cool-down, and starts a
+// new cool-down
+func (service *CompareService) acquireDumpCooldown() bool {
+	cooldown := time.Duration(service.Config.EvidenceDumpCooldownMS) * time.Millisecond
+	for {
+		last := service.lastStateCacheDump.Load()
+		now := time.Now().UnixNano()
+		if last != 0 && now-last < int64(cooldown) {
+			return false
+		}
+		if service.lastStateCacheDump.CompareAndSwap(last, now) {
+			return true
+		}
+	}
+}
+
+func writeJSONFile(path string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// sanitizeName replaces the characters of the name that are not safe in a file name
+func sanitizeName(name string) string {
+	sanitized := []rune(name)
+	for i, char := range sanitized {
+		if !(char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char >= '0' && char <= '9' || char == '-') {
+			sanitized[i] = '_'
+		}
+	}
+	return string(sanitized)
+}
@@ -0,0 +1,96 @@
+package compare
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"balance", "balance"},
+		{"token balance", "token_balance"},
+		{"erc721-owner", "erc721-owner"},
+		{"../etc/passwd", "___etc_passwd"},
+		{"0xAbC", "0xAbC"},
+		{"tokenId=1:owner", "tokenId_1_owner"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := sanitizeName(test.name); got != test.want {
+			t.Errorf("sanitizeName(%q): got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestEvidenceDirName(t *testing.T) {
+	timestamp := time.Date(2025, 1, 2, 3, 4, 5, 6000000, time.UTC)
+	tests := []struct {
+		name     string
+		mismatch store.Mismatch
+		want     string
+	}{
+		{
+			name:     "mismatch id",
+			mismatch: store.Mismatch{ID: 7, Comparator: "balance", Address: "0x01", Height: 10, Timestamp: timestamp},
+			want:     "20250102T030405.006Z-balance-10-7",
+		},
+		{
+			name:     "address and token without a store",
+			mismatch: store.Mismatch{Comparator: "token balance", Address: "0x01", Token: "0x02", Height: 10, Timestamp: timestamp},
+			want:     "20250102T030405.006Z-token_balance-10-0x01-0x02",
+		},
+		{
+			name:     "key without a store",
+			mismatch: store.Mismatch{Comparator: "receipt", Key: "0x03", Height: 10, Timestamp: timestamp},
+			want:     "20250102T030405.006Z-receipt-10-0x03",
+		},
+		{
+			name:     "height only without a store",
+			mismatch: store.Mismatch{Comparator: "block", Height: 10, Timestamp: timestamp},
+			want:     "20250102T030405.006Z-block-10",
+		},
+	}
+	for _, test := range tests {
+		if got := evidenceDirName(test.mismatch); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestKafkaMessageHistory(t *testing.T) {
+	history, err := NewKafkaMessageHistory(4, 2)
+	if err != nil {
+		t.Fatalf("create kafka message history: %v", err)
+	}
+	history.Add("address", 1, "0x01")
+	history.Add("tokenHolder", 2, "0x01", "0x02")
+	// Messages of other keys do not evict the history of a key
+	for i := 0; i < 10; i++ {
+		history.Add("address", i, "0x03")
+	}
+	history.Add("block", 3)
+
+	messages := history.GetMessagesTouching("0x01", "0x02", "")
+	if len(messages) != 2 || messages[0].Data != 1 || messages[1].Data != 2 {
+		t.Fatalf("got messages %+v, want the address and token holder messages once each in order", messages)
+	}
+	if messages := history.GetMessagesTouching("0x03"); len(messages) != 2 || messages[0].Data != 8 || messages[1].Data != 9 {
+		t.Errorf("got messages %+v, want the latest 2 messages of the key", messages)
+	}
+	if messages := history.GetMessagesTouching(""); len(messages) != 0 {
+		t.Errorf("got messages %+v without keys, want none", messages)
+	}
+
+	// The least recently touched keys are evicted beyond the history size
+	history.Add("address", 4, "0x04")
+	history.Add("address", 5, "0x05")
+	history.Add("address", 6, "0x06")
+	if messages := history.GetMessagesTouching("0x01"); len(messages) != 0 {
+		t.Errorf("got messages %+v of an evicted key, want none", messages)
+	}
+}
@@ -36,17 +36,93 @@ var (
 		Usage: "RPC url",
 		Value: "",
 	}
+	RealtimeRpcUrl = cli.StringFlag{
+		Name:  "rpc.realtime-url",
+		Usage: "Comma separated realtime node RPC urls serving the realtime_* calls, defaults to rpc.url",
+		Value: "",
+	}
+	ReferenceRpcUrl = cli.StringFlag{
+		Name:  "rpc.reference-url",
+		Usage: "Reference node RPC url serving the eth_* calls, defaults to rpc.url",
+		Value: "",
+	}
+	RpcBatchSize = cli.IntFlag{
+		Name:  "rpc.batch-size",
+		Usage: "Maximum number of requests packed into a single JSON-RPC batch call",
+		Value: DefaultBatchSize,
+	}
+	RpcEndpointConcurrency = cli.IntFlag{
+		Name:  "rpc.endpoint-concurrency",
+		Usage: "Maximum number of concurrent requests in flight to each RPC endpoint, 0 for no limit",
+		Value: DefaultEndpointConcurrency,
+	}
+	RpcTimeoutMS = cli.IntFlag{
+		Name:  "rpc.timeout-ms",
+		Usage: "Deadline of each RPC request attempt in milliseconds",
+		Value: DefaultRpcTimeoutMS,
+	}
+	RpcMaxRetries = cli.IntFlag{
+		Name:  "rpc.max-retries",
+		Usage: "Retries of an RPC request after a transient error",
+		Value: DefaultRpcMaxRetries,
+	}
+	RpcRetryBackoffMS = cli.IntFlag{
+		Name:  "rpc.retry-backoff-ms",
+		Usage: "Initial RPC retry backoff in milliseconds, doubled after every retry",
+		Value: DefaultRpcRetryBackoffMS,
+	}
+	RpcBreakerThreshold = cli.IntFlag{
+		Name:  "rpc.breaker-threshold",
+		Usage: "Consecutive transient RPC errors that mark an endpoint as down and pause comparisons, 0 to disable",
+		Value: DefaultRpcBreakerThreshold,
+	}
+	RpcBreakerCooldownMS = cli.IntFlag{
+		Name:  "rpc.breaker-cooldown-ms",
+		Usage: "Time in milliseconds before a down endpoint is probed again",
+		Value: DefaultRpcBreakerCooldownMS,
+	}
 	WsUrl = cli.StringFlag{
 		Name:  "ws.url",
-		Usage: "WS url",
+		Usage: "WS url of the realtime node, not supported with multiple realtime rpc urls",
 		Value: "",
 	}
+	WsHeightSource = cli.BoolFlag{
+		Name:  "ws.height-source",
+		Usage: "Drive height tracking from the ws.url newHeads subscription instead of cross-checking it against the kafka block messages",
+		Value: false,
+	}
+	// Realtime subscription compare flags
+	SubscriptionCompare = cli.BoolFlag{
+		Name:  "subscription.compare",
+		Usage: "Compare the realtime ws.url subscription notifications against the realtime RPC reads and the canonical chain",
+		Value: false,
+	}
+	SubscribeMethod = cli.StringFlag{
+		Name:  "subscription.method",
+		Usage: "Subscribe method of the realtime subscriptions",
+		Value: "eth_subscribe",
+	}
+	TxSubscription = cli.StringFlag{
+		Name:  "subscription.tx",
+		Usage: "Realtime pending and confirmed transaction subscription name",
+		Value: "realtimeTransactions",
+	}
+	BalanceSubscription = cli.StringFlag{
+		Name:  "subscription.balance",
+		Usage: "Realtime balance change subscription name",
+		Value: "realtimeBalances",
+	}
 	// Compare flags
 	MismatchCount = cli.IntFlag{
 		Name:  "compare.mismatch-count",
 		Usage: "Mismatch count",
 		Value: 0,
 	}
+	CompareWorkers = cli.IntFlag{
+		Name:  "compare.workers",
+		Usage: "Number of comparison jobs processed concurrently",
+		Value: DefaultWorkerCount,
+	}
 	CompareIntervalMS = cli.IntFlag{
 		Name:  "compare.interval-ms",
 		Usage: "Compare time interval in milliseconds",
@@ -57,6 +133,108 @@ var (
 		Usage: "Skip addresses",
 		Value: "",
 	}
+	HeightConsistent = cli.BoolFlag{
+		Name:  "compare.height-consistent",
+		Usage: "Pin the eth and realtime reads of each comparison to the same block height",
+		Value: false,
+	}
+	BlockConfirmations = cli.IntFlag{
+		Name:  "compare.block-confirmations",
+		Usage: "Blocks built on top of a block on the eth node before the block and logs comparators compare it",
+		Value: DefaultBlockConfirmations,
+	}
+	MismatchStorePath = cli.StringFlag{
+		Name:  "compare.mismatch-store",
+		Usage: "Path of the database file recording the confirmed mismatches, empty to disable",
+		Value: DefaultMismatchStorePath,
+	}
+	// Metrics flags
+	MetricsAddr = cli.StringFlag{
+		Name:  "metrics.addr",
+		Usage: "Listen address of the prometheus /metrics endpoint, empty to disable",
+		Value: DefaultMetricsAddr,
+	}
+	// Admin api flags
+	AdminAddr = cli.StringFlag{
+		Name:  "admin.addr",
+		Usage: "Listen address of the admin and status http api, empty to disable",
+		Value: "",
+	}
+	// Alert flags
+	AlertWebhookUrl = cli.StringFlag{
+		Name:  "alert.webhook-url",
+		Usage: "Webhook url receiving the confirmed mismatch alerts as json, empty to disable",
+		Value: "",
+	}
+	AlertChatWebhookUrl = cli.StringFlag{
+		Name:  "alert.chat-webhook-url",
+		Usage: "Chat incoming webhook url receiving the confirmed mismatch alerts as chat messages, empty to disable",
+		Value: "",
+	}
+	AlertDedupWindowMS = cli.IntFlag{
+		Name:  "alert.dedup-window-ms",
+		Usage: "Window in milliseconds within which repeated mismatches of the same item are alerted once",
+		Value: DefaultAlertDedupWindowMS,
+	}
+	AlertGroupWindowMS = cli.IntFlag{
+		Name:  "alert.group-window-ms",
+		Usage: "Window in milliseconds within which mismatches at the same height are grouped in a single alert",
+		Value: DefaultAlertGroupWindowMS,
+	}
+	AlertRateLimit = cli.IntFlag{
+		Name:  "alert.rate-limit",
+		Usage: "Maximum alerts sent per minute, 0 for no limit. Alerts over the limit are sent in a summary once the rate window reopens",
+		Value: DefaultAlertRateLimit,
+	}
+	// Evidence capture flags
+	EvidenceDir = cli.StringFlag{
+		Name:  "evidence.dir",
+		Usage: "Directory to capture the evidence bundle of each confirmed mismatch into, empty to disable",
+		Value: "",
+	}
+	EvidenceDumpStateCache = cli.BoolFlag{
+		Name:  "evidence.dump-state-cache",
+		Usage: "Trigger realtime_dumpStateCache on the realtime node when capturing the mismatch evidence",
+		Value: false,
+	}
+	EvidenceDumpCooldownMS = cli.IntFlag{
+		Name:  "evidence.dump-cooldown-ms",
+		Usage: "Minimum time in milliseconds between realtime state cache dumps",
+		Value: DefaultEvidenceDumpCooldownMS,
+	}
+	// Lag monitor flags
+	LagThreshold = cli.IntFlag{
+		Name:  "lag.threshold",
+		Usage: "Maximum height difference between realtime and the eth and kafka heights before comparisons are paused",
+		Value: DefaultHeightSyncRange,
+	}
+	LagIntervalMS = cli.IntFlag{
+		Name:  "lag.interval-ms",
+		Usage: "Lag monitor sampling interval in milliseconds",
+		Value: 1000,
+	}
+	// Call comparison flags
+	CallConfigFile = cli.StringFlag{
+		Name:  "call.config-file",
+		Usage: "YAML file listing the contract view calls to compare",
+		Value: "",
+	}
+	CallIntervalMS = cli.IntFlag{
+		Name:  "call.interval-ms",
+		Usage: "Periodic contract view call comparison interval in milliseconds",
+		Value: 10000,
+	}
+	// Logs comparison flags
+	LogsAddresses = cli.StringFlag{
+		Name:  "logs.addresses",
+		Usage: "Comma separated contract addresses to filter compared logs by",
+		Value: "",
+	}
+	LogsTopics = cli.StringFlag{
+		Name:  "logs.topics",
+		Usage: "Comma separated first topics to filter compared logs by",
+		Value: "",
+	}
 )
 
 var DefaultFlags = []cli.Flag{
@@ -66,8 +244,42 @@ var DefaultFlags = []cli.Flag{
 	&KafkaNonStateTopic,
 	&KafkaClientID,
 	&RpcUrl,
+	&RealtimeRpcUrl,
+	&ReferenceRpcUrl,
+	&RpcBatchSize,
+	&RpcEndpointConcurrency,
+	&RpcTimeoutMS,
+	&RpcMaxRetries,
+	&RpcRetryBackoffMS,
+	&RpcBreakerThreshold,
+	&RpcBreakerCooldownMS,
 	&WsUrl,
+	&WsHeightSource,
+	&SubscriptionCompare,
+	&SubscribeMethod,
+	&TxSubscription,
+	&BalanceSubscription,
 	&MismatchCount,
+	&CompareWorkers,
 	&CompareIntervalMS,
 	&SkipAddresses,
+	&HeightConsistent,
+	&BlockConfirmations,
+	&MismatchStorePath,
+	&MetricsAddr,
+	&AdminAddr,
+	&AlertWebhookUrl,
+	&AlertChatWebhookUrl,
+	&AlertDedupWindowMS,
+	&AlertGroupWindowMS,
+	&AlertRateLimit,
+	&EvidenceDir,
+	&EvidenceDumpStateCache,
+	&EvidenceDumpCooldownMS,
+	&LagThreshold,
+	&LagIntervalMS,
+	&CallConfigFile,
+	&CallIntervalMS,
+	&LogsAddresses,
+	&LogsTopics,
 }
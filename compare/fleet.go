@@ -0,0 +1,546 @@
+package compare
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/sieniven/realtime-compare-tool/kafka"
+	"github.com/sieniven/realtime-compare-tool/metrics"
+	"github.com/sieniven/realtime-compare-tool/rpc"
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+// FleetService runs a compare service for each realtime node of the fleet against the reference node, fanning out
+// the kafka messages to every node, and compares the realtime nodes against each other to identify a bad replica
+type FleetService struct {
+	Config CompareConfig
+
+	KafkaConsumer *kafka.KafkaConsumer
+	Nodes         []*CompareService
+	Logger        *log.Logger
+
+	// Cross node compare caches
+	balanceCache      *CompareBalanceCache
+	tokenBalanceCache *CompareAddrTokenCache
+	nonceCache        *CompareCountCache[common.Address]
+
+	// Comparison job workers, shared with the nodes
+	workerPool *WorkerPool
+
+	// Nodes excluded from the cross node comparisons, as last reported
+	excludedMu    sync.Mutex
+	excludedNodes string
+
+	// Channels
+	HeightChan      chan int64
+	AddrBalanceChan chan common.Address
+	TokenHolderChan chan kafka.TokenHolderData
+	ContractChan    chan common.Address
+	StorageChan     chan kafka.StorageData
+	TxHashChan      chan common.Hash
+	ApprovalChan    chan kafka.ApprovalData
+	NftTransferChan chan kafka.NftTransferData
+	ErrorChan       chan error
+}
+
+func NewFleetService(config CompareConfig, logger *log.Logger) (*FleetService, error) {
+	// The ws subscriptions drive the height tracking and subscription comparisons of a single node
+	if config.Rpc.WsUrl != "" {
+		return nil, ErrFleetWsUnsupported
+	}
+	kafkaConsumer, err := kafka.NewKafkaConsumer(config.Kafka)
+	if err != nil {
+		return nil, err
+	}
+	balanceCache, err := NewCompareBalanceCache()
+	if err != nil {
+		return nil, err
+	}
+	tokenBalanceCache, err := NewCompareAddrTokenCache()
+	if err != nil {
+		return nil, err
+	}
+	nonceCache, err := NewCompareCountCache[common.Address]()
+	if err != nil {
+		return nil, err
+	}
+
+	// The reference endpoint is shared by all nodes, so the transport is shared across the fleet, and the worker pool
+	// bounds the comparisons in flight across all nodes
+	transport := newTransport(config, logger)
+	workerPool := NewWorkerPool(config.CompareWorkers)
+	mismatchStore, err := newMismatchStore(config)
+	if err != nil {
+		return nil, err
+	}
+	alerter := newAlerter(config, logger)
+	nodes := make([]*CompareService, 0, len(config.Rpc.RealtimeUrls))
+	for _, realtimeUrl := range config.Rpc.RealtimeUrls {
+		// Prefix the node logs with the realtime url to report the per node results
+		nodeLogger := log.New(logger.Writer(), fmt.Sprintf("[%s] ", realtimeUrl), logger.Flags())
+		node, err := newCompareService(config, realtimeUrl, nil, transport, workerPool, mismatchStore, alerter, nodeLogger)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+
+	return &FleetService{
+		Config:            config,
+		KafkaConsumer:     kafkaConsumer,
+		Nodes:             nodes,
+		Logger:            logger,
+		balanceCache:      balanceCache,
+		tokenBalanceCache: tokenBalanceCache,
+		nonceCache:        nonceCache,
+		workerPool:        workerPool,
+		HeightChan:        make(chan int64, DefaultChannelSize),
+		AddrBalanceChan:   make(chan common.Address, DefaultChannelSize),
+		TokenHolderChan:   make(chan kafka.TokenHolderData, DefaultChannelSize),
+		ContractChan:      make(chan common.Address, DefaultChannelSize),
+		StorageChan:       make(chan kafka.StorageData, DefaultChannelSize),
+		TxHashChan:        make(chan common.Hash, DefaultChannelSize),
+		ApprovalChan:      make(chan kafka.ApprovalData, DefaultChannelSize),
+		NftTransferChan:   make(chan kafka.NftTransferData, DefaultChannelSize),
+		ErrorChan:         make(chan error, DefaultChannelSize),
+	}, nil
+}
+
+func (fleet *FleetService) Start(ctx context.Context) error {
+	// Start the kafka consumer goroutine
+	go fleet.KafkaConsumer.ConsumeKafka(ctx, fleet.HeightChan, fleet.AddrBalanceChan, fleet.TokenHolderChan, fleet.ContractChan, fleet.StorageChan, fleet.TxHashChan, fleet.ApprovalChan, fleet.NftTransferChan, fleet.ErrorChan, fleet.Logger)
+	if fleet.Config.AdminAddr != "" {
+		NewAdminServer(fleet.Nodes, fleet.Logger).Start(ctx, fleet.Config.AdminAddr)
+	}
+	// The alerter is shared by all nodes
+	if alerter := fleet.Nodes[0].alerter; alerter != nil {
+		go alerter.Run(ctx)
+	}
+	for _, node := range fleet.Nodes {
+		go func(node *CompareService) {
+			if err := node.run(ctx); err != nil && !errors.Is(err, ErrCtxCancelled) {
+				node.Logger.Printf("compare service stopped: %v\n", err)
+			}
+		}(node)
+	}
+	go fleet.ProcessCompareNodeBalanceCache(ctx)
+	go fleet.ProcessCompareNodeTokenBalanceCache(ctx)
+	go fleet.ProcessCompareNodeNonceCache(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ErrCtxCancelled
+		case height := <-fleet.HeightChan:
+			for _, node := range fleet.Nodes {
+				if !sendToNode(ctx, node.HeightChan, height) {
+					return ErrCtxCancelled
+				}
+			}
+		case address := <-fleet.AddrBalanceChan:
+			for _, node := range fleet.Nodes {
+				if !sendToNode(ctx, node.AddrBalanceChan, address) {
+					return ErrCtxCancelled
+				}
+			}
+			if len(fleet.readyNodes()) >= 2 && !fleet.Nodes[0].isSkipAddress(address) {
+				fleet.balanceCache.Add(address)
+				fleet.nonceCache.Add(address)
+			}
+		case tokenHolder := <-fleet.TokenHolderChan:
+			for _, node := range fleet.Nodes {
+				if !sendToNode(ctx, node.TokenHolderChan, tokenHolder) {
+					return ErrCtxCancelled
+				}
+			}
+			if len(fleet.readyNodes()) >= 2 && !fleet.Nodes[0].isSkipAddress(tokenHolder.Address) {
+				fleet.tokenBalanceCache.Add(tokenHolder.TokenAddress, tokenHolder.Address)
+			}
+		case address := <-fleet.ContractChan:
+			for _, node := range fleet.Nodes {
+				if !sendToNode(ctx, node.ContractChan, address) {
+					return ErrCtxCancelled
+				}
+			}
+		case storage := <-fleet.StorageChan:
+			for _, node := range fleet.Nodes {
+				if !sendToNode(ctx, node.StorageChan, storage) {
+					return ErrCtxCancelled
+				}
+			}
+		case txHash := <-fleet.TxHashChan:
+			for _, node := range fleet.Nodes {
+				if !sendToNode(ctx, node.TxHashChan, txHash) {
+					return ErrCtxCancelled
+				}
+			}
+		case approval := <-fleet.ApprovalChan:
+			for _, node := range fleet.Nodes {
+				if !sendToNode(ctx, node.ApprovalChan, approval) {
+					return ErrCtxCancelled
+				}
+			}
+		case transfer := <-fleet.NftTransferChan:
+			for _, node := range fleet.Nodes {
+				if !sendToNode(ctx, node.NftTransferChan, transfer) {
+					return ErrCtxCancelled
+				}
+			}
+		case err := <-fleet.ErrorChan:
+			return err
+		}
+	}
+}
+
+// ProcessCompareNodeBalanceCache compares the native balances across the ready realtime nodes of the fleet,
+// reporting each node that disagrees with the majority of the ready nodes
+func (fleet *FleetService) ProcessCompareNodeBalanceCache(ctx context.Context) {
+	for {
+		nodes, ok := fleet.waitUntilNodesReady(ctx)
+		if !ok {
+			return
+		}
+
+		addresses := fleet.balanceCache.GetAddresses()
+		fleet.workerPool.Run(ctx, len(addresses), func(ctx context.Context, i int) {
+			address := addresses[i]
+			// Run the cross node native balance comparison
+			values, height, err := fleet.readNodeValues(ctx, nodes, func(node *CompareService) (string, uint64, error) {
+				balance, height, err := node.RpcClient.RealtimeGetBalanceAtHeight(ctx, address)
+				if err != nil {
+					return "", 0, err
+				}
+				return balance.String(), height, nil
+			})
+			if err != nil {
+				fleet.Logger.Printf("error getting node balances for address %s: %v\n", address, err)
+				return
+			}
+			result := fleet.compareNodeValues(ctx, nodeComparison{
+				comparator: "fleet balance",
+				address:    address,
+				height:     height,
+				nodes:      nodes,
+				values:     values,
+				count:      fleet.balanceCache.GetCount(address),
+			})
+			if result == metrics.ResultTransientMismatch {
+				fleet.balanceCache.AddWithCount(address, fleet.balanceCache.GetCount(address)+1)
+			} else {
+				fleet.balanceCache.Remove(address)
+			}
+		})
+
+		time.Sleep(time.Duration(fleet.Config.CompareIntervalMS) * time.Millisecond)
+	}
+}
+
+// ProcessCompareNodeTokenBalanceCache compares the erc20 token balances across the ready realtime nodes of the fleet,
+// reporting each node that disagrees with the majority of the ready nodes
+func (fleet *FleetService) ProcessCompareNodeTokenBalanceCache(ctx context.Context) {
+	for {
+		nodes, ok := fleet.waitUntilNodesReady(ctx)
+		if !ok {
+			return
+		}
+
+		tokenAddresses := fleet.tokenBalanceCache.GetTokenAddresses()
+		addresses := make([]common.Address, 0)
+		addressTokens := make([]common.Address, 0)
+		for _, tokenAddress := range tokenAddresses {
+			for _, address := range fleet.tokenBalanceCache.GetAddressesFromTokenAddress(tokenAddress) {
+				addresses = append(addresses, address)
+				addressTokens = append(addressTokens, tokenAddress)
+			}
+		}
+		fleet.workerPool.Run(ctx, len(addresses), func(ctx context.Context, i int) {
+			address, tokenAddress := addresses[i], addressTokens[i]
+			// Run the cross node token balance comparison
+			values, height, err := fleet.readNodeValues(ctx, nodes, func(node *CompareService) (string, uint64, error) {
+				balance, height, err := node.RpcClient.RealtimeGetTokenBalanceAtHeight(ctx, address, tokenAddress)
+				if err != nil {
+					return "", 0, err
+				}
+				return balance.String(), height, nil
+			})
+			if err != nil {
+				fleet.Logger.Printf("error getting node token balances for address %s and token address %s: %v\n", address, tokenAddress, err)
+				return
+			}
+			result := fleet.compareNodeValues(ctx, nodeComparison{
+				comparator: "fleet token balance",
+				address:    address,
+				token:      &tokenAddress,
+				height:     height,
+				nodes:      nodes,
+				values:     values,
+				count:      fleet.tokenBalanceCache.GetCount(tokenAddress, address),
+			})
+			if result == metrics.ResultTransientMismatch {
+				fleet.tokenBalanceCache.AddWithCount(tokenAddress, address, fleet.tokenBalanceCache.GetCount(tokenAddress, address)+1)
+			} else {
+				fleet.tokenBalanceCache.Remove(tokenAddress, address)
+			}
+		})
+
+		time.Sleep(time.Duration(fleet.Config.CompareIntervalMS) * time.Millisecond)
+	}
+}
+
+// ProcessCompareNodeNonceCache compares the nonces across the ready realtime nodes of the fleet, reporting each node
+// that disagrees with the majority of the ready nodes
+func (fleet *FleetService) ProcessCompareNodeNonceCache(ctx context.Context) {
+	for {
+		nodes, ok := fleet.waitUntilNodesReady(ctx)
+		if !ok {
+			return
+		}
+
+		addresses := fleet.nonceCache.GetKeys()
+		fleet.workerPool.Run(ctx, len(addresses), func(ctx context.Context, i int) {
+			address := addresses[i]
+			// Run the cross node nonce comparison
+			values, height, err := fleet.readNodeValues(ctx, nodes, func(node *CompareService) (string, uint64, error) {
+				nonce, height, err := node.RpcClient.RealtimeGetTransactionCountAtHeight(ctx, address)
+				if err != nil {
+					return "", 0, err
+				}
+				return fmt.Sprintf("%d", nonce), height, nil
+			})
+			if err != nil {
+				fleet.Logger.Printf("error getting node nonces for address %s: %v\n", address, err)
+				return
+			}
+			result := fleet.compareNodeValues(ctx, nodeComparison{
+				comparator: "fleet nonce",
+				address:    address,
+				height:     height,
+				nodes:      nodes,
+				values:     values,
+				count:      fleet.nonceCache.GetCount(address),
+			})
+			if result == metrics.ResultTransientMismatch {
+				fleet.nonceCache.AddWithCount(address, fleet.nonceCache.GetCount(address)+1)
+			} else {
+				fleet.nonceCache.Remove(address)
+			}
+		})
+
+		time.Sleep(time.Duration(fleet.Config.CompareIntervalMS) * time.Millisecond)
+	}
+}
+
+// nodeComparison is the value of an item read on each ready node at the same realtime height, in the order of the
+// ready nodes
+type nodeComparison struct {
+	comparator string
+	address    common.Address
+	// Token address of the token balance comparisons
+	token  *common.Address
+	height uint64
+	nodes  []int
+	values []string
+	// Consecutive mismatches of the item so far
+	count int
+}
+
+// compareNodeValues groups the ready nodes by their value, and once the mismatch is confirmed logs and records each
+// node outside the group holding the majority value. If no value is held by a majority of the nodes, a single split
+// mismatch listing the value of every node is recorded instead. Returns the result of the comparison
+func (fleet *FleetService) compareNodeValues(ctx context.Context, comparison nodeComparison) string {
+	groups := groupNodeValues(comparison.values)
+	item := fmt.Sprintf("address %s", comparison.address)
+	token := ""
+	if comparison.token != nil {
+		item = fmt.Sprintf("address %s and token address %s", comparison.address, comparison.token)
+		token = comparison.token.Hex()
+	}
+
+	if len(groups) == 1 {
+		metrics.ObserveComparison(FleetMetricsNode, comparison.comparator, metrics.ResultMatch)
+		fleet.Logger.Printf("Values are equal across nodes at height %d for %s comparison of %s\n", comparison.height, comparison.comparator, item)
+		return metrics.ResultMatch
+	}
+	if comparison.count <= fleet.Config.MismatchCount {
+		metrics.ObserveComparison(FleetMetricsNode, comparison.comparator, metrics.ResultTransientMismatch)
+		return metrics.ResultTransientMismatch
+	}
+
+	metrics.ObserveComparison(FleetMetricsNode, comparison.comparator, metrics.ResultConfirmedMismatch)
+	majority := groups[0]
+	if 2*len(majority) <= len(comparison.values) {
+		// No majority, so the bad replica cannot be identified
+		nodeValues := make([]string, 0, len(comparison.values))
+		for i, node := range comparison.nodes {
+			nodeValues = append(nodeValues, fmt.Sprintf("node %s: %s", fleet.Config.Rpc.RealtimeUrls[node], comparison.values[i]))
+		}
+		diffs := fmt.Sprintf("no majority across nodes, %s", strings.Join(nodeValues, ", "))
+		fleet.Logger.Printf("Error in fleet comparator: cross node %s mismatch at height %d for %s, %s\n", comparison.comparator, comparison.height, item, diffs)
+		fleet.Nodes[comparison.nodes[0]].recordMismatch(ctx, store.Mismatch{
+			Comparator: comparison.comparator,
+			Address:    comparison.address.Hex(),
+			Token:      token,
+			Height:     comparison.height,
+			Diffs:      diffs,
+			Attempts:   comparison.count + 1,
+		})
+		return metrics.ResultConfirmedMismatch
+	}
+
+	majorityValue := comparison.values[majority[0]]
+	majorityUrls := make([]string, 0, len(majority))
+	for _, i := range majority {
+		majorityUrls = append(majorityUrls, fleet.Config.Rpc.RealtimeUrls[comparison.nodes[i]])
+	}
+	diffs := fmt.Sprintf("majority of nodes %s: %s", strings.Join(majorityUrls, ", "), majorityValue)
+	for _, group := range groups[1:] {
+		for _, i := range group {
+			node := comparison.nodes[i]
+			fleet.Logger.Printf("Error in fleet comparator: cross node %s mismatch at height %d for %s, %s, node %s: %s\n", comparison.comparator, comparison.height, item, diffs, fleet.Config.Rpc.RealtimeUrls[node], comparison.values[i])
+			fleet.Nodes[node].recordMismatch(ctx, store.Mismatch{
+				Comparator: comparison.comparator,
+				Address:    comparison.address.Hex(),
+				Token:      token,
+				Height:     comparison.height,
+				Realtime:   comparison.values[i],
+				Diffs:      diffs,
+				Attempts:   comparison.count + 1,
+			})
+		}
+	}
+	return metrics.ResultConfirmedMismatch
+}
+
+// groupNodeValues groups the indexes of the values by value, largest group first. Groups of the same size are
+// ordered by their first index
+func groupNodeValues(values []string) [][]int {
+	groups := make([][]int, 0)
+	groupIndexes := make(map[string]int)
+	for i, value := range values {
+		group, ok := groupIndexes[value]
+		if !ok {
+			group = len(groups)
+			groupIndexes[value] = group
+			groups = append(groups, nil)
+		}
+		groups[group] = append(groups[group], i)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i]) > len(groups[j])
+	})
+	return groups
+}
+
+// readNodeValues reads the value on each of the ready nodes, in the order of the ready nodes, along with the
+// realtime height the values were read at. The read is retried with a short backoff while the nodes serve it at
+// different heights, as the values are then not comparable, and ErrNodeHeightsDiffer is returned if the nodes are
+// still not aligned after the retries
+func (fleet *FleetService) readNodeValues(ctx context.Context, nodes []int, read func(node *CompareService) (string, uint64, error)) ([]string, uint64, error) {
+	var values []string
+	var height uint64
+	var err error
+	for i := 0; i < DefaultHeightRetryCount; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, 0, ErrCtxCancelled
+			case <-time.After(time.Duration(i*DefaultHeightRetryBackoffMS) * time.Millisecond):
+			}
+		}
+		values, height, err = fleet.readNodeValuesOnce(nodes, read)
+		if !errors.Is(err, ErrNodeHeightsDiffer) && !errors.Is(err, rpc.ErrRealtimeHeightChanged) {
+			break
+		}
+	}
+	return values, height, err
+}
+
+// readNodeValuesOnce reads the value on each of the ready nodes concurrently. ErrNodeHeightsDiffer is returned if
+// the nodes served the reads at different heights
+func (fleet *FleetService) readNodeValuesOnce(nodes []int, read func(node *CompareService) (string, uint64, error)) ([]string, uint64, error) {
+	values := make([]string, len(nodes))
+	heights := make([]uint64, len(nodes))
+	errs := make([]error, len(nodes))
+	var wg sync.WaitGroup
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node int) {
+			defer wg.Done()
+			values[i], heights[i], errs[i] = read(fleet.Nodes[node])
+		}(i, node)
+	}
+	wg.Wait()
+
+	for i, node := range nodes {
+		if errs[i] != nil {
+			return nil, 0, fmt.Errorf("node %s: %w", fleet.Config.Rpc.RealtimeUrls[node], errs[i])
+		}
+	}
+	for i, node := range nodes {
+		if heights[i] != heights[0] {
+			return nil, 0, fmt.Errorf("%w: node %s at height %d, node %s at height %d", ErrNodeHeightsDiffer, fleet.Config.Rpc.RealtimeUrls[nodes[0]], heights[0], fleet.Config.Rpc.RealtimeUrls[node], heights[i])
+		}
+	}
+	return values, heights[0], nil
+}
+
+// waitUntilNodesReady blocks until at least two realtime nodes are ready for the cross node comparisons, and
+// returns the indexes of the ready nodes. Returns false if the context is cancelled
+func (fleet *FleetService) waitUntilNodesReady(ctx context.Context) ([]int, bool) {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, false
+		default:
+		}
+		nodes := fleet.readyNodes()
+		if len(nodes) >= 2 {
+			return nodes, true
+		}
+		time.Sleep(time.Duration(fleet.Config.CompareIntervalMS) * time.Millisecond)
+	}
+}
+
+// readyNodes returns the indexes of the realtime nodes that are initialized, in sync and reachable, and reports
+// when the set of nodes excluded from the cross node comparisons changes
+func (fleet *FleetService) readyNodes() []int {
+	nodes := make([]int, 0, len(fleet.Nodes))
+	excluded := make([]string, 0)
+	for i, node := range fleet.Nodes {
+		if node.InitFlag.Load() && node.isComparing() {
+			nodes = append(nodes, i)
+		} else {
+			excluded = append(excluded, fleet.Config.Rpc.RealtimeUrls[i])
+		}
+	}
+
+	excludedNodes := strings.Join(excluded, ", ")
+	fleet.excludedMu.Lock()
+	defer fleet.excludedMu.Unlock()
+	if excludedNodes != fleet.excludedNodes {
+		fleet.excludedNodes = excludedNodes
+		if excludedNodes == "" {
+			fleet.Logger.Printf("All nodes are ready, cross node comparisons include every node\n")
+		} else {
+			fleet.Logger.Printf("Cross node comparisons exclude the nodes that are not ready: %s\n", excludedNodes)
+		}
+	}
+	return nodes
+}
+
+// sendToNode sends the kafka message to the node channel, returning false if the context is cancelled
+func sendToNode[T any](ctx context.Context, nodeChan chan T, value T) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case nodeChan <- value:
+		return true
+	}
+}
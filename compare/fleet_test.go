@@ -0,0 +1,151 @@
+package compare
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+)
+
+// testNode is the realtime height and nonce served by a fleet node stand-in
+type testNode struct {
+	height string
+	nonce  string
+}
+
+// newTestFleet creates a fleet of ready nodes against the rpc stand-ins, keeping the fleet logged lines and counting
+// the nonce reads across the fleet
+func newTestFleet(t *testing.T, testNodes []testNode, nonceReads *atomic.Int32) (*FleetService, *testLog) {
+	nodes := make([]*CompareService, 0, len(testNodes))
+	urls := make([]string, 0, len(testNodes))
+	for _, testNode := range testNodes {
+		testNode := testNode
+		node, _ := newTestService(t, func(method string, params []json.RawMessage) interface{} {
+			switch method {
+			case "realtime_blockNumber":
+				return testNode.height
+			case "realtime_getTransactionCount":
+				nonceReads.Add(1)
+				return testNode.nonce
+			}
+			return nil
+		})
+		node.InitFlag.Store(true)
+		nodes = append(nodes, node)
+		urls = append(urls, node.Config.Rpc.RpcUrl)
+	}
+	nonceCache, err := NewCompareCountCache[common.Address]()
+	if err != nil {
+		t.Fatalf("create nonce cache: %v", err)
+	}
+	logs := &testLog{}
+	fleet := &FleetService{
+		Config:     CompareConfig{Rpc: RpcConfig{RealtimeUrls: urls}, CompareIntervalMS: 5},
+		Nodes:      nodes,
+		Logger:     log.New(logs, "", 0),
+		nonceCache: nonceCache,
+		workerPool: NewWorkerPool(2),
+	}
+	return fleet, logs
+}
+
+func TestFleetNonceRound(t *testing.T) {
+	address := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+
+	t.Run("equal nonces", func(t *testing.T) {
+		var nonceReads atomic.Int32
+		fleet, logs := newTestFleet(t, []testNode{{"0xa", "0x1"}, {"0xa", "0x1"}}, &nonceReads)
+		fleet.nonceCache.Add(address)
+
+		runComparisons(t, fleet.ProcessCompareNodeNonceCache, func() bool {
+			return fleet.nonceCache.Size() == 0
+		})
+		if mismatches := logs.find("fleet nonce mismatch"); len(mismatches) != 0 {
+			t.Errorf("got mismatches %q, want none", mismatches)
+		}
+	})
+
+	t.Run("mismatch reported on the node outside the majority", func(t *testing.T) {
+		var nonceReads atomic.Int32
+		fleet, logs := newTestFleet(t, []testNode{{"0xa", "0x2"}, {"0xa", "0x1"}, {"0xa", "0x1"}}, &nonceReads)
+		fleet.Nodes[0].NodeHeight.Store(99)
+		fleet.nonceCache.Add(address)
+
+		runComparisons(t, fleet.ProcessCompareNodeNonceCache, func() bool {
+			return fleet.nonceCache.Size() == 0
+		})
+		mismatches := logs.find("fleet nonce mismatch")
+		if len(mismatches) != 1 {
+			t.Fatalf("got %d mismatches, want only the node outside the majority", len(mismatches))
+		}
+		urls := fleet.Config.Rpc.RealtimeUrls
+		if want := "majority of nodes " + urls[1] + ", " + urls[2] + ": 1, node " + urls[0] + ": 2\n"; !strings.Contains(mismatches[0], "at height 10") || !strings.HasSuffix(mismatches[0], want) {
+			t.Errorf("got mismatch %q, want realtime nonce 2 of the first node against the majority read at height 10", mismatches[0])
+		}
+	})
+
+	t.Run("split without a majority", func(t *testing.T) {
+		var nonceReads atomic.Int32
+		fleet, logs := newTestFleet(t, []testNode{{"0xa", "0x1"}, {"0xa", "0x2"}}, &nonceReads)
+		fleet.nonceCache.Add(address)
+
+		runComparisons(t, fleet.ProcessCompareNodeNonceCache, func() bool {
+			return fleet.nonceCache.Size() == 0
+		})
+		mismatches := logs.find("fleet nonce mismatch")
+		if len(mismatches) != 1 {
+			t.Fatalf("got %d mismatches, want a single split mismatch", len(mismatches))
+		}
+		urls := fleet.Config.Rpc.RealtimeUrls
+		if want := "no majority across nodes, node " + urls[0] + ": 1, node " + urls[1] + ": 2\n"; !strings.HasSuffix(mismatches[0], want) {
+			t.Errorf("got mismatch %q, want the nonce of every node", mismatches[0])
+		}
+	})
+
+	t.Run("nodes at different heights are not compared", func(t *testing.T) {
+		var nonceReads atomic.Int32
+		fleet, logs := newTestFleet(t, []testNode{{"0xa", "0x1"}, {"0xb", "0x2"}}, &nonceReads)
+		fleet.nonceCache.Add(address)
+
+		runComparisons(t, fleet.ProcessCompareNodeNonceCache, func() bool {
+			return nonceReads.Load() >= 6
+		})
+		if fleet.nonceCache.Size() != 1 || fleet.nonceCache.GetCount(address) != 0 {
+			t.Errorf("got %d cached items with count %d, want the item kept without a mismatch count", fleet.nonceCache.Size(), fleet.nonceCache.GetCount(address))
+		}
+		if mismatches := logs.find("fleet nonce mismatch"); len(mismatches) != 0 {
+			t.Errorf("got mismatches %q, want none", mismatches)
+		}
+	})
+}
+
+func TestGroupNodeValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		groups [][]int
+	}{
+		{"equal", []string{"1", "1", "1"}, [][]int{{0, 1, 2}}},
+		{"majority after the first node", []string{"2", "1", "1"}, [][]int{{1, 2}, {0}}},
+		{"split", []string{"1", "2"}, [][]int{{0}, {1}}},
+		{"largest group first", []string{"1", "2", "3", "3"}, [][]int{{2, 3}, {0}, {1}}},
+	}
+	for _, test := range tests {
+		if groups := groupNodeValues(test.values); !reflect.DeepEqual(groups, test.groups) {
+			t.Errorf("%s: got groups %v, want %v", test.name, groups, test.groups)
+		}
+	}
+}
+
+func TestNewFleetServiceRejectsWs(t *testing.T) {
+	config := CompareConfig{Rpc: RpcConfig{RealtimeUrls: []string{"http://a", "http://b"}, WsUrl: "ws://a"}}
+	if _, err := NewFleetService(config, log.New(io.Discard, "", 0)); !errors.Is(err, ErrFleetWsUnsupported) {
+		t.Errorf("got error %v, want %v", err, ErrFleetWsUnsupported)
+	}
+}
@@ -0,0 +1,113 @@
+package compare
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	zktypes "github.com/ledgerwatch/erigon/zk/types"
+	"github.com/sieniven/realtime-compare-tool/metrics"
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+func (service *CompareService) ProcessCompareInnerTxCache(ctx context.Context) {
+	for {
+		if !service.waitUntilComparing(ctx) {
+			return
+		}
+
+		txHashes := service.innerTxCache.GetKeys()
+		service.workerPool.Run(ctx, len(txHashes), func(ctx context.Context, i int) {
+			txHash := txHashes[i]
+			// Only compare once the tx is mined on the eth node
+			ethReceipt, err := service.RpcClient.EthGetTransactionReceipt(ctx, txHash)
+			if err != nil {
+				service.Logger.Printf("error getting eth receipt for tx %s: %v\n", txHash, err)
+				return
+			}
+			if ethReceipt == nil {
+				return
+			}
+			ethInnerTxs, err := service.RpcClient.EthGetInternalTransactions(ctx, txHash)
+			if err != nil {
+				service.Logger.Printf("error getting eth inner txs for tx %s: %v\n", txHash, err)
+				return
+			}
+			realtimeInnerTxs, err := service.RpcClient.RealtimeGetInternalTransactions(ctx, txHash)
+			if err != nil {
+				service.Logger.Printf("error getting realtime inner txs for tx %s: %v\n", txHash, err)
+				return
+			}
+
+			// Run the inner txs comparison
+			diffs, err := diffInnerTxs(ethInnerTxs, realtimeInnerTxs)
+			if err != nil {
+				service.Logger.Printf("error comparing inner txs for tx %s: %v\n", txHash, err)
+				return
+			}
+			mismatch := store.Mismatch{
+				Comparator: "innertx",
+				Key:        txHash.Hex(),
+				Height:     ethReceipt.BlockNumber.Uint64(),
+				Diffs:      strings.Join(diffs, "; "),
+			}
+			switch settleComparison(ctx, service, service.innerTxCache, txHash, len(diffs) == 0, mismatch) {
+			case metrics.ResultConfirmedMismatch:
+				service.Logger.Printf("Error in state comparator: inner txs mismatch at height %d for tx %s, diffs: %s\n", ethReceipt.BlockNumber, txHash, strings.Join(diffs, "; "))
+			case metrics.ResultMatch:
+				service.Logger.Printf("Inner txs are equal at height %d for tx %s\n", ethReceipt.BlockNumber, txHash)
+			}
+		})
+
+		time.Sleep(time.Duration(service.Config.CompareIntervalMS) * time.Millisecond)
+	}
+}
+
+// diffInnerTxs matches the eth and realtime inner txs by their call path, and returns the description of every
+// missing, extra and differing frame
+func diffInnerTxs(ethInnerTxs []zktypes.InnerTx, realtimeInnerTxs []zktypes.InnerTx) ([]string, error) {
+	ethFrames, ethPaths := innerTxsByPath(ethInnerTxs)
+	realtimeFrames, realtimePaths := innerTxsByPath(realtimeInnerTxs)
+
+	diffs := []string{}
+	for _, path := range ethPaths {
+		realtimeFrame, ok := realtimeFrames[path]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("missing frame %s", path))
+			continue
+		}
+		fields, err := diffJSONFields(ethFrames[path], realtimeFrame)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) > 0 {
+			diffs = append(diffs, fmt.Sprintf("frame %s differs in %s", path, strings.Join(fields, ",")))
+		}
+	}
+	for _, path := range realtimePaths {
+		if _, ok := ethFrames[path]; !ok {
+			diffs = append(diffs, fmt.Sprintf("extra frame %s", path))
+		}
+	}
+	return diffs, nil
+}
+
+// innerTxsByPath indexes the inner txs by their call path, returning the paths in list order. Frames without a
+// trace address fall back to their list index
+func innerTxsByPath(innerTxs []zktypes.InnerTx) (map[string]zktypes.InnerTx, []string) {
+	frames := make(map[string]zktypes.InnerTx, len(innerTxs))
+	paths := make([]string, 0, len(innerTxs))
+	for i, innerTx := range innerTxs {
+		path := innerTx.TraceAddress
+		if path == "" {
+			path = fmt.Sprintf("#%d", i)
+		}
+		if _, ok := frames[path]; ok {
+			path = fmt.Sprintf("%s#%d", path, i)
+		}
+		frames[path] = innerTx
+		paths = append(paths, path)
+	}
+	return frames, paths
+}
@@ -0,0 +1,91 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/core/types"
+	zktypes "github.com/ledgerwatch/erigon/zk/types"
+)
+
+func TestDiffInnerTxs(t *testing.T) {
+	tests := []struct {
+		name     string
+		eth      []zktypes.InnerTx
+		realtime []zktypes.InnerTx
+		diffs    []string
+	}{
+		{
+			name:     "equal",
+			eth:      []zktypes.InnerTx{{TraceAddress: "0", To: "0x01"}, {TraceAddress: "0_0", To: "0x02"}},
+			realtime: []zktypes.InnerTx{{TraceAddress: "0", To: "0x01"}, {TraceAddress: "0_0", To: "0x02"}},
+			diffs:    []string{},
+		},
+		{
+			name:     "matched by path regardless of order",
+			eth:      []zktypes.InnerTx{{TraceAddress: "0", To: "0x01"}, {TraceAddress: "0_0", To: "0x02"}},
+			realtime: []zktypes.InnerTx{{TraceAddress: "0_0", To: "0x02"}, {TraceAddress: "0", To: "0x01"}},
+			diffs:    []string{},
+		},
+		{
+			name:     "differing fields",
+			eth:      []zktypes.InnerTx{{TraceAddress: "0", To: "0x01", GasUsed: 100}},
+			realtime: []zktypes.InnerTx{{TraceAddress: "0", To: "0x03", GasUsed: 200}},
+			diffs:    []string{"frame 0 differs in gas_used,to"},
+		},
+		{
+			name:     "missing and extra frames",
+			eth:      []zktypes.InnerTx{{TraceAddress: "0"}, {TraceAddress: "0_0"}},
+			realtime: []zktypes.InnerTx{{TraceAddress: "0"}, {TraceAddress: "0_1"}},
+			diffs:    []string{"missing frame 0_0", "extra frame 0_1"},
+		},
+		{
+			name:     "frames without trace address fall back to the index",
+			eth:      []zktypes.InnerTx{{To: "0x01"}},
+			realtime: []zktypes.InnerTx{{To: "0x01"}, {To: "0x02"}},
+			diffs:    []string{"extra frame #1"},
+		},
+	}
+	for _, test := range tests {
+		diffs, err := diffInnerTxs(test.eth, test.realtime)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(diffs, test.diffs) {
+			t.Errorf("%s: got diffs %q, want %q", test.name, diffs, test.diffs)
+		}
+	}
+}
+
+func TestCompareServiceInnerTxRound(t *testing.T) {
+	txHash := common.HexToHash("0x1a")
+	var receiptReads, innerTxReads atomic.Int32
+	service, logs := newTestService(t, func(method string, params []json.RawMessage) interface{} {
+		switch method {
+		case "eth_getTransactionReceipt":
+			// The tx is only mined on the eth node from the third read
+			if receiptReads.Add(1) < 3 {
+				return nil
+			}
+			return &types.Receipt{Status: 1, TxHash: txHash, BlockNumber: big.NewInt(12)}
+		case "eth_getInternalTransactions":
+			innerTxReads.Add(1)
+			return []zktypes.InnerTx{{TraceAddress: "0", To: "0x01"}, {TraceAddress: "0_0", To: "0x02"}}
+		case "realtime_getInternalTransactions":
+			return []zktypes.InnerTx{{TraceAddress: "0", To: "0x01"}}
+		}
+		return nil
+	})
+	service.innerTxCache.Add(txHash)
+
+	mismatches := runComparisonRound(t, service.ProcessCompareInnerTxCache, service.innerTxCache.Size, logs, "inner txs mismatch")
+	if innerTxReads.Load() != 2 {
+		t.Errorf("got %d eth inner tx reads, want 2 once the tx is mined", innerTxReads.Load())
+	}
+	expectMismatch(t, mismatches, fmt.Sprintf("at height 12 for tx %s, diffs: missing frame 0_0\n", txHash))
+}
@@ -0,0 +1,141 @@
+package compare
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type HeightSample struct {
+	Timestamp      time.Time `json:"timestamp"`
+	RealtimeHeight uint64    `json:"realtimeHeight"`
+	EthHeight      uint64    `json:"ethHeight"`
+	KafkaHeight    uint64    `json:"kafkaHeight"`
+}
+
+// RealtimeLag returns the height difference of realtime against the eth node. A negative lag means realtime is behind
+func (sample HeightSample) RealtimeLag() int64 {
+	return int64(sample.RealtimeHeight) - int64(sample.EthHeight)
+}
+
+// KafkaLag returns the height difference of realtime against the kafka block stream. A negative lag means realtime
+// is behind
+func (sample HeightSample) KafkaLag() int64 {
+	return int64(sample.RealtimeHeight) - int64(sample.KafkaHeight)
+}
+
+// LagHistory keeps a rolling history of the latest height samples
+type LagHistory struct {
+	mu      sync.RWMutex
+	samples []HeightSample
+	size    int
+}
+
+func NewLagHistory(size int) *LagHistory {
+	return &LagHistory{
+		samples: make([]HeightSample, 0, size),
+		size:    size,
+	}
+}
+
+func (history *LagHistory) Add(sample HeightSample) {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+
+	if len(history.samples) >= history.size {
+		// Drop the oldest sample
+		history.samples = history.samples[1:]
+	}
+	history.samples = append(history.samples, sample)
+}
+
+func (history *LagHistory) GetSamples() []HeightSample {
+	history.mu.RLock()
+	defer history.mu.RUnlock()
+
+	samples := make([]HeightSample, len(history.samples))
+	copy(samples, history.samples)
+	return samples
+}
+
+func (history *LagHistory) Latest() (HeightSample, bool) {
+	history.mu.RLock()
+	defer history.mu.RUnlock()
+
+	if len(history.samples) == 0 {
+		return HeightSample{}, false
+	}
+	return history.samples[len(history.samples)-1], true
+}
+
+// ProcessLagMonitor continuously samples the realtime, eth and kafka heights, and pauses the comparisons while
+// realtime is out of sync beyond the configured lag threshold
+func (service *CompareService) ProcessLagMonitor(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		// The cache gauges are reported even when the heights can not be sampled
+		service.reportCacheSizes()
+		sample, err := service.sampleHeights(ctx)
+		if err != nil {
+			service.Logger.Printf("error sampling heights in lag monitor: %v\n", err)
+			time.Sleep(time.Duration(service.Config.LagIntervalMS) * time.Millisecond)
+			continue
+		}
+		service.lagHistory.Add(sample)
+		service.reportHeights(sample)
+
+		inSync := service.isInSync(sample)
+		if inSync != service.SyncFlag.Load() {
+			if inSync {
+				service.Logger.Printf("Realtime back in sync, resuming compare. realtime: %d, eth: %d, kafka: %d\n", sample.RealtimeHeight, sample.EthHeight, sample.KafkaHeight)
+			} else {
+				service.Logger.Printf("Error in lag monitor: realtime out of sync, pausing compare. realtime: %d, eth: %d, kafka: %d, realtime lag: %d, kafka lag: %d\n", sample.RealtimeHeight, sample.EthHeight, sample.KafkaHeight, sample.RealtimeLag(), sample.KafkaLag())
+			}
+			service.SyncFlag.Store(inSync)
+		}
+
+		time.Sleep(time.Duration(service.Config.LagIntervalMS) * time.Millisecond)
+	}
+}
+
+func (service *CompareService) sampleHeights(ctx context.Context) (HeightSample, error) {
+	realtimeHeight, err := service.RpcClient.RealtimeBlockNumber(ctx)
+	if err != nil {
+		return HeightSample{}, err
+	}
+	ethHeight, err := service.RpcClient.EthGetBlockNumber(ctx)
+	if err != nil {
+		return HeightSample{}, err
+	}
+	return HeightSample{
+		Timestamp:      time.Now(),
+		RealtimeHeight: realtimeHeight,
+		EthHeight:      ethHeight,
+		KafkaHeight:    uint64(service.NodeHeight.Load()),
+	}, nil
+}
+
+// isInSync checks if realtime is within the lag threshold of the eth height, and of the kafka height once the
+// kafka block stream has been received
+func (service *CompareService) isInSync(sample HeightSample) bool {
+	threshold := int64(service.Config.LagThreshold)
+	if abs(sample.RealtimeLag()) > threshold {
+		return false
+	}
+	if sample.KafkaHeight > 0 && abs(sample.KafkaLag()) > threshold {
+		return false
+	}
+	return true
+}
+
+func abs(value int64) int64 {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,74 @@
+package compare
+
+import (
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLagHistoryDropsOldestSample(t *testing.T) {
+	history := NewLagHistory(2)
+	if _, ok := history.Latest(); ok {
+		t.Fatal("got a latest sample from an empty history")
+	}
+	for height := uint64(1); height <= 3; height++ {
+		history.Add(HeightSample{RealtimeHeight: height})
+	}
+
+	samples := history.GetSamples()
+	if len(samples) != 2 || samples[0].RealtimeHeight != 2 || samples[1].RealtimeHeight != 3 {
+		t.Errorf("got samples %+v, want the realtime heights 2 and 3", samples)
+	}
+	if latest, _ := history.Latest(); latest.RealtimeHeight != 3 {
+		t.Errorf("got latest realtime height %d, want 3", latest.RealtimeHeight)
+	}
+}
+
+func TestIsInSync(t *testing.T) {
+	service := &CompareService{Config: CompareConfig{LagThreshold: 5}}
+	inSync := map[HeightSample]bool{
+		{RealtimeHeight: 100, EthHeight: 100}:                   true,
+		{RealtimeHeight: 95, EthHeight: 100}:                    true,
+		{RealtimeHeight: 94, EthHeight: 100}:                    false,
+		{RealtimeHeight: 106, EthHeight: 100}:                   false,
+		{RealtimeHeight: 100, EthHeight: 100, KafkaHeight: 90}:  false,
+		{RealtimeHeight: 100, EthHeight: 100, KafkaHeight: 104}: true,
+	}
+	for sample, want := range inSync {
+		if got := service.isInSync(sample); got != want {
+			t.Errorf("realtime %d, eth %d, kafka %d: got in sync %v, want %v", sample.RealtimeHeight, sample.EthHeight, sample.KafkaHeight, got, want)
+		}
+	}
+}
+
+func TestLagMonitorPausesComparisonsOutOfSync(t *testing.T) {
+	var realtimeHeight atomic.Value
+	realtimeHeight.Store("0x5a")
+	service, _ := newTestService(t, func(method string, params []json.RawMessage) interface{} {
+		switch method {
+		case "realtime_blockNumber":
+			return realtimeHeight.Load()
+		case "eth_blockNumber":
+			return "0x64"
+		}
+		return nil
+	})
+	service.Config.LagThreshold = 5
+	service.Config.LagIntervalMS = 5
+
+	// Realtime is 10 blocks behind eth
+	runComparisons(t, service.ProcessLagMonitor, func() bool {
+		return !service.SyncFlag.Load()
+	})
+	if sample, ok := service.lagHistory.Latest(); !ok || sample.RealtimeLag() != -10 {
+		t.Fatalf("got latest sample %+v, want a realtime lag of -10", sample)
+	}
+
+	realtimeHeight.Store("0x62")
+	runComparisons(t, service.ProcessLagMonitor, func() bool {
+		return service.SyncFlag.Load()
+	})
+	if sample, _ := service.lagHistory.Latest(); sample.RealtimeHeight != 98 {
+		t.Errorf("got latest sample %+v, want the caught up realtime height 98", sample)
+	}
+}
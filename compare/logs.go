@@ -0,0 +1,200 @@
+package compare
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/sieniven/realtime-compare-tool/metrics"
+	"github.com/sieniven/realtime-compare-tool/rpc"
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+// logKey identifies a log by its transaction hash and block log index
+type logKey struct {
+	TxHash common.Hash
+	Index  uint
+}
+
+func (service *CompareService) ProcessCompareLogsCache(ctx context.Context) {
+	for {
+		if !service.waitUntilComparing(ctx) {
+			return
+		}
+
+		ethHeight, err := service.RpcClient.EthGetBlockNumber(ctx)
+		if err != nil {
+			service.Logger.Printf("error getting eth block number: %v\n", err)
+			time.Sleep(time.Duration(service.Config.CompareIntervalMS) * time.Millisecond)
+			continue
+		}
+		heights := service.logsCache.GetKeys()
+		service.workerPool.Run(ctx, len(heights), func(ctx context.Context, i int) {
+			height := heights[i]
+			// Only compare once the block is confirmed on the eth node, so reorged blocks are not reported
+			if !isConfirmed(height, ethHeight, service.Config.BlockConfirmations) {
+				return
+			}
+			ethBlock, err := service.RpcClient.EthGetBlockByNumber(ctx, height)
+			if err != nil {
+				service.Logger.Printf("error getting eth block at height %d: %v\n", height, err)
+				return
+			}
+			if ethBlock == nil {
+				// The height is confirmed on the eth node, so the block is not going to be served later either
+				service.Logger.Printf("Eth block is missing at confirmed height %d, skipping logs compare\n", height)
+				service.logsCache.Remove(height)
+				return
+			}
+
+			// Run the logs comparison
+			diffs, err := service.diffBlockLogs(ctx, ethBlock)
+			if errors.Is(err, ErrLogsNotComparable) {
+				// A missing realtime receipt is reported by the receipt comparator
+				service.Logger.Printf("Realtime logs are not comparable at height %d, skipping compare: %v\n", height, err)
+				service.logsCache.Remove(height)
+				return
+			}
+			if err != nil {
+				service.Logger.Printf("error comparing logs at height %d: %v\n", height, err)
+				return
+			}
+			mismatch := store.Mismatch{
+				Comparator: "logs",
+				Height:     height,
+				Diffs:      strings.Join(diffs, "; "),
+			}
+			switch settleComparison(ctx, service, service.logsCache, height, len(diffs) == 0, mismatch) {
+			case metrics.ResultConfirmedMismatch:
+				service.Logger.Printf("Error in state comparator: logs mismatch at height %d, diffs: %s\n", height, strings.Join(diffs, "; "))
+			case metrics.ResultMatch:
+				service.Logger.Printf("Logs are equal at height %d\n", height)
+			}
+		})
+
+		time.Sleep(time.Duration(service.Config.CompareIntervalMS) * time.Millisecond)
+	}
+}
+
+// diffBlockLogs compares the logs of the block from eth_getLogs against the logs of the realtime receipts, and
+// returns the description of every missing, extra and mutated log
+func (service *CompareService) diffBlockLogs(ctx context.Context, ethBlock *rpc.Block) ([]string, error) {
+	ethLogs, err := service.RpcClient.EthGetLogs(ctx, uint64(ethBlock.Number), service.Config.LogsAddresses, service.Config.LogsTopics)
+	if err != nil {
+		return nil, err
+	}
+	realtimeLogs, err := service.getRealtimeBlockLogs(ctx, uint64(ethBlock.Number))
+	if err != nil {
+		return nil, err
+	}
+
+	ethLogsByKey := make(map[logKey]*types.Log, len(ethLogs))
+	for _, log := range ethLogs {
+		ethLogsByKey[logKey{TxHash: log.TxHash, Index: log.Index}] = log
+	}
+	realtimeLogsByKey := make(map[logKey]*types.Log, len(realtimeLogs))
+	for _, log := range realtimeLogs {
+		realtimeLogsByKey[logKey{TxHash: log.TxHash, Index: log.Index}] = log
+	}
+
+	diffs := []string{}
+	for _, ethLog := range ethLogs {
+		key := logKey{TxHash: ethLog.TxHash, Index: ethLog.Index}
+		realtimeLog, ok := realtimeLogsByKey[key]
+		if !ok {
+			diffs = append(diffs, fmt.Sprintf("missing log tx %s index %d", key.TxHash, key.Index))
+			continue
+		}
+		if ethLog.Address != realtimeLog.Address || !equalTopics(ethLog, realtimeLog) || !bytes.Equal(ethLog.Data, realtimeLog.Data) {
+			diffs = append(diffs, fmt.Sprintf("mutated log tx %s index %d, eth: %s %v 0x%x, realtime: %s %v 0x%x", key.TxHash, key.Index, ethLog.Address, ethLog.Topics, ethLog.Data, realtimeLog.Address, realtimeLog.Topics, realtimeLog.Data))
+		}
+	}
+	for _, realtimeLog := range realtimeLogs {
+		key := logKey{TxHash: realtimeLog.TxHash, Index: realtimeLog.Index}
+		if _, ok := ethLogsByKey[key]; !ok {
+			diffs = append(diffs, fmt.Sprintf("extra log tx %s index %d", key.TxHash, key.Index))
+		}
+	}
+	return diffs, nil
+}
+
+// getRealtimeBlockLogs returns the logs of the realtime receipts of the transactions in the realtime view of the
+// block, filtered by the configured log addresses and topics. The receipts are read in batches, and
+// ErrLogsNotComparable is returned if a receipt is missing, as the logs of the block are then incomplete
+func (service *CompareService) getRealtimeBlockLogs(ctx context.Context, height uint64) ([]*types.Log, error) {
+	txCount, err := service.RpcClient.RealtimeGetBlockTransactionCountByNumber(ctx, height)
+	if err != nil {
+		return nil, err
+	}
+	blockTxHashes, errs, err := service.RpcClient.RealtimeBatchGetBlockTransactionHashes(ctx, height, txCount)
+	if err != nil {
+		return nil, err
+	}
+	txHashes := make([]common.Hash, 0, len(blockTxHashes))
+	for index, txHash := range blockTxHashes {
+		if errs[index] != nil {
+			return nil, fmt.Errorf("error getting realtime tx at index %d: %v", index, errs[index])
+		}
+		if txHash == (common.Hash{}) {
+			// Block transaction divergences are reported by the block comparator
+			continue
+		}
+		txHashes = append(txHashes, txHash)
+	}
+	receipts, errs, err := service.RpcClient.RealtimeBatchGetTransactionReceipts(ctx, txHashes)
+	if err != nil {
+		return nil, err
+	}
+
+	logs := []*types.Log{}
+	for i, txHash := range txHashes {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("error getting realtime receipt for tx %s: %v", txHash, errs[i])
+		}
+		if receipts[i] == nil {
+			return nil, fmt.Errorf("%w: missing realtime receipt for tx %s", ErrLogsNotComparable, txHash)
+		}
+		for _, log := range receipts[i].Logs {
+			if !service.matchLogsFilter(log) {
+				continue
+			}
+			// Key the log by the receipt tx hash, in case realtime does not populate the log tx hash
+			logCopy := *log
+			logCopy.TxHash = txHash
+			logs = append(logs, &logCopy)
+		}
+	}
+	return logs, nil
+}
+
+func (service *CompareService) matchLogsFilter(log *types.Log) bool {
+	if len(service.Config.LogsAddresses) > 0 {
+		matched := false
+		for _, address := range service.Config.LogsAddresses {
+			if log.Address == address {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(service.Config.LogsTopics) > 0 {
+		if len(log.Topics) == 0 {
+			return false
+		}
+		for _, topic := range service.Config.LogsTopics {
+			if log.Topics[0] == topic {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
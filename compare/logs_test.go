@@ -0,0 +1,67 @@
+package compare
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/sieniven/realtime-compare-tool/rpc"
+)
+
+func TestCompareServiceLogsRound(t *testing.T) {
+	txHash := common.HexToHash("0x0c")
+	transferLog := &types.Log{Address: common.HexToAddress("0x0d"), Topics: []common.Hash{common.HexToHash("0x0e")}, Data: []byte{1}, BlockNumber: 10, TxHash: txHash}
+	approvalLog := &types.Log{Address: common.HexToAddress("0x0d"), Topics: []common.Hash{common.HexToHash("0x0f")}, Data: []byte{2}, BlockNumber: 10, TxHash: txHash, Index: 1}
+	receiptWith := func(logs ...*types.Log) *types.Receipt {
+		return &types.Receipt{Status: 1, Logs: logs, TxHash: txHash}
+	}
+
+	tests := []struct {
+		name     string
+		realtime *types.Receipt
+		diffs    string
+		skipped  bool
+	}{
+		{name: "equal logs", realtime: receiptWith(transferLog)},
+		{name: "extra realtime log", realtime: receiptWith(transferLog, approvalLog), diffs: "extra log tx " + txHash.Hex() + " index 1"},
+		{name: "missing realtime receipt is not compared", skipped: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service, logs := newTestService(t, func(method string, params []json.RawMessage) interface{} {
+				switch method {
+				case "eth_blockNumber":
+					return "0x20"
+				case "eth_getBlockByNumber":
+					return rpc.Block{Number: 10, Transactions: []common.Hash{txHash}}
+				case "eth_getLogs":
+					return []*types.Log{transferLog}
+				case "realtime_getBlockTransactionCountByNumber":
+					return "0x1"
+				case "realtime_getTransactionByBlockNumberAndIndex":
+					return map[string]common.Hash{"hash": txHash}
+				case "realtime_getTransactionReceipt":
+					return test.realtime
+				}
+				return nil
+			})
+			service.logsCache.Add(10)
+
+			runComparisons(t, service.ProcessCompareLogsCache, func() bool {
+				return service.logsCache.Size() == 0
+			})
+			if skipped := logs.find("Realtime logs are not comparable at height 10"); test.skipped != (len(skipped) == 1) {
+				t.Errorf("got skipped logs %q, want the height skipped %v", skipped, test.skipped)
+			}
+			mismatches := logs.find("logs mismatch")
+			switch {
+			case test.diffs == "" && len(mismatches) != 0:
+				t.Errorf("got mismatches %q, want none", mismatches)
+			case test.diffs != "" && (len(mismatches) != 1 || !strings.HasSuffix(mismatches[0], "at height 10, diffs: "+test.diffs+"\n")):
+				t.Errorf("got mismatches %q, want diffs %q", mismatches, test.diffs)
+			}
+		})
+	}
+}
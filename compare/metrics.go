@@ -0,0 +1,56 @@
+package compare
+
+import (
+	"github.com/sieniven/realtime-compare-tool/metrics"
+)
+
+// observeComparison counts the comparison result of the comparator against the realtime node
+func (service *CompareService) observeComparison(comparator string, result string) {
+	metrics.ObserveComparison(service.metricsNode(), comparator, result)
+}
+
+// metricsNode returns the node label of the realtime node, the host of the realtime url
+func (service *CompareService) metricsNode() string {
+	return metrics.EndpointLabel(service.RpcClient.RealtimeUrl())
+}
+
+// reportHeights updates the height gauges of the realtime node
+func (service *CompareService) reportHeights(sample HeightSample) {
+	node := service.metricsNode()
+	metrics.Heights.WithLabelValues(node, metrics.HeightRealtime).Set(float64(sample.RealtimeHeight))
+	metrics.Heights.WithLabelValues(node, metrics.HeightCanonical).Set(float64(sample.EthHeight))
+	metrics.Heights.WithLabelValues(node, metrics.HeightKafka).Set(float64(sample.KafkaHeight))
+}
+
+// reportCacheSizes updates the compare cache size gauges of the realtime node
+func (service *CompareService) reportCacheSizes() {
+	node := service.metricsNode()
+	for cache, size := range service.cacheSizes() {
+		metrics.CacheSize.WithLabelValues(node, cache).Set(float64(size))
+	}
+}
+
+// cacheSizes returns the number of entries pending comparison in each compare cache
+func (service *CompareService) cacheSizes() map[string]int {
+	return map[string]int{
+		"balance":                service.balanceCache.Size(),
+		"addrToken":              service.addrTokenCache.Size(),
+		"nonce":                  service.nonceCache.Size(),
+		"code":                   service.codeCache.Size(),
+		"storage":                service.storageCache.Size(),
+		"receipt":                service.receiptCache.Size(),
+		"innerTx":                service.innerTxCache.Size(),
+		"block":                  service.blockCache.Size(),
+		"logs":                   service.logsCache.Size(),
+		"tx":                     service.txCache.Size(),
+		"call":                   service.callCache.Size(),
+		"token":                  service.tokenCache.Size(),
+		"allowance":              service.allowanceCache.Size(),
+		ERC721OwnerComparator:    service.erc721OwnerCache.Size(),
+		ERC721BalanceComparator:  service.erc721BalanceCache.Size(),
+		ERC1155BalanceComparator: service.erc1155BalanceCache.Size(),
+		"txNotification":         service.txNotificationCache.Size(),
+		"balanceNotification":    service.balanceNotificationCache.Size(),
+		"missedTx":               service.missedTxCache.Size(),
+	}
+}
@@ -0,0 +1,61 @@
+package compare
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sieniven/realtime-compare-tool/metrics"
+)
+
+func TestCacheSizesCoverEveryCache(t *testing.T) {
+	service, _ := newTestService(t, func(method string, params []json.RawMessage) interface{} { return nil })
+	address := common.HexToAddress("0x01")
+	txHash := common.HexToHash("0x02")
+	service.balanceCache.Add(address)
+	service.addrTokenCache.Add(address, address)
+	service.nonceCache.Add(address)
+	service.codeCache.Add(address)
+	service.storageCache.Add(address, txHash)
+	service.receiptCache.Add(txHash)
+	service.innerTxCache.Add(txHash)
+	service.blockCache.Add(1)
+	service.logsCache.Add(1)
+	service.txCache.Add(txHash)
+	service.callCache.Add("totalSupply")
+	service.tokenCache.Add(address)
+	service.allowanceCache.Add(address, AllowancePair{Owner: address, Spender: address})
+	service.erc721OwnerCache.Add(address, NftKey{TokenID: txHash})
+	service.erc721BalanceCache.Add(address, NftKey{Holder: address})
+	service.erc1155BalanceCache.Add(address, NftKey{Holder: address, TokenID: txHash})
+	service.txNotificationCache.Add(txHash, 1, 1)
+	service.balanceNotificationCache.Add(address, nil, 1)
+	service.missedTxCache.Add(txHash, 1, 1)
+
+	sizes := service.cacheSizes()
+	if len(sizes) != 19 {
+		t.Errorf("got %d cache gauges, want 19", len(sizes))
+	}
+	for cache, size := range sizes {
+		if size != 1 {
+			t.Errorf("got size %d for the %s cache, want 1", size, cache)
+		}
+	}
+}
+
+func TestLagMonitorReportsCacheSizesWithoutHeights(t *testing.T) {
+	// The stand-in returns null heights, so every height sample fails
+	service, _ := newTestService(t, func(method string, params []json.RawMessage) interface{} { return nil })
+	service.Config.LagIntervalMS = 5
+	service.missedTxCache.Add(common.HexToHash("0x01"), 1, 1)
+	service.missedTxCache.Add(common.HexToHash("0x02"), 1, 1)
+
+	gauge := metrics.CacheSize.WithLabelValues(service.metricsNode(), "missedTx")
+	runComparisons(t, service.ProcessLagMonitor, func() bool {
+		return testutil.ToFloat64(gauge) == 2
+	})
+	if _, ok := service.lagHistory.Latest(); ok {
+		t.Error("got a height sample from the failing stand-in")
+	}
+}
@@ -0,0 +1,100 @@
+package compare
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/sieniven/realtime-compare-tool/alert"
+	"github.com/sieniven/realtime-compare-tool/metrics"
+	"github.com/sieniven/realtime-compare-tool/rpc"
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+// newMismatchStore opens the confirmed mismatch store, or returns nil if the store is disabled
+func newMismatchStore(config CompareConfig) (*store.MismatchStore, error) {
+	if config.MismatchStorePath == "" {
+		return nil, nil
+	}
+	return store.NewMismatchStore(config.MismatchStorePath)
+}
+
+// newAlerter creates the alerter with the configured webhook sinks, or returns nil if no webhook is configured
+func newAlerter(config CompareConfig, logger *log.Logger) *alert.Alerter {
+	sinks := make([]alert.Sink, 0)
+	if config.AlertWebhookUrl != "" {
+		sinks = append(sinks, alert.NewWebhookSink(config.AlertWebhookUrl))
+	}
+	if config.AlertChatWebhookUrl != "" {
+		sinks = append(sinks, alert.NewChatWebhookSink(config.AlertChatWebhookUrl))
+	}
+	if len(sinks) == 0 {
+		return nil
+	}
+	return alert.NewAlerter(alert.AlertConfig{
+		DedupWindow: time.Duration(config.AlertDedupWindowMS) * time.Millisecond,
+		GroupWindow: time.Duration(config.AlertGroupWindowMS) * time.Millisecond,
+		RateLimit:   config.AlertRateLimit,
+	}, sinks, logger)
+}
+
+// countCache is a compare cache that keeps the number of consecutive mismatches of each pending key
+type countCache[K comparable] interface {
+	AddWithCount(key K, count int)
+	Remove(key K)
+	GetCount(key K) int
+}
+
+// settleComparison settles the comparison of the pending key in the cache, and returns its comparison result. A
+// mismatch is compared again in the next rounds until it persists past the mismatch count, after which it is
+// recorded as confirmed and removed from the cache. An equal comparison is removed from the cache
+func settleComparison[K comparable](ctx context.Context, service *CompareService, cache countCache[K], key K, equal bool, mismatch store.Mismatch) string {
+	result := metrics.ResultMatch
+	if !equal {
+		count := cache.GetCount(key)
+		if count <= service.Config.MismatchCount {
+			cache.AddWithCount(key, count+1)
+			service.observeComparison(mismatch.Comparator, metrics.ResultTransientMismatch)
+			return metrics.ResultTransientMismatch
+		}
+		result = metrics.ResultConfirmedMismatch
+		mismatch.Attempts = count + 1
+		service.recordMismatch(ctx, mismatch)
+	}
+	service.observeComparison(mismatch.Comparator, result)
+	cache.Remove(key)
+	return result
+}
+
+// recordMismatch persists, alerts and captures the evidence of the confirmed mismatch, with the endpoints of the node
+// it was found on. The context is the context of the comparison, recording the RPC calls that found the mismatch
+func (service *CompareService) recordMismatch(ctx context.Context, mismatch store.Mismatch) {
+	mismatch.RealtimeUrl = service.RpcClient.RealtimeUrl()
+	mismatch.ReferenceUrl = service.RpcClient.ReferenceUrl()
+	mismatch.Timestamp = time.Now().UTC()
+	if service.mismatchStore != nil {
+		id, err := service.mismatchStore.Add(mismatch)
+		if err != nil {
+			service.Logger.Printf("error recording %s mismatch at height %d: %v\n", mismatch.Comparator, mismatch.Height, err)
+		}
+		mismatch.ID = id
+	}
+	if service.alerter != nil {
+		service.alerter.Notify(mismatch)
+	}
+	if service.Config.EvidenceDir != "" {
+		select {
+		case service.evidenceSlots <- struct{}{}:
+		default:
+			service.Logger.Printf("evidence captures in progress, skipping the evidence of %s mismatch at height %d\n", mismatch.Comparator, mismatch.Height)
+			return
+		}
+		comparisonTimings := rpc.CallTimings(ctx)
+		go func() {
+			defer func() {
+				<-service.evidenceSlots
+			}()
+			service.captureEvidence(ctx, mismatch, comparisonTimings)
+		}()
+	}
+}
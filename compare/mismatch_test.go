@@ -0,0 +1,66 @@
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/sieniven/realtime-compare-tool/metrics"
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+func TestRecordMismatch(t *testing.T) {
+	service, _ := newTestService(t, func(method string, params []json.RawMessage) interface{} { return nil })
+	mismatchStore := newTestMismatchStore(t)
+	service.mismatchStore = mismatchStore
+
+	service.recordMismatch(context.Background(), store.Mismatch{Comparator: "nonce", Address: "0x01", Height: 10, Eth: "5", Realtime: "6", Attempts: 2})
+	mismatches, err := mismatchStore.List(store.Filter{Comparator: "nonce"})
+	if err != nil {
+		t.Fatalf("list mismatches: %v", err)
+	}
+	if len(mismatches) != 1 {
+		t.Fatalf("got %d mismatches, want 1", len(mismatches))
+	}
+	mismatch := mismatches[0]
+	if mismatch.ID == 0 || mismatch.Height != 10 || mismatch.Eth != "5" || mismatch.Realtime != "6" || mismatch.Attempts != 2 {
+		t.Errorf("got mismatch %+v, want the recorded nonce mismatch", mismatch)
+	}
+	if mismatch.RealtimeUrl != service.RpcClient.RealtimeUrl() || mismatch.ReferenceUrl != service.RpcClient.ReferenceUrl() {
+		t.Errorf("got endpoints %s and %s, want the endpoints of the service", mismatch.RealtimeUrl, mismatch.ReferenceUrl)
+	}
+	if mismatch.Timestamp.IsZero() {
+		t.Errorf("got a zero timestamp, want the time the mismatch was recorded")
+	}
+
+	// The mismatch is only logged by the comparators when the store is disabled
+	service.mismatchStore = nil
+	service.recordMismatch(context.Background(), store.Mismatch{Comparator: "nonce", Address: "0x02"})
+	if mismatches, _ := mismatchStore.List(store.Filter{}); len(mismatches) != 1 {
+		t.Errorf("got %d stored mismatches with the store disabled, want 1", len(mismatches))
+	}
+}
+
+func TestSettleComparison(t *testing.T) {
+	service, _ := newTestService(t, func(method string, params []json.RawMessage) interface{} { return nil })
+	service.Config.MismatchCount = 1
+	cache, err := NewCompareCountCache[string]()
+	if err != nil {
+		t.Fatalf("create cache: %v", err)
+	}
+	cache.Add("match")
+	cache.Add("mismatch")
+
+	if result := settleComparison(context.Background(), service, cache, "match", true, store.Mismatch{Comparator: "test"}); result != metrics.ResultMatch {
+		t.Errorf("got result %s for the equal key, want %s", result, metrics.ResultMatch)
+	}
+	wants := []string{metrics.ResultTransientMismatch, metrics.ResultTransientMismatch, metrics.ResultConfirmedMismatch}
+	for i, want := range wants {
+		if result := settleComparison(context.Background(), service, cache, "mismatch", false, store.Mismatch{Comparator: "test"}); result != want {
+			t.Errorf("got result %s for mismatch %d, want %s", result, i+1, want)
+		}
+	}
+	if cache.Size() != 0 {
+		t.Errorf("got %d cached keys, want the settled keys removed", cache.Size())
+	}
+}
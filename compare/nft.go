@@ -0,0 +1,229 @@
+package compare
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/accounts/abi"
+	"github.com/sieniven/realtime-compare-tool/kafka"
+	"github.com/sieniven/realtime-compare-tool/metrics"
+	"github.com/sieniven/realtime-compare-tool/rpc"
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+const (
+	ERC721OwnerComparator    = "erc721 owner"
+	ERC721BalanceComparator  = "erc721 balance"
+	ERC1155BalanceComparator = "erc1155 balance"
+)
+
+func (service *CompareService) ProcessCompareERC721OwnerCache(ctx context.Context) {
+	service.processCompareNftCache(ctx, service.erc721OwnerCache, ERC721OwnerComparator)
+}
+
+func (service *CompareService) ProcessCompareERC721BalanceCache(ctx context.Context) {
+	service.processCompareNftCache(ctx, service.erc721BalanceCache, ERC721BalanceComparator)
+}
+
+func (service *CompareService) ProcessCompareERC1155BalanceCache(ctx context.Context) {
+	service.processCompareNftCache(ctx, service.erc1155BalanceCache, ERC1155BalanceComparator)
+}
+
+func (service *CompareService) processCompareNftCache(ctx context.Context, cache *CompareNestedCountCache[common.Address, NftKey], comparator string) {
+	for {
+		if !service.waitUntilComparing(ctx) {
+			return
+		}
+
+		tokenAddresses := cache.GetKeys()
+		keys := make([]NftKey, 0)
+		keyTokens := make([]common.Address, 0)
+		for _, tokenAddress := range tokenAddresses {
+			for _, key := range cache.GetSubKeys(tokenAddress) {
+				keys = append(keys, key)
+				keyTokens = append(keyTokens, tokenAddress)
+			}
+		}
+		service.workerPool.Run(ctx, len(keys), func(ctx context.Context, i int) {
+			tokenAddress, key := keyTokens[i], keys[i]
+			// Run the nft comparison
+			description := describeNftKey(comparator, key)
+			call, err := newNftCall(comparator, tokenAddress, key)
+			if err != nil {
+				service.Logger.Printf("error creating %s call for token address %s: %v\n", comparator, tokenAddress, err)
+				cache.Remove(tokenAddress, key)
+				return
+			}
+			height, ethOutput, realtimeOutput, err := service.getNftCallOutputs(ctx, call)
+			if err != nil {
+				service.Logger.Printf("error getting %s outputs for token address %s and %s: %v\n", comparator, tokenAddress, description, err)
+				return
+			}
+			// The holder and token id are recorded in hex, matching the keys of the kafka message history
+			address, tokenID := "", ""
+			if key.Holder != (common.Address{}) {
+				address = key.Holder.Hex()
+			}
+			if comparator != ERC721BalanceComparator {
+				tokenID = key.TokenID.Hex()
+			}
+			mismatch := store.Mismatch{
+				Comparator: comparator,
+				Address:    address,
+				Token:      tokenAddress.Hex(),
+				Key:        tokenID,
+				Height:     height,
+				Eth:        ethOutput.format(call),
+				Realtime:   realtimeOutput.format(call),
+			}
+			switch settleComparison(ctx, service, cache.subKeyCache(tokenAddress), key, ethOutput.equal(realtimeOutput), mismatch) {
+			case metrics.ResultConfirmedMismatch:
+				service.Logger.Printf("Error in state comparator: %s mismatch at height %d for token address %s and %s, eth: %s, realtime: %s\n", comparator, height, tokenAddress, description, ethOutput.format(call), realtimeOutput.format(call))
+			case metrics.ResultMatch:
+				service.Logger.Printf("Nft %s outputs are equal at height %d for token address %s and %s\n", comparator, height, tokenAddress, description)
+			}
+		})
+
+		time.Sleep(time.Duration(service.Config.CompareIntervalMS) * time.Millisecond)
+	}
+}
+
+// nftCallOutput is the output of an nft call, which reverts for the owner of a nonexistent or burned erc721 token
+type nftCallOutput struct {
+	output   []byte
+	reverted bool
+}
+
+func newNftCallOutput(output []byte, err error) (nftCallOutput, error) {
+	if err != nil {
+		if isRevertError(err) {
+			return nftCallOutput{reverted: true}, nil
+		}
+		return nftCallOutput{}, err
+	}
+	return nftCallOutput{output: output}, nil
+}
+
+// equal returns true if both calls reverted, or both succeeded with the same output
+func (output nftCallOutput) equal(other nftCallOutput) bool {
+	if output.reverted || other.reverted {
+		return output.reverted == other.reverted
+	}
+	return bytes.Equal(output.output, other.output)
+}
+
+func (output nftCallOutput) format(call ContractCall) string {
+	if output.reverted {
+		return "reverted"
+	}
+	return call.FormatOutput(output.output)
+}
+
+// getNftCallOutputs returns the eth and realtime outputs of the nft call. A reverted call is returned as a reverted
+// output rather than an error, so a token burned on one node only is reported as a mismatch, and a token burned on
+// both nodes is compared as equal
+func (service *CompareService) getNftCallOutputs(ctx context.Context, call ContractCall) (uint64, nftCallOutput, nftCallOutput, error) {
+	data := fmt.Sprintf("0x%x", call.Data)
+	if !service.Config.HeightConsistent {
+		ethOutput, err := newNftCallOutput(service.RpcClient.EthCall(ctx, call.Address, call.Data, nil))
+		if err != nil {
+			return 0, nftCallOutput{}, nftCallOutput{}, err
+		}
+		realtimeOutput, err := service.RpcClient.RealtimeCall(ctx, call.Address, "0x0", data)
+		output, err := newNftCallOutput(common.FromHex(realtimeOutput), err)
+		if err != nil {
+			return 0, nftCallOutput{}, nftCallOutput{}, err
+		}
+		return uint64(service.NodeHeight.Load()), ethOutput, output, nil
+	}
+
+	return readAtConsistentHeight(ctx, service,
+		func() (nftCallOutput, uint64, error) {
+			var output nftCallOutput
+			height, err := service.RpcClient.RealtimeReadAtHeight(ctx, func() error {
+				realtimeOutput, err := service.RpcClient.RealtimeCall(ctx, call.Address, "0x0", data)
+				output, err = newNftCallOutput(common.FromHex(realtimeOutput), err)
+				return err
+			})
+			return output, height, err
+		},
+		func(height uint64) (nftCallOutput, error) {
+			return newNftCallOutput(service.RpcClient.EthCall(ctx, call.Address, call.Data, new(big.Int).SetUint64(height)))
+		})
+}
+
+// addNftTransfer enqueues the nft comparisons touched by the transfer. Mints and burns have the zero address as
+// the sender or recipient, which are not compared. The owner of a burned erc721 token is not compared either, as
+// ownerOf reverts on both nodes
+func (service *CompareService) addNftTransfer(transfer kafka.NftTransferData) {
+	tokenID := common.BigToHash(transfer.TokenID)
+	holders := []common.Address{}
+	for _, holder := range []common.Address{transfer.From, transfer.To} {
+		if holder != (common.Address{}) && !service.isSkipAddress(holder) {
+			holders = append(holders, holder)
+		}
+	}
+
+	if transfer.Type == kafka.ERC1155TransferMessageType {
+		for _, holder := range holders {
+			service.erc1155BalanceCache.Add(transfer.TokenAddress, NftKey{Holder: holder, TokenID: tokenID})
+		}
+		return
+	}
+	if transfer.To != (common.Address{}) {
+		service.erc721OwnerCache.Add(transfer.TokenAddress, NftKey{TokenID: tokenID})
+	}
+	for _, holder := range holders {
+		service.erc721BalanceCache.Add(transfer.TokenAddress, NftKey{Holder: holder})
+	}
+}
+
+func newNftCall(comparator string, tokenAddress common.Address, key NftKey) (ContractCall, error) {
+	var name string
+	var args []interface{}
+	var methodFn func(string) (*abi.Method, error)
+	var packFn func(string, ...interface{}) ([]byte, error)
+	tokenID := new(big.Int).SetBytes(key.TokenID.Bytes())
+	switch comparator {
+	case ERC721OwnerComparator:
+		name, args, methodFn, packFn = "ownerOf", []interface{}{tokenID}, rpc.ERC721Method, rpc.PackERC721
+	case ERC721BalanceComparator:
+		name, args, methodFn, packFn = "balanceOf", []interface{}{key.Holder}, rpc.ERC721Method, rpc.PackERC721
+	case ERC1155BalanceComparator:
+		name, args, methodFn, packFn = "balanceOf", []interface{}{key.Holder, tokenID}, rpc.ERC1155Method, rpc.PackERC1155
+	default:
+		return ContractCall{}, fmt.Errorf("unknown nft comparator %s", comparator)
+	}
+
+	method, err := methodFn(name)
+	if err != nil {
+		return ContractCall{}, err
+	}
+	data, err := packFn(name, args...)
+	if err != nil {
+		return ContractCall{}, err
+	}
+	return ContractCall{
+		Name:    name,
+		Address: tokenAddress,
+		Data:    data,
+		Method:  method,
+	}, nil
+}
+
+// describeNftKey returns the readable fields of the nft key used by the comparator
+func describeNftKey(comparator string, key NftKey) string {
+	tokenID := new(big.Int).SetBytes(key.TokenID.Bytes())
+	switch comparator {
+	case ERC721OwnerComparator:
+		return fmt.Sprintf("token id %s", tokenID)
+	case ERC721BalanceComparator:
+		return fmt.Sprintf("holder %s", key.Holder)
+	default:
+		return fmt.Sprintf("holder %s and token id %s", key.Holder, tokenID)
+	}
+}
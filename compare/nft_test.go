@@ -0,0 +1,102 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/sieniven/realtime-compare-tool/kafka"
+)
+
+func TestAddNftTransfer(t *testing.T) {
+	service, _ := newTestService(t, func(method string, params []json.RawMessage) interface{} { return nil })
+	token := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	from, to := common.HexToAddress("0xa2"), common.HexToAddress("0xa3")
+	tokenID := common.BigToHash(big.NewInt(7))
+
+	// An erc721 mint compares the new owner of the token id and the balance of the recipient only
+	service.addNftTransfer(kafka.NftTransferData{Type: kafka.ERC721TransferMessageType, To: to, TokenAddress: token, TokenID: big.NewInt(7)})
+	if owners := service.erc721OwnerCache.GetSubKeys(token); len(owners) != 1 || owners[0] != (NftKey{TokenID: tokenID}) {
+		t.Errorf("got erc721 owner keys %+v, want token id 7", owners)
+	}
+	if balances := service.erc721BalanceCache.GetSubKeys(token); len(balances) != 1 || balances[0] != (NftKey{Holder: to}) {
+		t.Errorf("got erc721 balance keys %+v, want the recipient only", balances)
+	}
+
+	// An erc721 burn compares the balance of the sender only, as the owner of the burned token id reverts
+	burned := common.HexToAddress("0x00000000000000000000000000000000000000b1")
+	service.addNftTransfer(kafka.NftTransferData{Type: kafka.ERC721TransferMessageType, From: from, TokenAddress: burned, TokenID: big.NewInt(7)})
+	if owners := service.erc721OwnerCache.GetSubKeys(burned); len(owners) != 0 {
+		t.Errorf("got erc721 owner keys %+v for the burned token id, want none", owners)
+	}
+	if balances := service.erc721BalanceCache.GetSubKeys(burned); len(balances) != 1 || balances[0] != (NftKey{Holder: from}) {
+		t.Errorf("got erc721 balance keys %+v for the burn, want the sender only", balances)
+	}
+
+	// An erc1155 transfer compares the token id balances of the sender and the recipient
+	service.addNftTransfer(kafka.NftTransferData{Type: kafka.ERC1155TransferMessageType, From: from, To: to, TokenAddress: token, TokenID: big.NewInt(7)})
+	balances := map[NftKey]bool{}
+	for _, key := range service.erc1155BalanceCache.GetSubKeys(token) {
+		balances[key] = true
+	}
+	if len(balances) != 2 || !balances[NftKey{Holder: from, TokenID: tokenID}] || !balances[NftKey{Holder: to, TokenID: tokenID}] {
+		t.Errorf("got erc1155 balance keys %+v, want the sender and the recipient of token id 7", balances)
+	}
+	if len(service.erc721OwnerCache.GetSubKeys(token)) != 1 || len(service.erc721BalanceCache.GetSubKeys(token)) != 1 {
+		t.Error("got erc721 keys added by the erc1155 transfer")
+	}
+}
+
+func TestCompareServiceERC721OwnerRound(t *testing.T) {
+	token := common.HexToAddress("0x00000000000000000000000000000000000000a4")
+	seller, buyer := common.HexToAddress("0xa5"), common.HexToAddress("0xa6")
+	service, logs := newTestService(t, func(method string, params []json.RawMessage) interface{} {
+		// Realtime still reports the seller as the owner after the sale
+		if method == "realtime_call" {
+			return common.BytesToHash(seller.Bytes()).Hex()
+		}
+		return common.BytesToHash(buyer.Bytes()).Hex()
+	})
+	service.erc721OwnerCache.Add(token, NftKey{TokenID: common.BigToHash(big.NewInt(9))})
+
+	mismatches := runComparisonRound(t, service.ProcessCompareERC721OwnerCache, service.erc721OwnerCache.Size, logs, ERC721OwnerComparator+" mismatch")
+	expectMismatch(t, mismatches, fmt.Sprintf("for token address %s and token id 9, eth: [%v], realtime: [%v]\n", token, buyer, seller))
+}
+
+func TestCompareServiceERC721BurnedOwnerRound(t *testing.T) {
+	token := common.HexToAddress("0x00000000000000000000000000000000000000a7")
+	owner := common.HexToAddress("0xa8")
+	burnedOnEth, burnedOnBoth := common.BigToHash(big.NewInt(10)), common.BigToHash(big.NewInt(11))
+	service, logs := newTestService(t, func(method string, params []json.RawMessage) interface{} {
+		if method != "eth_call" && method != "realtime_call" {
+			return nil
+		}
+		// ownerOf(uint256) is the selector followed by the token id
+		var call struct {
+			Input string `json:"input"`
+			Data  string `json:"data"`
+		}
+		json.Unmarshal(params[0], &call)
+		data := call.Data
+		if call.Input != "" {
+			data = call.Input
+		}
+		tokenID := common.HexToHash(data[len(data)-64:])
+		// Realtime still reports the owner of the token burned on eth
+		if tokenID == burnedOnBoth || method == "eth_call" {
+			return &testRpcError{Code: 3, Message: "execution reverted: ERC721: invalid token ID"}
+		}
+		return common.BytesToHash(owner.Bytes()).Hex()
+	})
+	service.erc721OwnerCache.Add(token, NftKey{TokenID: burnedOnEth})
+	service.erc721OwnerCache.Add(token, NftKey{TokenID: burnedOnBoth})
+
+	mismatches := runComparisonRound(t, service.ProcessCompareERC721OwnerCache, service.erc721OwnerCache.Size, logs, ERC721OwnerComparator+" mismatch")
+	expectMismatch(t, mismatches, fmt.Sprintf("for token address %s and token id 10, eth: reverted, realtime: [%v]\n", token, owner))
+	if equal := logs.find("token id 11"); len(equal) != 1 || !strings.Contains(equal[0], "outputs are equal") {
+		t.Errorf("got logs %q for the token burned on both nodes, want the reverts compared as equal", equal)
+	}
+}
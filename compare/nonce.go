@@ -0,0 +1,70 @@
+package compare
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/sieniven/realtime-compare-tool/metrics"
+	"github.com/sieniven/realtime-compare-tool/rpc"
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+func (service *CompareService) ProcessCompareNonceCache(ctx context.Context) {
+	for {
+		if !service.waitUntilComparing(ctx) {
+			return
+		}
+
+		addresses := service.nonceCache.GetKeys()
+		service.workerPool.Run(ctx, len(addresses), func(ctx context.Context, i int) {
+			address := addresses[i]
+			// Run the nonce comparison
+			height, ethNonce, realtimeNonce, err := service.getNonces(ctx, address)
+			if err != nil {
+				service.Logger.Printf("error getting nonces for address %s: %v\n", address, err)
+				return
+			}
+			mismatch := store.Mismatch{
+				Comparator: "nonce",
+				Address:    address.Hex(),
+				Height:     height,
+				Eth:        strconv.FormatUint(ethNonce, 10),
+				Realtime:   strconv.FormatUint(realtimeNonce, 10),
+			}
+			switch settleComparison(ctx, service, service.nonceCache, address, ethNonce == realtimeNonce, mismatch) {
+			case metrics.ResultConfirmedMismatch:
+				service.Logger.Printf("Error in state comparator: nonce mismatch at height %d for address %s, eth: %d, realtime: %d\n", height, address, ethNonce, realtimeNonce)
+			case metrics.ResultMatch:
+				service.Logger.Printf("Nonces are equal at height %d for address %s\n", height, address)
+			}
+		})
+
+		time.Sleep(time.Duration(service.Config.CompareIntervalMS) * time.Millisecond)
+	}
+}
+
+// getNonces returns the eth and realtime nonces of the address, along with the height they were compared at.
+// In height consistent mode, both nonces are read at the same realtime block height
+func (service *CompareService) getNonces(ctx context.Context, address common.Address) (uint64, uint64, uint64, error) {
+	if !service.Config.HeightConsistent {
+		ethNonce, err := service.RpcClient.EthGetTransactionCount(ctx, address, "latest")
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		realtimeNonce, err := service.RpcClient.RealtimeGetTransactionCount(ctx, address)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		return uint64(service.NodeHeight.Load()), ethNonce, realtimeNonce, nil
+	}
+
+	return readAtConsistentHeight(ctx, service,
+		func() (uint64, uint64, error) {
+			return service.RpcClient.RealtimeGetTransactionCountAtHeight(ctx, address)
+		},
+		func(height uint64) (uint64, error) {
+			return service.RpcClient.EthGetTransactionCount(ctx, address, rpc.BlockNumberToHex(height))
+		})
+}
@@ -0,0 +1,43 @@
+package compare
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+)
+
+func TestCompareServiceNonceRound(t *testing.T) {
+	address := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	tests := []struct {
+		name          string
+		realtimeNonce string
+		mismatches    int
+	}{
+		{"equal nonces", "0x5", 0},
+		{"nonce mismatch", "0x6", 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service, logs := newTestService(t, func(method string, params []json.RawMessage) interface{} {
+				switch method {
+				case "eth_getTransactionCount":
+					return "0x5"
+				case "realtime_getTransactionCount":
+					return test.realtimeNonce
+				}
+				return nil
+			})
+			service.nonceCache.Add(address)
+
+			mismatches := runComparisonRound(t, service.ProcessCompareNonceCache, service.nonceCache.Size, logs, "nonce mismatch")
+			if len(mismatches) != test.mismatches {
+				t.Fatalf("got %d nonce mismatches, want %d", len(mismatches), test.mismatches)
+			}
+			if test.mismatches > 0 && !strings.Contains(mismatches[0], "eth: 5, realtime: 6") {
+				t.Errorf("got mismatch %q, want eth 5, realtime 6", mismatches[0])
+			}
+		})
+	}
+}
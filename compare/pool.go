@@ -0,0 +1,52 @@
+package compare
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sieniven/realtime-compare-tool/rpc"
+)
+
+// WorkerPool runs the comparison jobs concurrently with bounded parallelism. The pool is shared by the compare
+// loops, so the workers bound the total number of comparisons in flight
+type WorkerPool struct {
+	workers chan struct{}
+}
+
+func NewWorkerPool(size int) *WorkerPool {
+	if size <= 0 {
+		size = 1
+	}
+	return &WorkerPool{
+		workers: make(chan struct{}, size),
+	}
+}
+
+// Run runs the job for each index in [0, count) on the pool workers, and waits for all the jobs to complete. Every
+// cache key is handled by a single job per compare round, which preserves the per key mismatch count semantics. Each
+// job runs with its own RPC call recorder, so the evidence of a mismatch holds the calls of the job that found it
+func (pool *WorkerPool) Run(ctx context.Context, count int, job func(ctx context.Context, i int)) {
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case pool.workers <- struct{}{}:
+		}
+		if ctx.Err() != nil {
+			// The context was cancelled while waiting for a worker
+			<-pool.workers
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-pool.workers
+				wg.Done()
+			}()
+			job(rpc.WithCallRecorder(ctx), i)
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,84 @@
+package compare
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPoolRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		count   int
+		maxSize int
+	}{
+		{"single worker", 1, 10, 1},
+		{"bounded workers", 3, 20, 3},
+		{"more workers than jobs", 10, 4, 4},
+		{"no jobs", 2, 0, 0},
+		{"non positive size", 0, 5, 1},
+	}
+	for _, test := range tests {
+		pool := NewWorkerPool(test.size)
+		var mu sync.Mutex
+		runs := make(map[int]int)
+		var running, maxRunning atomic.Int32
+		pool.Run(context.Background(), test.count, func(ctx context.Context, i int) {
+			current := running.Add(1)
+			for {
+				max := maxRunning.Load()
+				if current <= max || maxRunning.CompareAndSwap(max, current) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+
+			mu.Lock()
+			runs[i]++
+			mu.Unlock()
+		})
+
+		if len(runs) != test.count {
+			t.Errorf("%s: got %d jobs run, want %d", test.name, len(runs), test.count)
+		}
+		for i, count := range runs {
+			if count != 1 {
+				t.Errorf("%s: job %d ran %d times, want once", test.name, i, count)
+			}
+		}
+		if int(maxRunning.Load()) > test.maxSize {
+			t.Errorf("%s: got %d concurrent jobs, want at most %d", test.name, maxRunning.Load(), test.maxSize)
+		}
+	}
+}
+
+func TestWorkerPoolRunCancelled(t *testing.T) {
+	tests := []struct {
+		name      string
+		cancelled bool
+		runs      int32
+	}{
+		{"cancelled before run", true, 0},
+		{"cancelled by the first job", false, 1},
+	}
+	for _, test := range tests {
+		pool := NewWorkerPool(1)
+		ctx, cancel := context.WithCancel(context.Background())
+		if test.cancelled {
+			cancel()
+		}
+		var runs atomic.Int32
+		pool.Run(ctx, 10, func(ctx context.Context, i int) {
+			runs.Add(1)
+			cancel()
+		})
+		cancel()
+		if runs.Load() != test.runs {
+			t.Errorf("%s: got %d jobs run, want %d", test.name, runs.Load(), test.runs)
+		}
+	}
+}
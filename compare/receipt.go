@@ -0,0 +1,137 @@
+package compare
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/sieniven/realtime-compare-tool/metrics"
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+func (service *CompareService) ProcessCompareReceiptCache(ctx context.Context) {
+	for {
+		if !service.waitUntilComparing(ctx) {
+			return
+		}
+
+		txHashes := service.receiptCache.GetKeys()
+		service.workerPool.Run(ctx, len(txHashes), func(ctx context.Context, i int) {
+			txHash := txHashes[i]
+			// Only compare once the tx is mined on the eth node
+			ethReceipt, err := service.RpcClient.EthGetTransactionReceipt(ctx, txHash)
+			if err != nil {
+				service.Logger.Printf("error getting eth receipt for tx %s: %v\n", txHash, err)
+				return
+			}
+			if ethReceipt == nil {
+				return
+			}
+			realtimeReceipt, err := service.RpcClient.RealtimeGetTransactionReceipt(ctx, txHash)
+			if err != nil {
+				service.Logger.Printf("error getting realtime receipt for tx %s: %v\n", txHash, err)
+				return
+			}
+			if realtimeReceipt == nil {
+				realtimeHeight, err := service.RpcClient.RealtimeBlockNumber(ctx)
+				if err != nil {
+					service.Logger.Printf("error getting realtime height for tx %s: %v\n", txHash, err)
+					return
+				}
+				receiptHeight := ethReceipt.BlockNumber.Uint64()
+				if realtimeHeight < receiptHeight {
+					// Realtime has not reached the tx height yet, compare again in the next round
+					return
+				}
+				if realtimeHeight > receiptHeight+DefaultRealtimeReceiptWindow {
+					// The receipt is past the realtime cache window, so the missing receipt can not be compared
+					service.Logger.Printf("Realtime receipt is evicted at realtime height %d for tx %s at height %d, skipping compare\n", realtimeHeight, txHash, receiptHeight)
+					service.receiptCache.Remove(txHash)
+					return
+				}
+			}
+
+			// Run the receipt comparison
+			diffs := diffReceipts(ethReceipt, realtimeReceipt)
+			mismatch := store.Mismatch{
+				Comparator: "receipt",
+				Key:        txHash.Hex(),
+				Height:     ethReceipt.BlockNumber.Uint64(),
+				Diffs:      strings.Join(diffs, "; "),
+			}
+			switch settleComparison(ctx, service, service.receiptCache, txHash, len(diffs) == 0, mismatch) {
+			case metrics.ResultConfirmedMismatch:
+				service.Logger.Printf("Error in state comparator: receipt mismatch at height %d for tx %s, diffs: %s\n", ethReceipt.BlockNumber, txHash, strings.Join(diffs, "; "))
+			case metrics.ResultMatch:
+				service.Logger.Printf("Receipts are equal at height %d for tx %s\n", ethReceipt.BlockNumber, txHash)
+			}
+		})
+
+		time.Sleep(time.Duration(service.Config.CompareIntervalMS) * time.Millisecond)
+	}
+}
+
+// diffReceipts returns the description of every receipt field that diverged between the eth and realtime receipts.
+// A nil realtime receipt is only passed for txs within the realtime cache window, where the receipt is missing
+func diffReceipts(ethReceipt *types.Receipt, realtimeReceipt *types.Receipt) []string {
+	if realtimeReceipt == nil {
+		return []string{"missing realtime receipt"}
+	}
+	diffs := []string{}
+	if ethReceipt.Status != realtimeReceipt.Status {
+		diffs = append(diffs, fmt.Sprintf("status eth: %d, realtime: %d", ethReceipt.Status, realtimeReceipt.Status))
+	}
+	if ethReceipt.CumulativeGasUsed != realtimeReceipt.CumulativeGasUsed {
+		diffs = append(diffs, fmt.Sprintf("cumulativeGasUsed eth: %d, realtime: %d", ethReceipt.CumulativeGasUsed, realtimeReceipt.CumulativeGasUsed))
+	}
+	if ethReceipt.GasUsed != realtimeReceipt.GasUsed {
+		diffs = append(diffs, fmt.Sprintf("gasUsed eth: %d, realtime: %d", ethReceipt.GasUsed, realtimeReceipt.GasUsed))
+	}
+	if ethReceipt.ContractAddress != realtimeReceipt.ContractAddress {
+		diffs = append(diffs, fmt.Sprintf("contractAddress eth: %s, realtime: %s", ethReceipt.ContractAddress, realtimeReceipt.ContractAddress))
+	}
+	if ethReceipt.Bloom != realtimeReceipt.Bloom {
+		diffs = append(diffs, "logsBloom")
+	}
+	diffs = append(diffs, diffLogs(ethReceipt.Logs, realtimeReceipt.Logs)...)
+	return diffs
+}
+
+// diffLogs returns the description of every log field that diverged between the eth and realtime logs
+func diffLogs(ethLogs types.Logs, realtimeLogs types.Logs) []string {
+	diffs := []string{}
+	if len(ethLogs) != len(realtimeLogs) {
+		diffs = append(diffs, fmt.Sprintf("logs length eth: %d, realtime: %d", len(ethLogs), len(realtimeLogs)))
+	}
+	for i := 0; i < len(ethLogs) && i < len(realtimeLogs); i++ {
+		ethLog, realtimeLog := ethLogs[i], realtimeLogs[i]
+		if ethLog.Address != realtimeLog.Address {
+			diffs = append(diffs, fmt.Sprintf("logs[%d].address eth: %s, realtime: %s", i, ethLog.Address, realtimeLog.Address))
+		}
+		if !equalTopics(ethLog, realtimeLog) {
+			diffs = append(diffs, fmt.Sprintf("logs[%d].topics eth: %v, realtime: %v", i, ethLog.Topics, realtimeLog.Topics))
+		}
+		if !bytes.Equal(ethLog.Data, realtimeLog.Data) {
+			diffs = append(diffs, fmt.Sprintf("logs[%d].data eth: 0x%x, realtime: 0x%x", i, ethLog.Data, realtimeLog.Data))
+		}
+		if ethLog.Index != realtimeLog.Index {
+			diffs = append(diffs, fmt.Sprintf("logs[%d].logIndex eth: %d, realtime: %d", i, ethLog.Index, realtimeLog.Index))
+		}
+	}
+	return diffs
+}
+
+func equalTopics(ethLog *types.Log, realtimeLog *types.Log) bool {
+	if len(ethLog.Topics) != len(realtimeLog.Topics) {
+		return false
+	}
+	for i := range ethLog.Topics {
+		if ethLog.Topics[i] != realtimeLog.Topics[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,152 @@
+package compare
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/core/types"
+)
+
+func TestDiffReceipts(t *testing.T) {
+	newReceipt := func() *types.Receipt {
+		return &types.Receipt{
+			Status:            1,
+			CumulativeGasUsed: 42000,
+			GasUsed:           21000,
+			Logs: types.Logs{
+				{Address: common.Address{1}, Topics: []common.Hash{{2}}, Data: []byte{3}, Index: 0},
+			},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(receipt *types.Receipt) *types.Receipt
+		diffs  []string
+	}{
+		{
+			name:   "equal",
+			modify: func(receipt *types.Receipt) *types.Receipt { return receipt },
+			diffs:  []string{},
+		},
+		{
+			name:   "missing realtime receipt",
+			modify: func(receipt *types.Receipt) *types.Receipt { return nil },
+			diffs:  []string{"missing realtime receipt"},
+		},
+		{
+			name: "status and gas",
+			modify: func(receipt *types.Receipt) *types.Receipt {
+				receipt.Status = 0
+				receipt.CumulativeGasUsed = 43000
+				receipt.GasUsed = 22000
+				return receipt
+			},
+			diffs: []string{
+				"status eth: 1, realtime: 0",
+				"cumulativeGasUsed eth: 42000, realtime: 43000",
+				"gasUsed eth: 21000, realtime: 22000",
+			},
+		},
+		{
+			name: "bloom",
+			modify: func(receipt *types.Receipt) *types.Receipt {
+				receipt.Bloom[0] = 1
+				return receipt
+			},
+			diffs: []string{"logsBloom"},
+		},
+		{
+			name: "log fields",
+			modify: func(receipt *types.Receipt) *types.Receipt {
+				receipt.Logs[0] = &types.Log{Address: common.Address{1}, Topics: []common.Hash{{4}}, Data: []byte{5}, Index: 1}
+				return receipt
+			},
+			diffs: []string{
+				"logs[0].topics eth: [0x0200000000000000000000000000000000000000000000000000000000000000], realtime: [0x0400000000000000000000000000000000000000000000000000000000000000]",
+				"logs[0].data eth: 0x03, realtime: 0x05",
+				"logs[0].logIndex eth: 0, realtime: 1",
+			},
+		},
+		{
+			name: "missing log",
+			modify: func(receipt *types.Receipt) *types.Receipt {
+				receipt.Logs = types.Logs{}
+				return receipt
+			},
+			diffs: []string{"logs length eth: 1, realtime: 0"},
+		},
+	}
+	for _, test := range tests {
+		diffs := diffReceipts(newReceipt(), test.modify(newReceipt()))
+		if !reflect.DeepEqual(diffs, test.diffs) {
+			t.Errorf("%s: got diffs %q, want %q", test.name, diffs, test.diffs)
+		}
+	}
+}
+
+func TestCompareServiceReceiptRound(t *testing.T) {
+	txHash := common.HexToHash("0x0a")
+	ethReceipt := &types.Receipt{Status: 1, CumulativeGasUsed: 21000, GasUsed: 21000, Logs: types.Logs{}, TxHash: txHash, BlockNumber: big.NewInt(100)}
+	failedReceipt := *ethReceipt
+	failedReceipt.Status = 0
+
+	tests := []struct {
+		name           string
+		realtime       *types.Receipt
+		realtimeHeight string
+		// Diffs of the recorded mismatch, empty if no mismatch is recorded
+		diffs string
+		// Whether the tx is left in the cache for a later round
+		pending bool
+	}{
+		{name: "equal receipts", realtime: ethReceipt, realtimeHeight: "0x64"},
+		{name: "status mismatch", realtime: &failedReceipt, realtimeHeight: "0x64", diffs: "status eth: 1, realtime: 0"},
+		{name: "missing within realtime window", realtimeHeight: "0x65", diffs: "missing realtime receipt"},
+		{name: "evicted past realtime window", realtimeHeight: "0x400"},
+		{name: "realtime behind the tx height", realtimeHeight: "0x63", pending: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var realtimeHeightReads atomic.Int32
+			service, logs := newTestService(t, func(method string, params []json.RawMessage) interface{} {
+				switch method {
+				case "eth_getTransactionReceipt":
+					return ethReceipt
+				case "realtime_getTransactionReceipt":
+					return test.realtime
+				case "realtime_blockNumber":
+					realtimeHeightReads.Add(1)
+					return test.realtimeHeight
+				}
+				return nil
+			})
+			service.receiptCache.Add(txHash)
+
+			runComparisons(t, service.ProcessCompareReceiptCache, func() bool {
+				if test.pending {
+					return realtimeHeightReads.Load() >= 3
+				}
+				return service.receiptCache.Size() == 0
+			})
+			if pending := service.receiptCache.Size() == 1; pending != test.pending {
+				t.Errorf("got pending %v, want %v", pending, test.pending)
+			}
+			mismatches := logs.find("receipt mismatch")
+			if test.diffs == "" {
+				if len(mismatches) != 0 {
+					t.Errorf("got mismatches %q, want none", mismatches)
+				}
+				return
+			}
+			if len(mismatches) != 1 || !strings.Contains(mismatches[0], "at height 100") || !strings.HasSuffix(mismatches[0], "diffs: "+test.diffs+"\n") {
+				t.Errorf("got mismatches %q, want a mismatch at height 100 with diffs %q", mismatches, test.diffs)
+			}
+		})
+	}
+}
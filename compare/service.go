@@ -3,30 +3,91 @@ package compare
 import (
 	"context"
 	"log"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/sieniven/realtime-compare-tool/alert"
 	"github.com/sieniven/realtime-compare-tool/kafka"
 	"github.com/sieniven/realtime-compare-tool/rpc"
+	"github.com/sieniven/realtime-compare-tool/store"
 )
 
 type CompareService struct {
 	InitFlag   atomic.Bool
+	SyncFlag   atomic.Bool
+	PauseFlag  atomic.Bool
 	NodeHeight atomic.Int64
 	Config     CompareConfig
 
+	// Guards the skip addresses, which can be updated through the admin API
+	skipMu sync.RWMutex
+
 	KafkaConsumer *kafka.KafkaConsumer
 	RpcClient     *rpc.RealtimeClient
+	WsClient      *rpc.WsClient
 	Logger        *log.Logger
 
 	// Compare cache
 	balanceCache   *CompareBalanceCache
 	addrTokenCache *CompareAddrTokenCache
+	nonceCache     *CompareCountCache[common.Address]
+	codeCache      *CompareCountCache[common.Address]
+	storageCache   *CompareNestedCountCache[common.Address, common.Hash]
+	receiptCache   *CompareCountCache[common.Hash]
+	innerTxCache   *CompareCountCache[common.Hash]
+	blockCache     *CompareCountCache[uint64]
+	logsCache      *CompareCountCache[uint64]
+	txCache        *CompareCountCache[common.Hash]
+	callCache      *CompareCountCache[string]
+	tokenCache     *CompareCountCache[common.Address]
+	allowanceCache *CompareNestedCountCache[common.Address, AllowancePair]
+	// Highest height queued for the block and logs comparisons
+	queuedHeight atomic.Uint64
+
+	// Realtime subscription compare caches
+	txNotificationCache      *CompareTxNotificationCache
+	balanceNotificationCache *CompareBalanceNotificationCache
+	missedTxCache            *CompareMissedTxCache
+	notifiedTxs              *NotifiedTxSet
+	// Number of the live realtime tx subscription, zero while the subscription is down
+	txSubscription atomic.Uint64
+
+	// Nft compare caches
+	erc721OwnerCache    *CompareNestedCountCache[common.Address, NftKey]
+	erc721BalanceCache  *CompareNestedCountCache[common.Address, NftKey]
+	erc1155BalanceCache *CompareNestedCountCache[common.Address, NftKey]
+
+	// Configured contract view calls by name
+	contractCalls map[string]ContractCall
+
+	// Lag monitor
+	lagHistory *LagHistory
+
+	// Confirmed mismatch history and alerts, nil if disabled
+	mismatchStore *store.MismatchStore
+	alerter       *alert.Alerter
+
+	// Mismatch evidence
+	kafkaHistory       *KafkaMessageHistory
+	lastStateCacheDump atomic.Int64
+	// Bounds the concurrent evidence captures
+	evidenceSlots chan struct{}
+
+	// Comparison job workers
+	workerPool *WorkerPool
 
 	// Channels
 	HeightChan      chan int64
+	WsHeightChan    chan int64
 	AddrBalanceChan chan common.Address
 	TokenHolderChan chan kafka.TokenHolderData
+	ContractChan    chan common.Address
+	StorageChan     chan kafka.StorageData
+	TxHashChan      chan common.Hash
+	ApprovalChan    chan kafka.ApprovalData
+	NftTransferChan chan kafka.NftTransferData
 	ErrorChan       chan error
 }
 
@@ -35,10 +96,46 @@ func NewCompareService(config CompareConfig, logger *log.Logger) (*CompareServic
 	if err != nil {
 		return nil, err
 	}
-	rpcClient, err := rpc.NewRealtimeClient(config.Rpc.RpcUrl)
+	mismatchStore, err := newMismatchStore(config)
 	if err != nil {
 		return nil, err
 	}
+	return newCompareService(config, config.Rpc.RealtimeUrls[0], kafkaConsumer, newTransport(config, logger), NewWorkerPool(config.CompareWorkers), mismatchStore, newAlerter(config, logger), logger)
+}
+
+// newTransport creates the RPC transport with the configured policy, reporting when an endpoint goes down or
+// recovers
+func newTransport(config CompareConfig, logger *log.Logger) *rpc.Transport {
+	transport := rpc.NewTransport(rpc.TransportConfig{
+		Timeout:          time.Duration(config.Rpc.TimeoutMS) * time.Millisecond,
+		MaxRetries:       config.Rpc.MaxRetries,
+		RetryBackoff:     time.Duration(config.Rpc.RetryBackoffMS) * time.Millisecond,
+		BreakerThreshold: config.Rpc.BreakerThreshold,
+		BreakerCooldown:  time.Duration(config.Rpc.BreakerCooldownMS) * time.Millisecond,
+		EndpointLimit:    config.Rpc.EndpointLimit,
+	})
+	transport.OnBreakerChange(func(url string, open bool) {
+		if open {
+			logger.Printf("Error in rpc transport: endpoint %s is down, pausing comparisons\n", url)
+		} else {
+			logger.Printf("endpoint %s recovered, resuming comparisons\n", url)
+		}
+	})
+	return transport
+}
+
+// newCompareService creates the compare service of the realtime node. The kafka consumer is nil for the nodes of
+// a fleet, which are fed by the fleet kafka consumer instead, and the transport, worker pool, mismatch store and
+// alerter are shared across the fleet
+func newCompareService(config CompareConfig, realtimeUrl string, kafkaConsumer *kafka.KafkaConsumer, transport *rpc.Transport, workerPool *WorkerPool, mismatchStore *store.MismatchStore, alerter *alert.Alerter, logger *log.Logger) (*CompareService, error) {
+	rpcClient, err := rpc.NewRealtimeClient(realtimeUrl, config.Rpc.ReferenceUrl, config.Rpc.BatchSize, transport)
+	if err != nil {
+		return nil, err
+	}
+	var wsClient *rpc.WsClient
+	if config.Rpc.WsUrl != "" {
+		wsClient = rpc.NewWsClient(config.Rpc.WsUrl)
+	}
 	balanceCache, err := NewCompareBalanceCache()
 	if err != nil {
 		return nil, err
@@ -47,85 +144,376 @@ func NewCompareService(config CompareConfig, logger *log.Logger) (*CompareServic
 	if err != nil {
 		return nil, err
 	}
+	nonceCache, err := NewCompareCountCache[common.Address]()
+	if err != nil {
+		return nil, err
+	}
+	codeCache, err := NewCompareCountCache[common.Address]()
+	if err != nil {
+		return nil, err
+	}
+	storageCache, err := NewCompareNestedCountCache[common.Address, common.Hash]()
+	if err != nil {
+		return nil, err
+	}
+	receiptCache, err := NewCompareCountCache[common.Hash]()
+	if err != nil {
+		return nil, err
+	}
+	innerTxCache, err := NewCompareCountCache[common.Hash]()
+	if err != nil {
+		return nil, err
+	}
+	blockCache, err := NewCompareCountCache[uint64]()
+	if err != nil {
+		return nil, err
+	}
+	logsCache, err := NewCompareCountCache[uint64]()
+	if err != nil {
+		return nil, err
+	}
+	txCache, err := NewCompareCountCache[common.Hash]()
+	if err != nil {
+		return nil, err
+	}
+	callCache, err := NewCompareCountCache[string]()
+	if err != nil {
+		return nil, err
+	}
+	tokenCache, err := NewCompareCountCache[common.Address]()
+	if err != nil {
+		return nil, err
+	}
+	allowanceCache, err := NewCompareNestedCountCache[common.Address, AllowancePair]()
+	if err != nil {
+		return nil, err
+	}
+	txNotificationCache, err := NewCompareTxNotificationCache()
+	if err != nil {
+		return nil, err
+	}
+	balanceNotificationCache, err := NewCompareBalanceNotificationCache()
+	if err != nil {
+		return nil, err
+	}
+	missedTxCache, err := NewCompareMissedTxCache()
+	if err != nil {
+		return nil, err
+	}
+	notifiedTxs, err := NewNotifiedTxSet()
+	if err != nil {
+		return nil, err
+	}
+	erc721OwnerCache, err := NewCompareNestedCountCache[common.Address, NftKey]()
+	if err != nil {
+		return nil, err
+	}
+	erc721BalanceCache, err := NewCompareNestedCountCache[common.Address, NftKey]()
+	if err != nil {
+		return nil, err
+	}
+	erc1155BalanceCache, err := NewCompareNestedCountCache[common.Address, NftKey]()
+	if err != nil {
+		return nil, err
+	}
+	kafkaHistory, err := NewKafkaMessageHistory(DefaultKafkaHistorySize, DefaultKafkaHistoryPerKey)
+	if err != nil {
+		return nil, err
+	}
+	contractCalls := make(map[string]ContractCall)
+	if config.CallConfigFile != "" {
+		calls, err := LoadContractCalls(config.CallConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, call := range calls {
+			contractCalls[call.Name] = call
+		}
+	}
+
+	service := &CompareService{
+		InitFlag:                 atomic.Bool{},
+		SyncFlag:                 atomic.Bool{},
+		PauseFlag:                atomic.Bool{},
+		NodeHeight:               atomic.Int64{},
+		Config:                   config,
+		KafkaConsumer:            kafkaConsumer,
+		RpcClient:                rpcClient,
+		WsClient:                 wsClient,
+		Logger:                   logger,
+		balanceCache:             balanceCache,
+		addrTokenCache:           addrTokenCache,
+		nonceCache:               nonceCache,
+		codeCache:                codeCache,
+		storageCache:             storageCache,
+		receiptCache:             receiptCache,
+		innerTxCache:             innerTxCache,
+		blockCache:               blockCache,
+		logsCache:                logsCache,
+		txCache:                  txCache,
+		callCache:                callCache,
+		tokenCache:               tokenCache,
+		allowanceCache:           allowanceCache,
+		txNotificationCache:      txNotificationCache,
+		balanceNotificationCache: balanceNotificationCache,
+		missedTxCache:            missedTxCache,
+		notifiedTxs:              notifiedTxs,
+		erc721OwnerCache:         erc721OwnerCache,
+		erc721BalanceCache:       erc721BalanceCache,
+		erc1155BalanceCache:      erc1155BalanceCache,
+		contractCalls:            contractCalls,
+		lagHistory:               NewLagHistory(DefaultLagHistorySize),
+		mismatchStore:            mismatchStore,
+		alerter:                  alerter,
+		kafkaHistory:             kafkaHistory,
+		evidenceSlots:            make(chan struct{}, DefaultEvidenceConcurrency),
+		workerPool:               workerPool,
+		HeightChan:               make(chan int64, DefaultChannelSize),
+		WsHeightChan:             make(chan int64, DefaultChannelSize),
+		AddrBalanceChan:          make(chan common.Address, DefaultChannelSize),
+		TokenHolderChan:          make(chan kafka.TokenHolderData, DefaultChannelSize),
+		ContractChan:             make(chan common.Address, DefaultChannelSize),
+		StorageChan:              make(chan kafka.StorageData, DefaultChannelSize),
+		TxHashChan:               make(chan common.Hash, DefaultChannelSize),
+		ApprovalChan:             make(chan kafka.ApprovalData, DefaultChannelSize),
+		NftTransferChan:          make(chan kafka.NftTransferData, DefaultChannelSize),
+		ErrorChan:                make(chan error, DefaultChannelSize),
+	}
+	// Comparisons run until the lag monitor detects realtime is out of sync
+	service.SyncFlag.Store(true)
 
-	return &CompareService{
-		InitFlag:        atomic.Bool{},
-		NodeHeight:      atomic.Int64{},
-		Config:          config,
-		KafkaConsumer:   kafkaConsumer,
-		RpcClient:       rpcClient,
-		Logger:          logger,
-		balanceCache:    balanceCache,
-		addrTokenCache:  addrTokenCache,
-		HeightChan:      make(chan int64, DefaultChannelSize),
-		AddrBalanceChan: make(chan common.Address, DefaultChannelSize),
-		TokenHolderChan: make(chan kafka.TokenHolderData, DefaultChannelSize),
-		ErrorChan:       make(chan error, DefaultChannelSize),
-	}, nil
+	return service, nil
 }
 
 func (service *CompareService) Start(ctx context.Context) error {
 	// Start the kafka consumer goroutine
-	go service.KafkaConsumer.ConsumeKafka(ctx, service.HeightChan, service.AddrBalanceChan, service.TokenHolderChan, service.ErrorChan, service.Logger)
+	go service.KafkaConsumer.ConsumeKafka(ctx, service.HeightChan, service.AddrBalanceChan, service.TokenHolderChan, service.ContractChan, service.StorageChan, service.TxHashChan, service.ApprovalChan, service.NftTransferChan, service.ErrorChan, service.Logger)
+	if service.Config.AdminAddr != "" {
+		NewAdminServer([]*CompareService{service}, service.Logger).Start(ctx, service.Config.AdminAddr)
+	}
+	if service.alerter != nil {
+		go service.alerter.Run(ctx)
+	}
+	return service.run(ctx)
+}
+
+// run starts the comparators and dispatches the kafka messages received on the service channels to the caches
+func (service *CompareService) run(ctx context.Context) error {
 	go service.ProcessCompareBalanceCache(ctx)
 	go service.ProcessCompareAddrTokenCache(ctx)
+	go service.ProcessCompareNonceCache(ctx)
+	go service.ProcessCompareCodeCache(ctx)
+	go service.ProcessCompareStorageCache(ctx)
+	go service.ProcessCompareReceiptCache(ctx)
+	go service.ProcessCompareInnerTxCache(ctx)
+	go service.ProcessCompareBlockCache(ctx)
+	go service.ProcessCompareLogsCache(ctx)
+	go service.ProcessCompareTxCache(ctx)
+	go service.ProcessCompareTokenCache(ctx)
+	go service.ProcessCompareAllowanceCache(ctx)
+	go service.ProcessCompareERC721OwnerCache(ctx)
+	go service.ProcessCompareERC721BalanceCache(ctx)
+	go service.ProcessCompareERC1155BalanceCache(ctx)
+	go service.ProcessLagMonitor(ctx)
+	if service.WsClient != nil {
+		go service.ProcessNewHeads(ctx)
+		if service.Config.SubscriptionCompare {
+			go service.ProcessRealtimeSubscriptions(ctx)
+			go service.ProcessCompareTxNotificationCache(ctx)
+			go service.ProcessCompareBalanceNotificationCache(ctx)
+			go service.ProcessCompareMissedTxCache(ctx)
+		}
+	}
+	if len(service.contractCalls) > 0 {
+		go service.ProcessContractCallTicker(ctx)
+		go service.ProcessCompareCallCache(ctx)
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			return ErrCtxCancelled
 		case height := <-service.HeightChan:
-			if service.NodeHeight.Load() < height {
-				service.NodeHeight.Store(height)
-				if !service.InitFlag.Load() {
-					// Try to init compare service
-					ethHeight, err := service.RpcClient.EthGetBlockNumber(ctx)
-					if err != nil {
-						service.Logger.Printf("error getting node height from rpc client: %v\n", err)
-						continue
-					}
-					diff := int64(ethHeight) - height
-					if diff < 0 {
-						diff = -diff
-					}
-					if diff < DefaultHeightSyncRange {
-						service.InitFlag.Store(true)
-						service.Logger.Println("node heights initialized, starting compare")
-					}
-				}
+			if service.Config.Rpc.WsHeightSource {
+				// The websocket heads drive the height tracking, mixing in the kafka heights could move the node
+				// height backwards
+				continue
 			}
+			service.onHeight(ctx, height)
+		case height := <-service.WsHeightChan:
+			service.onHeight(ctx, height)
 		case address := <-service.AddrBalanceChan:
+			service.kafkaHistory.Add(kafka.AddressMessageType, address, address.Hex())
 			if !service.InitFlag.Load() {
 				continue
 			}
-			skipFlag := false
-			for _, skipAddress := range service.Config.SkipAddresses {
-				if address.Hex() == skipAddress.Hex() {
-					skipFlag = true
-					break
-				}
-			}
-			if skipFlag {
+			if service.isSkipAddress(address) {
 				continue
 			}
 			service.balanceCache.Add(address)
+			service.nonceCache.Add(address)
+			service.codeCache.Add(address)
+			service.addContractCalls(address)
 		case tokenHolder := <-service.TokenHolderChan:
+			service.kafkaHistory.Add(kafka.TokenHolderMessageType, tokenHolder, tokenHolder.Address.Hex(), tokenHolder.TokenAddress.Hex())
 			if !service.InitFlag.Load() {
 				continue
 			}
-			skipFlag := false
-			for _, skipAddress := range service.Config.SkipAddresses {
-				if tokenHolder.Address.Hex() == skipAddress.Hex() {
-					skipFlag = true
-					break
-				}
-			}
-			if skipFlag {
+			if service.isSkipAddress(tokenHolder.Address) {
 				continue
 			}
 			service.addrTokenCache.Add(tokenHolder.TokenAddress, tokenHolder.Address)
+			service.tokenCache.Add(tokenHolder.TokenAddress)
+			service.addContractCalls(tokenHolder.TokenAddress)
+		case address := <-service.ContractChan:
+			service.kafkaHistory.Add(kafka.ContractMessageType, address, address.Hex())
+			if !service.InitFlag.Load() {
+				continue
+			}
+			if service.isSkipAddress(address) {
+				continue
+			}
+			service.codeCache.Add(address)
+			service.addContractCalls(address)
+		case storage := <-service.StorageChan:
+			service.kafkaHistory.Add(kafka.StorageMessageType, storage, storage.Address.Hex(), storage.Slot.Hex())
+			if !service.InitFlag.Load() {
+				continue
+			}
+			if service.isSkipAddress(storage.Address) {
+				continue
+			}
+			service.storageCache.Add(storage.Address, storage.Slot)
+			service.addContractCalls(storage.Address)
+		case txHash := <-service.TxHashChan:
+			service.kafkaHistory.Add(kafka.TransactionMessageType, txHash, txHash.Hex())
+			if !service.InitFlag.Load() {
+				continue
+			}
+			service.receiptCache.Add(txHash)
+			service.innerTxCache.Add(txHash)
+			service.txCache.Add(txHash)
+			// Missed notifications can only be checked while the tx subscription is live
+			if subscription := service.txSubscription.Load(); subscription != 0 && service.Config.SubscriptionCompare {
+				service.missedTxCache.Add(txHash, uint64(service.NodeHeight.Load()), subscription)
+			}
+		case approval := <-service.ApprovalChan:
+			service.kafkaHistory.Add(kafka.ApprovalMessageType, approval, approval.Owner.Hex(), approval.Spender.Hex(), approval.TokenAddress.Hex())
+			if !service.InitFlag.Load() {
+				continue
+			}
+			if service.isSkipAddress(approval.Owner) {
+				continue
+			}
+			service.allowanceCache.Add(approval.TokenAddress, AllowancePair{Owner: approval.Owner, Spender: approval.Spender})
+			service.tokenCache.Add(approval.TokenAddress)
+		case transfer := <-service.NftTransferChan:
+			service.kafkaHistory.Add(transfer.Type, transfer, transfer.From.Hex(), transfer.To.Hex(), transfer.TokenAddress.Hex(), common.BigToHash(transfer.TokenID).Hex())
+			if !service.InitFlag.Load() {
+				continue
+			}
+			service.addNftTransfer(transfer)
 		case err := <-service.ErrorChan:
 			return err
 		}
 	}
 }
+
+// onHeight tracks the new block height, initializing the comparisons once the eth node is in range, and queues the
+// block level comparisons of the height. Stale and duplicate heights from kafka or the websocket heads are not
+// queued again
+func (service *CompareService) onHeight(ctx context.Context, height int64) {
+	if service.NodeHeight.Load() < height {
+		service.NodeHeight.Store(height)
+		if !service.InitFlag.Load() {
+			// Try to init compare service
+			ethHeight, err := service.RpcClient.EthGetBlockNumber(ctx)
+			if err != nil {
+				service.Logger.Printf("error getting node height from rpc client: %v\n", err)
+				return
+			}
+			diff := int64(ethHeight) - height
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff < DefaultHeightSyncRange {
+				service.InitFlag.Store(true)
+				service.Logger.Println("node heights initialized, starting compare")
+			}
+		}
+	}
+	if service.InitFlag.Load() && uint64(height) > service.queuedHeight.Load() {
+		service.queuedHeight.Store(uint64(height))
+		service.blockCache.Add(uint64(height))
+		service.logsCache.Add(uint64(height))
+	}
+}
+
+// isComparing returns true if realtime is in sync, both the realtime and reference endpoints are reachable, and the
+// comparators are not paused through the admin API
+func (service *CompareService) isComparing() bool {
+	return service.SyncFlag.Load() && !service.PauseFlag.Load() && service.RpcClient.IsAvailable()
+}
+
+// waitUntilComparing blocks while the comparisons are paused, returning false if the context is cancelled
+func (service *CompareService) waitUntilComparing(ctx context.Context) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		default:
+		}
+		if service.isComparing() {
+			return true
+		}
+		time.Sleep(time.Duration(service.Config.CompareIntervalMS) * time.Millisecond)
+	}
+}
+
+func (service *CompareService) isSkipAddress(address common.Address) bool {
+	service.skipMu.RLock()
+	defer service.skipMu.RUnlock()
+
+	for _, skipAddress := range service.Config.SkipAddresses {
+		if address.Hex() == skipAddress.Hex() {
+			return true
+		}
+	}
+	return false
+}
+
+func (service *CompareService) GetSkipAddresses() []common.Address {
+	service.skipMu.RLock()
+	defer service.skipMu.RUnlock()
+
+	addresses := make([]common.Address, len(service.Config.SkipAddresses))
+	copy(addresses, service.Config.SkipAddresses)
+	return addresses
+}
+
+// AddSkipAddress skips the comparisons of the address from now on, the address pending comparisons still run
+func (service *CompareService) AddSkipAddress(address common.Address) {
+	service.skipMu.Lock()
+	defer service.skipMu.Unlock()
+
+	for _, skipAddress := range service.Config.SkipAddresses {
+		if skipAddress == address {
+			return
+		}
+	}
+	service.Config.SkipAddresses = append(service.Config.SkipAddresses, address)
+}
+
+// RemoveSkipAddress resumes the comparisons of the address, and returns false if the address was not skipped
+func (service *CompareService) RemoveSkipAddress(address common.Address) bool {
+	service.skipMu.Lock()
+	defer service.skipMu.Unlock()
+
+	for i, skipAddress := range service.Config.SkipAddresses {
+		if skipAddress == address {
+			service.Config.SkipAddresses = append(service.Config.SkipAddresses[:i:i], service.Config.SkipAddresses[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
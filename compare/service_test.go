@@ -0,0 +1,184 @@
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sieniven/realtime-compare-tool/rpc"
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+// rpcHandler returns the result of a JSON-RPC method call, nil for a null result or a *testRpcError for an error
+type rpcHandler func(method string, params []json.RawMessage) interface{}
+
+type testRpcRequest struct {
+	ID     uint64            `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type testRpcResponse struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Result  interface{}   `json:"result"`
+	Error   *testRpcError `json:"error,omitempty"`
+}
+
+// testRpcError is returned by the rpc handler to answer the call with a JSON-RPC error
+type testRpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// newRpcServer starts a JSON-RPC stand-in for the realtime and reference nodes, answering both single and batch
+// requests with the handler
+func newRpcServer(t *testing.T, handler rpcHandler) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		respond := func(request testRpcRequest) testRpcResponse {
+			result := handler(request.Method, request.Params)
+			if rpcErr, ok := result.(*testRpcError); ok {
+				return testRpcResponse{JSONRPC: "2.0", ID: request.ID, Error: rpcErr}
+			}
+			return testRpcResponse{JSONRPC: "2.0", ID: request.ID, Result: result}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if len(body) > 0 && body[0] == '[' {
+			var requests []testRpcRequest
+			if err := json.Unmarshal(body, &requests); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			responses := make([]testRpcResponse, 0, len(requests))
+			for _, request := range requests {
+				responses = append(responses, respond(request))
+			}
+			json.NewEncoder(w).Encode(responses)
+			return
+		}
+		var request testRpcRequest
+		if err := json.Unmarshal(body, &request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(respond(request))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// testLog keeps the lines logged by the compare service
+type testLog struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *testLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, string(p))
+	return len(p), nil
+}
+
+// find returns the logged lines containing the substring
+func (l *testLog) find(substr string) []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var lines []string
+	for _, line := range l.lines {
+		if strings.Contains(line, substr) {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// newTestService creates a compare service against the rpc stand-in, keeping the logged lines. Mismatches are
+// confirmed on the second comparison
+func newTestService(t *testing.T, handler rpcHandler) (*CompareService, *testLog) {
+	server := newRpcServer(t, handler)
+	config := CompareConfig{
+		Rpc: RpcConfig{
+			RpcUrl:       server.URL,
+			RealtimeUrls: []string{server.URL},
+			ReferenceUrl: server.URL,
+			BatchSize:    DefaultBatchSize,
+			TimeoutMS:    1000,
+		},
+		CompareWorkers:    2,
+		CompareIntervalMS: 5,
+	}
+	logs := &testLog{}
+	transport := rpc.NewTransport(rpc.TransportConfig{Timeout: time.Second})
+	service, err := newCompareService(config, server.URL, nil, transport, NewWorkerPool(config.CompareWorkers), nil, nil, log.New(logs, "", 0))
+	if err != nil {
+		t.Fatalf("create compare service: %v", err)
+	}
+	return service, logs
+}
+
+// newTestMismatchStore opens a mismatch store in a temporary directory
+func newTestMismatchStore(t *testing.T) *store.MismatchStore {
+	mismatchStore, err := store.NewMismatchStore(filepath.Join(t.TempDir(), "mismatches.db"))
+	if err != nil {
+		t.Fatalf("open mismatch store: %v", err)
+	}
+	return mismatchStore
+}
+
+// runComparisons runs the comparator loop until done returns true, failing the test if it does not within a few
+// seconds
+func runComparisons(t *testing.T, process func(ctx context.Context), done func() bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		process(ctx)
+	}()
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatal("comparator did not finish the comparison round")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// runComparisonRound runs the comparator loop until its cache is settled, and returns the logged lines containing
+// the mismatch substring
+func runComparisonRound(t *testing.T, process func(ctx context.Context), size func() int, logs *testLog, mismatch string) []string {
+	runComparisons(t, process, func() bool {
+		return size() == 0
+	})
+	return logs.find(mismatch)
+}
+
+// expectMismatch fails the test unless a single mismatch is logged, containing the wanted description
+func expectMismatch(t *testing.T, mismatches []string, want string) {
+	t.Helper()
+	if len(mismatches) != 1 {
+		t.Fatalf("got mismatches %q, want a single mismatch %q", mismatches, want)
+	}
+	if !strings.Contains(mismatches[0], want) {
+		t.Errorf("got mismatch %q, want the mismatch %q", mismatches[0], want)
+	}
+}
@@ -0,0 +1,74 @@
+package compare
+
+import (
+	"context"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/sieniven/realtime-compare-tool/metrics"
+	"github.com/sieniven/realtime-compare-tool/rpc"
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+func (service *CompareService) ProcessCompareStorageCache(ctx context.Context) {
+	for {
+		if !service.waitUntilComparing(ctx) {
+			return
+		}
+		addresses := service.storageCache.GetKeys()
+		service.workerPool.Run(ctx, len(addresses), func(ctx context.Context, i int) {
+			address := addresses[i]
+			slots := service.storageCache.GetSubKeys(address)
+			for _, slot := range slots {
+				// Run the storage slot comparison
+				height, ethValue, realtimeValue, err := service.getStorageValues(ctx, address, slot)
+				if err != nil {
+					service.Logger.Printf("error getting storage values for address %s and slot %s: %v\n", address, slot, err)
+					continue
+				}
+				mismatch := store.Mismatch{
+					Comparator: "storage",
+					Address:    address.Hex(),
+					Key:        slot.Hex(),
+					Height:     height,
+					Eth:        ethValue.Hex(),
+					Realtime:   realtimeValue.Hex(),
+				}
+				switch settleComparison(ctx, service, service.storageCache.subKeyCache(address), slot, ethValue == realtimeValue, mismatch) {
+				case metrics.ResultConfirmedMismatch:
+					service.Logger.Printf("Error in state comparator: storage mismatch at height %d for address %s and slot %s, eth: %s, realtime: %s\n", height, address, slot, ethValue, realtimeValue)
+				case metrics.ResultMatch:
+					service.Logger.Printf("Storage values are equal at height %d for address %s and slot %s\n", height, address, slot)
+				}
+			}
+		})
+
+		time.Sleep(time.Duration(service.Config.CompareIntervalMS) * time.Millisecond)
+	}
+}
+
+// getStorageValues returns the eth and realtime values of the storage slot, along with the height they were
+// compared at. The values are normalized to 32 bytes, as nodes may trim leading zeroes differently
+func (service *CompareService) getStorageValues(ctx context.Context, address common.Address, slot common.Hash) (uint64, common.Hash, common.Hash, error) {
+	if !service.Config.HeightConsistent {
+		ethValue, err := service.RpcClient.EthGetStorageAt(ctx, address, slot.Hex(), "latest")
+		if err != nil {
+			return 0, common.Hash{}, common.Hash{}, err
+		}
+		realtimeValue, err := service.RpcClient.RealtimeGetStorageAt(ctx, address, slot.Hex())
+		if err != nil {
+			return 0, common.Hash{}, common.Hash{}, err
+		}
+		return uint64(service.NodeHeight.Load()), common.HexToHash(ethValue), common.HexToHash(realtimeValue), nil
+	}
+
+	return readAtConsistentHeight(ctx, service,
+		func() (common.Hash, uint64, error) {
+			realtimeValue, height, err := service.RpcClient.RealtimeGetStorageAtHeight(ctx, address, slot.Hex())
+			return common.HexToHash(realtimeValue), height, err
+		},
+		func(height uint64) (common.Hash, error) {
+			ethValue, err := service.RpcClient.EthGetStorageAt(ctx, address, slot.Hex(), rpc.BlockNumberToHex(height))
+			return common.HexToHash(ethValue), err
+		})
+}
@@ -0,0 +1,49 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+)
+
+func TestCompareServiceStorageRound(t *testing.T) {
+	address := common.HexToAddress("0x00000000000000000000000000000000000000d1")
+	trimmedSlot, laggingSlot, divergedSlot := common.HexToHash("0x01"), common.HexToHash("0x02"), common.HexToHash("0x03")
+	var laggingReads atomic.Int32
+	service, logs := newTestService(t, func(method string, params []json.RawMessage) interface{} {
+		var slot string
+		json.Unmarshal(params[1], &slot)
+		switch common.HexToHash(slot) {
+		case trimmedSlot:
+			// The nodes trim the leading zeroes of the same value differently
+			if method == "eth_getStorageAt" {
+				return "0x2a"
+			}
+			return common.HexToHash("0x2a").Hex()
+		case laggingSlot:
+			// Realtime catches up with the eth value on the second read
+			if method == "realtime_getStorageAt" && laggingReads.Add(1) == 1 {
+				return "0x04"
+			}
+			return "0x05"
+		case divergedSlot:
+			if method == "eth_getStorageAt" {
+				return "0x06"
+			}
+			return "0x07"
+		}
+		return nil
+	})
+	service.storageCache.Add(address, trimmedSlot)
+	service.storageCache.Add(address, laggingSlot)
+	service.storageCache.Add(address, divergedSlot)
+
+	mismatches := runComparisonRound(t, service.ProcessCompareStorageCache, service.storageCache.Size, logs, "storage mismatch")
+	if laggingReads.Load() < 2 {
+		t.Errorf("got %d realtime reads of the lagging slot, want it compared again after the transient mismatch", laggingReads.Load())
+	}
+	expectMismatch(t, mismatches, fmt.Sprintf("for address %s and slot %s, eth: %s, realtime: %s", address, divergedSlot, common.HexToHash("0x06"), common.HexToHash("0x07")))
+}
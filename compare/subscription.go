@@ -0,0 +1,305 @@
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/core/types"
+	"github.com/sieniven/realtime-compare-tool/metrics"
+	"github.com/sieniven/realtime-compare-tool/rpc"
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+// ProcessRealtimeSubscriptions subscribes to the realtime transaction and balance change notifications, and
+// enqueues each notification for verification against the realtime RPC reads and the canonical chain
+func (service *CompareService) ProcessRealtimeSubscriptions(ctx context.Context) {
+	txChan := make(chan json.RawMessage, DefaultChannelSize)
+	balanceChan := make(chan json.RawMessage, DefaultChannelSize)
+	go service.subscribeWs(ctx, service.Config.SubscribeMethod, txChan, &service.txSubscription, service.Config.TxSubscription)
+	go service.subscribeWs(ctx, service.Config.SubscribeMethod, balanceChan, nil, service.Config.BalanceSubscription)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case result := <-txChan:
+			var notification rpc.RealtimeTxNotification
+			if err := json.Unmarshal(result, &notification); err != nil {
+				service.Logger.Printf("error unmarshalling realtime tx notification: %v\n", err)
+				continue
+			}
+			service.notifiedTxs.Add(notification.Hash)
+			if !service.InitFlag.Load() {
+				continue
+			}
+			var height uint64
+			if notification.BlockNumber != nil {
+				height = uint64(*notification.BlockNumber)
+			}
+			service.txNotificationCache.Add(notification.Hash, height, uint64(service.NodeHeight.Load()))
+		case result := <-balanceChan:
+			var notification rpc.RealtimeBalanceNotification
+			if err := json.Unmarshal(result, &notification); err != nil {
+				service.Logger.Printf("error unmarshalling realtime balance notification: %v\n", err)
+				continue
+			}
+			if !service.InitFlag.Load() || notification.Balance == nil {
+				continue
+			}
+			if service.isSkipAddress(notification.Address) {
+				continue
+			}
+			var height uint64
+			if notification.BlockNumber != nil {
+				height = uint64(*notification.BlockNumber)
+			}
+			service.balanceNotificationCache.Add(notification.Address, notification.Balance.ToInt(), height)
+		}
+	}
+}
+
+// ProcessCompareTxNotificationCache verifies each realtime transaction notification against the realtime receipt
+// and the canonical chain receipt, catching phantom notifications
+func (service *CompareService) ProcessCompareTxNotificationCache(ctx context.Context) {
+	for {
+		if !service.waitUntilComparing(ctx) {
+			return
+		}
+
+		txHashes := service.txNotificationCache.GetTxHashes()
+		service.workerPool.Run(ctx, len(txHashes), func(ctx context.Context, i int) {
+			txHash := txHashes[i]
+			notification, ok := service.txNotificationCache.Get(txHash)
+			if !ok {
+				return
+			}
+			realtimeReceipt, err := service.RpcClient.RealtimeGetTransactionReceipt(ctx, txHash)
+			if err != nil {
+				service.Logger.Printf("error getting realtime receipt for tx %s: %v\n", txHash, err)
+				return
+			}
+			var ethReceipt *types.Receipt
+			pendingExpired := false
+			if realtimeReceipt == nil {
+				// The tx is still pending on realtime, until the eth node is past the pending bound of the notification
+				ethHeight, err := service.RpcClient.EthGetBlockNumber(ctx)
+				if err != nil {
+					service.Logger.Printf("error getting eth height for tx %s: %v\n", txHash, err)
+					return
+				}
+				if ethHeight < pendingTxBound(notification) {
+					return
+				}
+				pendingExpired = true
+			} else if realtimeReceipt.BlockNumber != nil {
+				// Only judge the tx against the canonical chain once the eth node has reached the tx height
+				height := notification.Height
+				if height == 0 {
+					height = realtimeReceipt.BlockNumber.Uint64()
+				}
+				if err := service.waitForEthHeight(ctx, height); err != nil {
+					service.Logger.Printf("error waiting for eth height %d for tx %s: %v\n", height, txHash, err)
+					return
+				}
+			}
+			if pendingExpired || realtimeReceipt.BlockNumber != nil {
+				ethReceipt, err = service.RpcClient.EthGetTransactionReceipt(ctx, txHash)
+				if err != nil {
+					service.Logger.Printf("error getting eth receipt for tx %s: %v\n", txHash, err)
+					return
+				}
+			}
+
+			// Run the tx notification verification
+			mined, diff := diffTxNotification(notification, realtimeReceipt, ethReceipt, pendingExpired)
+			if !mined {
+				// The tx is not yet mined, verify it again in the next round
+				return
+			}
+			mismatch := store.Mismatch{
+				Comparator: "tx notification",
+				Key:        txHash.Hex(),
+				Height:     notification.Height,
+				Diffs:      diff,
+			}
+			switch settleComparison(ctx, service, service.txNotificationCache, txHash, diff == "", mismatch) {
+			case metrics.ResultConfirmedMismatch:
+				service.Logger.Printf("Error in subscription comparator: tx notification mismatch at height %d for tx %s, %s\n", notification.Height, txHash, diff)
+			case metrics.ResultMatch:
+				service.Logger.Printf("Tx notification is verified at height %d for tx %s\n", ethReceipt.BlockNumber, txHash)
+			}
+		})
+
+		time.Sleep(time.Duration(service.Config.CompareIntervalMS) * time.Millisecond)
+	}
+}
+
+// pendingTxBound returns the eth height past which a notified tx without a realtime receipt is no longer pending
+func pendingTxBound(notification TxNotification) uint64 {
+	return max(notification.Height, notification.NodeHeight) + DefaultPendingTxBlocks
+}
+
+// diffTxNotification returns the description of how the tx notification diverged from the realtime and eth
+// receipts, or an empty string if the notified tx is mined at the same height on both nodes. It returns false if
+// the tx is not yet mined, which is the case for a receipt without a block number and for a missing realtime
+// receipt until the eth node is past the pending bound of the notification
+func diffTxNotification(notification TxNotification, realtimeReceipt *types.Receipt, ethReceipt *types.Receipt, pendingExpired bool) (bool, string) {
+	if realtimeReceipt == nil {
+		if !pendingExpired {
+			return false, ""
+		}
+		if ethReceipt == nil || ethReceipt.BlockNumber == nil {
+			return true, fmt.Sprintf("phantom notification, tx not mined on realtime or the canonical chain %d blocks past the notification", DefaultPendingTxBlocks)
+		}
+		if notification.Height != 0 && ethReceipt.BlockNumber.Uint64() != notification.Height {
+			return true, fmt.Sprintf("notified at height %d, eth receipt at height %d", notification.Height, ethReceipt.BlockNumber)
+		}
+		// The realtime receipt was evicted, but the tx is mined at the notified height on the canonical chain
+		return true, ""
+	}
+	if realtimeReceipt.BlockNumber == nil {
+		return false, ""
+	}
+	if notification.Height != 0 && realtimeReceipt.BlockNumber.Uint64() != notification.Height {
+		return true, fmt.Sprintf("realtime receipt at height %d", realtimeReceipt.BlockNumber)
+	}
+	if ethReceipt == nil {
+		return true, "notified tx not found on the canonical chain"
+	}
+	if ethReceipt.BlockNumber == nil {
+		return false, ""
+	}
+	if ethReceipt.BlockNumber.Cmp(realtimeReceipt.BlockNumber) != 0 {
+		return true, fmt.Sprintf("realtime receipt at height %d, eth receipt at height %d", realtimeReceipt.BlockNumber, ethReceipt.BlockNumber)
+	}
+	return true, ""
+}
+
+// ProcessCompareMissedTxCache checks that every tx from the kafka stream is also received on the realtime
+// transaction subscription, catching missed notifications. Txs are only checked while the tx subscription they were
+// received under is live, as notifications are lost while the subscription is down
+func (service *CompareService) ProcessCompareMissedTxCache(ctx context.Context) {
+	for {
+		if !service.waitUntilComparing(ctx) {
+			return
+		}
+
+		// The missed tx check only reads the notified tx set without any rpc calls, so it is not run on the worker pool
+		subscription := service.txSubscription.Load()
+		txHashes := service.missedTxCache.GetTxHashes()
+		for _, txHash := range txHashes {
+			if subscription == 0 {
+				break
+			}
+			missedTx, ok := service.missedTxCache.Get(txHash)
+			if !ok {
+				continue
+			}
+			if missedTx.Subscription != subscription {
+				// The subscription dropped since the tx was received, so its notification may have been lost
+				service.missedTxCache.Remove(txHash)
+				continue
+			}
+			notified := service.notifiedTxs.Contains(txHash)
+			// The notification may arrive after the kafka message, so wait for a few blocks before counting it
+			if !notified && uint64(service.NodeHeight.Load()) < missedTx.NodeHeight+DefaultMissedTxBlocks {
+				continue
+			}
+			mismatch := store.Mismatch{
+				Comparator: "missed tx notification",
+				Key:        txHash.Hex(),
+				Height:     uint64(service.NodeHeight.Load()),
+			}
+			switch settleComparison(ctx, service, service.missedTxCache, txHash, notified, mismatch) {
+			case metrics.ResultConfirmedMismatch:
+				service.Logger.Printf("Error in subscription comparator: missed tx notification at height %d for tx %s\n", service.NodeHeight.Load(), txHash)
+			case metrics.ResultMatch:
+				service.Logger.Printf("Tx notification is received at height %d for tx %s\n", service.NodeHeight.Load(), txHash)
+			}
+		}
+
+		time.Sleep(time.Duration(service.Config.CompareIntervalMS) * time.Millisecond)
+	}
+}
+
+// ProcessCompareBalanceNotificationCache verifies each realtime balance change notification against the realtime
+// balance, and the eth balance at the notified height
+func (service *CompareService) ProcessCompareBalanceNotificationCache(ctx context.Context) {
+	for {
+		if !service.waitUntilComparing(ctx) {
+			return
+		}
+
+		addresses := service.balanceNotificationCache.GetAddresses()
+		service.workerPool.Run(ctx, len(addresses), func(ctx context.Context, i int) {
+			address := addresses[i]
+			notification, ok := service.balanceNotificationCache.Get(address)
+			if !ok {
+				return
+			}
+			realtimeBalance, ethBalance, err := service.getNotificationBalances(ctx, address, notification)
+			if err != nil {
+				service.Logger.Printf("error getting notification balances for address %s: %v\n", address, err)
+				return
+			}
+
+			if realtimeBalance == nil && ethBalance == nil {
+				// Neither balance can be read at the notified height, so the notification cannot be verified
+				service.observeComparison("balance notification", metrics.ResultSkipped)
+				service.Logger.Printf("Balance notification is unverifiable at height %d for address %s, skipping compare\n", notification.Height, address)
+				service.balanceNotificationCache.Remove(address)
+				return
+			}
+
+			// Run the balance notification verification, the balances are nil if they cannot be read at the
+			// notified height
+			realtimeMismatch := realtimeBalance != nil && notification.Balance.Cmp(realtimeBalance) != 0
+			ethMismatch := ethBalance != nil && notification.Balance.Cmp(ethBalance) != 0
+			mismatch := store.Mismatch{
+				Comparator: "balance notification",
+				Address:    address.Hex(),
+				Height:     notification.Height,
+				Eth:        ethBalance.String(),
+				Realtime:   realtimeBalance.String(),
+				Diffs:      fmt.Sprintf("notified: %s", notification.Balance),
+			}
+			switch settleComparison(ctx, service, service.balanceNotificationCache, address, !realtimeMismatch && !ethMismatch, mismatch) {
+			case metrics.ResultConfirmedMismatch:
+				service.Logger.Printf("Error in subscription comparator: balance notification mismatch at height %d for address %s, notified: %s, realtime: %s, eth: %s\n", notification.Height, address, notification.Balance, realtimeBalance, ethBalance)
+			case metrics.ResultMatch:
+				service.Logger.Printf("Balance notification is verified at height %d for address %s\n", notification.Height, address)
+			}
+		})
+
+		time.Sleep(time.Duration(service.Config.CompareIntervalMS) * time.Millisecond)
+	}
+}
+
+// getNotificationBalances returns the realtime and eth balances to verify the balance notification against. The
+// realtime balance is nil if realtime has moved past the notified height, and the eth balance is nil if the
+// notification does not carry the height
+func (service *CompareService) getNotificationBalances(ctx context.Context, address common.Address, notification BalanceNotification) (*big.Int, *big.Int, error) {
+	realtimeBalance, height, err := service.RpcClient.RealtimeGetBalanceAtHeight(ctx, address)
+	if err != nil {
+		return nil, nil, err
+	}
+	if notification.Height != 0 && height != notification.Height {
+		realtimeBalance = nil
+	}
+	if notification.Height == 0 {
+		return realtimeBalance, nil, nil
+	}
+
+	if err := service.waitForEthHeight(ctx, notification.Height); err != nil {
+		return nil, nil, err
+	}
+	ethBalance, err := service.RpcClient.EthGetBalance(ctx, address, rpc.BlockNumberToHex(notification.Height))
+	if err != nil {
+		return nil, nil, err
+	}
+	return realtimeBalance, ethBalance, nil
+}
@@ -0,0 +1,129 @@
+package compare
+
+import (
+	"encoding/json"
+	"math/big"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/core/types"
+)
+
+func TestDiffTxNotification(t *testing.T) {
+	receiptAt := func(height int64) *types.Receipt {
+		return &types.Receipt{BlockNumber: big.NewInt(height)}
+	}
+	pending := &types.Receipt{}
+
+	tests := []struct {
+		name           string
+		notification   TxNotification
+		realtime       *types.Receipt
+		eth            *types.Receipt
+		pendingExpired bool
+		mined          bool
+		diff           string
+	}{
+		{"verified", TxNotification{Height: 10}, receiptAt(10), receiptAt(10), false, true, ""},
+		{"pending realtime receipt", TxNotification{}, pending, nil, false, false, ""},
+		{"missing realtime receipt within pending bound", TxNotification{NodeHeight: 10}, nil, nil, false, false, ""},
+		{"phantom past pending bound", TxNotification{NodeHeight: 10}, nil, nil, true, true, "phantom notification"},
+		{"evicted realtime receipt mined on eth", TxNotification{Height: 10}, nil, receiptAt(10), true, true, ""},
+		{"evicted realtime receipt mined at other height", TxNotification{Height: 10}, nil, receiptAt(11), true, true, "notified at height 10, eth receipt at height 11"},
+		{"realtime receipt at other height", TxNotification{Height: 10}, receiptAt(11), receiptAt(11), false, true, "realtime receipt at height 11"},
+		{"missing eth receipt", TxNotification{Height: 10}, receiptAt(10), nil, false, true, "notified tx not found on the canonical chain"},
+		{"eth receipt at other height", TxNotification{}, receiptAt(10), receiptAt(12), false, true, "realtime receipt at height 10, eth receipt at height 12"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mined, diff := diffTxNotification(test.notification, test.realtime, test.eth, test.pendingExpired)
+			if mined != test.mined {
+				t.Errorf("got mined %v, want %v", mined, test.mined)
+			}
+			if (test.diff == "") != (diff == "") || !strings.HasPrefix(diff, test.diff) {
+				t.Errorf("got diff %q, want %q", diff, test.diff)
+			}
+		})
+	}
+}
+
+func TestCompareServiceTxNotificationRound(t *testing.T) {
+	txHash := common.HexToHash("0x01")
+
+	t.Run("pending within bound", func(t *testing.T) {
+		var heightReads atomic.Int32
+		service, logs := newTestService(t, func(method string, params []json.RawMessage) interface{} {
+			if method == "eth_blockNumber" {
+				heightReads.Add(1)
+				return "0x10"
+			}
+			return nil
+		})
+		service.txNotificationCache.Add(txHash, 0, 0x10)
+
+		// The tx stays pending across rounds while the eth node is below the pending bound
+		runComparisons(t, service.ProcessCompareTxNotificationCache, func() bool {
+			return heightReads.Load() >= 3
+		})
+		if service.txNotificationCache.Size() != 1 {
+			t.Error("pending tx notification was removed from the cache")
+		}
+		if mismatches := logs.find("tx notification mismatch"); len(mismatches) != 0 {
+			t.Errorf("got %d mismatches for the pending tx, want 0", len(mismatches))
+		}
+	})
+
+	t.Run("phantom past bound", func(t *testing.T) {
+		service, logs := newTestService(t, func(method string, params []json.RawMessage) interface{} {
+			if method == "eth_blockNumber" {
+				return "0x100"
+			}
+			return nil
+		})
+		service.txNotificationCache.Add(txHash, 0, 0x10)
+
+		mismatches := runComparisonRound(t, service.ProcessCompareTxNotificationCache, service.txNotificationCache.Size, logs, "tx notification mismatch")
+		expectMismatch(t, mismatches, "for tx "+txHash.Hex()+", phantom notification")
+	})
+}
+
+func TestCompareServiceMissedTxRound(t *testing.T) {
+	missed, notified, stale := common.HexToHash("0x02"), common.HexToHash("0x03"), common.HexToHash("0x04")
+	service, logs := newTestService(t, func(method string, params []json.RawMessage) interface{} { return nil })
+	service.NodeHeight.Store(10)
+	service.txSubscription.Store(2)
+	service.missedTxCache.Add(missed, 10, 2)
+	service.missedTxCache.Add(notified, 10, 2)
+	// The tx received under the dropped subscription may have lost its notification in the reconnect gap
+	service.missedTxCache.Add(stale, 10, 1)
+	service.notifiedTxs.Add(notified)
+
+	// The missed tx is not counted until its notification is a few blocks late
+	runComparisons(t, service.ProcessCompareMissedTxCache, func() bool {
+		return service.missedTxCache.Size() == 1
+	})
+	if service.missedTxCache.GetCount(missed) != 0 {
+		t.Errorf("got count %d for the missed tx within the notification wait, want 0", service.missedTxCache.GetCount(missed))
+	}
+
+	// The missed tx is not confirmed while the subscription is down
+	service.NodeHeight.Store(20)
+	service.txSubscription.Store(0)
+	deadline := time.Now().Add(50 * time.Millisecond)
+	runComparisons(t, service.ProcessCompareMissedTxCache, func() bool { return time.Now().After(deadline) })
+	if service.missedTxCache.GetCount(missed) != 0 {
+		t.Errorf("got count %d for the missed tx while the subscription is down, want 0", service.missedTxCache.GetCount(missed))
+	}
+
+	service.txSubscription.Store(2)
+	runComparisons(t, service.ProcessCompareMissedTxCache, func() bool {
+		return service.missedTxCache.Size() == 0
+	})
+	mismatches := logs.find("missed tx notification")
+	if len(mismatches) != 1 || !strings.HasSuffix(mismatches[0], "for tx "+missed.Hex()+"\n") {
+		t.Errorf("got mismatches %q, want only the missed tx of the live subscription", mismatches)
+	}
+}
@@ -0,0 +1,161 @@
+package compare
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/sieniven/realtime-compare-tool/metrics"
+	"github.com/sieniven/realtime-compare-tool/rpc"
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+func (service *CompareService) ProcessCompareTokenCache(ctx context.Context) {
+	for {
+		if !service.waitUntilComparing(ctx) {
+			return
+		}
+
+		tokenAddresses := service.tokenCache.GetKeys()
+		service.workerPool.Run(ctx, len(tokenAddresses), func(ctx context.Context, i int) {
+			tokenAddress := tokenAddresses[i]
+			// Run the token level comparison
+			height, diffs, err := service.diffTokenCalls(ctx, tokenAddress)
+			if err != nil {
+				service.Logger.Printf("error comparing token calls for token address %s: %v\n", tokenAddress, err)
+				return
+			}
+			mismatch := store.Mismatch{
+				Comparator: "token",
+				Token:      tokenAddress.Hex(),
+				Height:     height,
+				Diffs:      strings.Join(diffs, "; "),
+			}
+			switch settleComparison(ctx, service, service.tokenCache, tokenAddress, len(diffs) == 0, mismatch) {
+			case metrics.ResultConfirmedMismatch:
+				service.Logger.Printf("Error in state comparator: token mismatch at height %d for token address %s, diffs: %s\n", height, tokenAddress, strings.Join(diffs, "; "))
+			case metrics.ResultMatch:
+				service.Logger.Printf("Token calls are equal at height %d for token address %s\n", height, tokenAddress)
+			}
+		})
+
+		time.Sleep(time.Duration(service.Config.CompareIntervalMS) * time.Millisecond)
+	}
+}
+
+func (service *CompareService) ProcessCompareAllowanceCache(ctx context.Context) {
+	for {
+		if !service.waitUntilComparing(ctx) {
+			return
+		}
+
+		tokenAddresses := service.allowanceCache.GetKeys()
+		pairs := make([]AllowancePair, 0)
+		pairTokens := make([]common.Address, 0)
+		for _, tokenAddress := range tokenAddresses {
+			for _, pair := range service.allowanceCache.GetSubKeys(tokenAddress) {
+				pairs = append(pairs, pair)
+				pairTokens = append(pairTokens, tokenAddress)
+			}
+		}
+		service.workerPool.Run(ctx, len(pairs), func(ctx context.Context, i int) {
+			tokenAddress, pair := pairTokens[i], pairs[i]
+			// Run the allowance comparison
+			call, err := newERC20Call(tokenAddress, "allowance", pair.Owner, pair.Spender)
+			if err != nil {
+				service.Logger.Printf("error creating allowance call for token address %s: %v\n", tokenAddress, err)
+				service.allowanceCache.Remove(tokenAddress, pair)
+				return
+			}
+			height, ethOutput, realtimeOutput, err := service.getCallOutputs(ctx, call)
+			if err != nil {
+				service.Logger.Printf("error getting allowances for token address %s, owner %s and spender %s: %v\n", tokenAddress, pair.Owner, pair.Spender, err)
+				return
+			}
+			mismatch := store.Mismatch{
+				Comparator: "allowance",
+				Address:    pair.Owner.Hex(),
+				Token:      tokenAddress.Hex(),
+				Key:        pair.Spender.Hex(),
+				Height:     height,
+				Eth:        call.FormatOutput(ethOutput),
+				Realtime:   call.FormatOutput(realtimeOutput),
+			}
+			switch settleComparison(ctx, service, service.allowanceCache.subKeyCache(tokenAddress), pair, bytes.Equal(ethOutput, realtimeOutput), mismatch) {
+			case metrics.ResultConfirmedMismatch:
+				service.Logger.Printf("Error in state comparator: allowance mismatch at height %d for token address %s, owner %s and spender %s, eth: %s, realtime: %s\n", height, tokenAddress, pair.Owner, pair.Spender, call.FormatOutput(ethOutput), call.FormatOutput(realtimeOutput))
+			case metrics.ResultMatch:
+				service.Logger.Printf("Allowances are equal at height %d for token address %s, owner %s and spender %s\n", height, tokenAddress, pair.Owner, pair.Spender)
+			}
+		})
+
+		time.Sleep(time.Duration(service.Config.CompareIntervalMS) * time.Millisecond)
+	}
+}
+
+// diffTokenCalls compares the token level erc20 methods of the token, and returns the description of every
+// diverged method output. Optional metadata methods that revert because the token does not implement them are
+// skipped, any other error is returned so the token stays cached for the next comparison
+func (service *CompareService) diffTokenCalls(ctx context.Context, tokenAddress common.Address) (uint64, []string, error) {
+	var height uint64
+	succeeded := false
+	diffs := []string{}
+	for _, method := range DefaultTokenCompareMethods {
+		call, err := newERC20Call(tokenAddress, method)
+		if err != nil {
+			return 0, nil, err
+		}
+		callHeight, ethOutput, realtimeOutput, err := service.getCallOutputs(ctx, call)
+		if err != nil {
+			if isOptionalTokenMethod(method) && isRevertError(err) {
+				continue
+			}
+			return 0, nil, fmt.Errorf("error getting %s outputs: %w", method, err)
+		}
+		height = callHeight
+		succeeded = true
+		if !bytes.Equal(ethOutput, realtimeOutput) {
+			diffs = append(diffs, fmt.Sprintf("%s eth: %s, realtime: %s", method, call.FormatOutput(ethOutput), call.FormatOutput(realtimeOutput)))
+		}
+	}
+	if !succeeded {
+		return 0, nil, ErrNoTokenCalls
+	}
+	return height, diffs, nil
+}
+
+func isOptionalTokenMethod(method string) bool {
+	for _, optionalMethod := range DefaultTokenOptionalMethods {
+		if method == optionalMethod {
+			return true
+		}
+	}
+	return false
+}
+
+// isRevertError returns true if the contract call reverted, e.g. because the contract does not implement the
+// method, as opposed to a transport or node error
+func isRevertError(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "revert") || strings.Contains(message, "invalid opcode")
+}
+
+func newERC20Call(tokenAddress common.Address, method string, args ...interface{}) (ContractCall, error) {
+	abiMethod, err := rpc.ERC20Method(method)
+	if err != nil {
+		return ContractCall{}, err
+	}
+	data, err := rpc.PackERC20(method, args...)
+	if err != nil {
+		return ContractCall{}, err
+	}
+	return ContractCall{
+		Name:    method,
+		Address: tokenAddress,
+		Data:    data,
+		Method:  abiMethod,
+	}, nil
+}
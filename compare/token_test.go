@@ -0,0 +1,81 @@
+package compare
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/sieniven/realtime-compare-tool/rpc"
+)
+
+// erc20Selector returns the 4 byte selector of the erc20 method
+func erc20Selector(t *testing.T, method string, args ...interface{}) []byte {
+	data, err := rpc.PackERC20(method, args...)
+	if err != nil {
+		t.Fatalf("pack %s: %v", method, err)
+	}
+	return data[:4]
+}
+
+// uint256Output returns the abi encoded uint256 output of a contract call
+func uint256Output(value int64) string {
+	return fmt.Sprintf("0x%064x", value)
+}
+
+// callData returns the call data of the eth_call or realtime_call request
+func callData(params []json.RawMessage) []byte {
+	var call struct {
+		Data string `json:"data"`
+	}
+	json.Unmarshal(params[0], &call)
+	return common.FromHex(call.Data)
+}
+
+func TestCompareServiceTokenRound(t *testing.T) {
+	token := common.HexToAddress("0x00000000000000000000000000000000000000f1")
+	selectors := map[string][]byte{}
+	for _, method := range DefaultTokenCompareMethods {
+		selectors[method] = erc20Selector(t, method)
+	}
+	// The abi encoded "TKN" symbol string
+	symbol := fmt.Sprintf("0x%064x%064x%x%058x", 0x20, 3, "TKN", 0)
+	service, logs := newTestService(t, func(method string, params []json.RawMessage) interface{} {
+		data := callData(params)
+		switch {
+		case bytes.HasPrefix(data, selectors["name"]):
+			// The token does not implement the optional name method
+			return &testRpcError{Code: 3, Message: "execution reverted"}
+		case bytes.HasPrefix(data, selectors["symbol"]):
+			return symbol
+		case bytes.HasPrefix(data, selectors["decimals"]):
+			return uint256Output(18)
+		case bytes.HasPrefix(data, selectors["totalSupply"]):
+			if method == "realtime_call" {
+				return uint256Output(1001)
+			}
+			return uint256Output(1000)
+		}
+		return nil
+	})
+	service.tokenCache.Add(token)
+
+	mismatches := runComparisonRound(t, service.ProcessCompareTokenCache, service.tokenCache.Size, logs, "token mismatch")
+	expectMismatch(t, mismatches, fmt.Sprintf("for token address %s, diffs: totalSupply eth: [1000], realtime: [1001]\n", token))
+}
+
+func TestCompareServiceAllowanceRound(t *testing.T) {
+	token := common.HexToAddress("0x00000000000000000000000000000000000000f2")
+	owner, spender := common.HexToAddress("0xf3"), common.HexToAddress("0xf4")
+	service, logs := newTestService(t, func(method string, params []json.RawMessage) interface{} {
+		if method == "realtime_call" {
+			return uint256Output(5)
+		}
+		return uint256Output(0)
+	})
+	service.allowanceCache.Add(token, AllowancePair{Owner: owner, Spender: spender})
+
+	mismatches := runComparisonRound(t, service.ProcessCompareAllowanceCache, service.allowanceCache.Size, logs, "allowance mismatch")
+	expectMismatch(t, mismatches, fmt.Sprintf("for token address %s, owner %s and spender %s, eth: [0], realtime: [5]\n", token, owner, spender))
+}
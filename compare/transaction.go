@@ -0,0 +1,124 @@
+package compare
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+	"github.com/sieniven/realtime-compare-tool/metrics"
+	"github.com/sieniven/realtime-compare-tool/store"
+)
+
+func (service *CompareService) ProcessCompareTxCache(ctx context.Context) {
+	for {
+		if !service.waitUntilComparing(ctx) {
+			return
+		}
+
+		txHashes := service.txCache.GetKeys()
+		service.workerPool.Run(ctx, len(txHashes), func(ctx context.Context, i int) {
+			txHash := txHashes[i]
+			// Run the transaction comparison
+			height, mined, diffs, err := service.diffTx(ctx, txHash)
+			if errors.Is(err, ErrTxNotComparable) {
+				service.Logger.Printf("Realtime tx is evicted for tx %s at height %d, skipping compare: %v\n", txHash, height, err)
+				service.txCache.Remove(txHash)
+				return
+			}
+			if err != nil {
+				service.Logger.Printf("error comparing tx %s: %v\n", txHash, err)
+				return
+			}
+			if !mined {
+				return
+			}
+			mismatch := store.Mismatch{
+				Comparator: "tx",
+				Key:        txHash.Hex(),
+				Height:     height,
+				Diffs:      strings.Join(diffs, "; "),
+			}
+			switch settleComparison(ctx, service, service.txCache, txHash, len(diffs) == 0, mismatch) {
+			case metrics.ResultConfirmedMismatch:
+				service.Logger.Printf("Error in state comparator: tx mismatch at height %d for tx %s, diffs: %s\n", height, txHash, strings.Join(diffs, "; "))
+			case metrics.ResultMatch:
+				service.Logger.Printf("Txs are equal at height %d for tx %s\n", height, txHash)
+			}
+		})
+
+		time.Sleep(time.Duration(service.Config.CompareIntervalMS) * time.Millisecond)
+	}
+}
+
+// diffTx compares the realtime view of the tx and its raw encoding against the eth node. It returns false if the
+// tx is not yet mined on the eth node or realtime has not reached the tx height, otherwise the eth block number of
+// the tx and the description of every diverged field. ErrTxNotComparable is returned if the realtime tx is missing
+// past the realtime cache window
+func (service *CompareService) diffTx(ctx context.Context, txHash common.Hash) (uint64, bool, []string, error) {
+	includeExtraInfo := true
+	ethTx, err := service.RpcClient.EthGetTransactionByHash(ctx, txHash, &includeExtraInfo)
+	if err != nil {
+		return 0, false, nil, err
+	}
+	if ethTx == nil {
+		return 0, false, nil, nil
+	}
+	ethFields, err := toJSONFields(ethTx)
+	if err != nil {
+		return 0, false, nil, err
+	}
+	blockNumber, ok := ethFields["blockNumber"].(string)
+	if !ok {
+		return 0, false, nil, nil
+	}
+	height, err := hexutil.DecodeUint64(blockNumber)
+	if err != nil {
+		return 0, false, nil, err
+	}
+	realtimeTx, err := service.RpcClient.RealtimeGetTransactionByHash(ctx, txHash, &includeExtraInfo)
+	if err != nil {
+		return 0, false, nil, err
+	}
+	if realtimeTx == nil {
+		realtimeHeight, err := service.RpcClient.RealtimeBlockNumber(ctx)
+		if err != nil {
+			return 0, false, nil, err
+		}
+		if realtimeHeight < height {
+			// Realtime has not reached the tx height yet, compare again in the next round
+			return 0, false, nil, nil
+		}
+		if realtimeHeight > height+DefaultRealtimeReceiptWindow {
+			return height, false, nil, fmt.Errorf("%w: missing realtime tx at realtime height %d", ErrTxNotComparable, realtimeHeight)
+		}
+		return height, true, []string{"missing realtime tx"}, nil
+	}
+
+	realtimeFields, err := toJSONFields(realtimeTx)
+	if err != nil {
+		return 0, false, nil, err
+	}
+
+	diffs := []string{}
+	for _, field := range diffFields(ethFields, realtimeFields, DefaultTxCompareFields...) {
+		diffs = append(diffs, fmt.Sprintf("%s eth: %v, realtime: %v", field, ethFields[field], realtimeFields[field]))
+	}
+
+	ethRawTx, err := service.RpcClient.EthGetRawTransactionByHash(ctx, txHash)
+	if err != nil {
+		return 0, false, nil, err
+	}
+	realtimeRawTx, err := service.RpcClient.RealtimeGetRawTransactionByHash(ctx, txHash)
+	if err != nil {
+		return 0, false, nil, err
+	}
+	if !bytes.Equal(ethRawTx, realtimeRawTx) {
+		diffs = append(diffs, fmt.Sprintf("raw tx eth: 0x%x, realtime: 0x%x", ethRawTx, realtimeRawTx))
+	}
+	return height, true, diffs, nil
+}
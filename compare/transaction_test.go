@@ -0,0 +1,67 @@
+package compare
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+)
+
+func TestCompareServiceTxRound(t *testing.T) {
+	txHash := common.HexToHash("0x2b")
+	tx := map[string]interface{}{"hash": txHash, "blockNumber": "0xe"}
+	var ethTxReads, rawTxReads atomic.Int32
+	service, logs := newTestService(t, func(method string, params []json.RawMessage) interface{} {
+		switch method {
+		case "eth_getTransactionByHash":
+			// The tx is pending on the eth node on the first read
+			if ethTxReads.Add(1) == 1 {
+				return nil
+			}
+			return tx
+		case "realtime_getTransactionByHash":
+			return tx
+		case "eth_getRawTransactionByHash":
+			rawTxReads.Add(1)
+			return "0x02f8"
+		case "realtime_getRawTransactionByHash":
+			return "0x02f9"
+		}
+		return nil
+	})
+	service.txCache.Add(txHash)
+
+	mismatches := runComparisonRound(t, service.ProcessCompareTxCache, service.txCache.Size, logs, "tx mismatch")
+	if ethTxReads.Load() != 3 || rawTxReads.Load() != 2 {
+		t.Errorf("got %d eth tx reads and %d raw tx reads, want the raw tx compared only once the tx is mined", ethTxReads.Load(), rawTxReads.Load())
+	}
+	expectMismatch(t, mismatches, fmt.Sprintf("at height 14 for tx %s, diffs: raw tx eth: 0x02f8, realtime: 0x02f9\n", txHash))
+}
+
+func TestCompareServiceMissingRealtimeTx(t *testing.T) {
+	missingTxHash, evictedTxHash := common.HexToHash("0x2c"), common.HexToHash("0x2d")
+	service, logs := newTestService(t, func(method string, params []json.RawMessage) interface{} {
+		switch method {
+		case "eth_getTransactionByHash":
+			var txHash common.Hash
+			json.Unmarshal(params[0], &txHash)
+			if txHash == evictedTxHash {
+				return map[string]interface{}{"hash": txHash, "blockNumber": "0x1"}
+			}
+			return map[string]interface{}{"hash": txHash, "blockNumber": "0x60"}
+		case "realtime_blockNumber":
+			return "0x64"
+		}
+		return nil
+	})
+	service.txCache.Add(missingTxHash)
+	service.txCache.Add(evictedTxHash)
+
+	mismatches := runComparisonRound(t, service.ProcessCompareTxCache, service.txCache.Size, logs, "tx mismatch")
+	expectMismatch(t, mismatches, fmt.Sprintf("at height 96 for tx %s, diffs: missing realtime tx\n", missingTxHash))
+	if evicted := logs.find("Realtime tx is evicted for tx " + evictedTxHash.String()); len(evicted) != 1 {
+		t.Errorf("got evicted logs %q, want the tx past the realtime window skipped", evicted)
+	}
+}
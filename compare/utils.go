@@ -0,0 +1,87 @@
+package compare
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// diffJSONFields returns the sorted json field names whose values differ between the eth and realtime objects.
+// If no fields are specified, all fields are compared
+func diffJSONFields(ethValue interface{}, realtimeValue interface{}, fields ...string) ([]string, error) {
+	ethFields, err := toJSONFields(ethValue)
+	if err != nil {
+		return nil, err
+	}
+	realtimeFields, err := toJSONFields(realtimeValue)
+	if err != nil {
+		return nil, err
+	}
+	return diffFields(ethFields, realtimeFields, fields...), nil
+}
+
+// diffFields returns the sorted names of the fields whose values differ between the decoded eth and realtime json
+// objects. If no fields are specified, all fields are compared
+func diffFields(ethFields map[string]interface{}, realtimeFields map[string]interface{}, fields ...string) []string {
+	diffs := []string{}
+	if len(fields) > 0 {
+		for _, field := range fields {
+			if !reflect.DeepEqual(ethFields[field], realtimeFields[field]) {
+				diffs = append(diffs, field)
+			}
+		}
+		return diffs
+	}
+	for field, ethField := range ethFields {
+		if !reflect.DeepEqual(ethField, realtimeFields[field]) {
+			diffs = append(diffs, field)
+		}
+	}
+	for field := range realtimeFields {
+		if _, ok := ethFields[field]; !ok {
+			diffs = append(diffs, field)
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
+
+func toJSONFields(value interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// mergeErrors returns the error of each batch request, preferring the eth error over the realtime error
+func mergeErrors(ethErrs []error, realtimeErrs []error) []error {
+	errs := make([]error, len(ethErrs))
+	for i := range ethErrs {
+		errs[i] = ethErrs[i]
+		if errs[i] == nil {
+			errs[i] = realtimeErrs[i]
+		}
+	}
+	return errs
+}
+
+// chunk splits the items into chunks of up to the size, or a single chunk if the size is not positive
+func chunk[T any](items []T, size int) [][]T {
+	if size <= 0 {
+		size = len(items)
+	}
+	chunks := make([][]T, 0)
+	for start := 0; start < len(items); start += size {
+		end := start + size
+		if end > len(items) {
+			end = len(items)
+		}
+		chunks = append(chunks, items[start:end])
+	}
+	return chunks
+}
@@ -0,0 +1,70 @@
+package compare
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDiffJSONFields(t *testing.T) {
+	type object struct {
+		A string `json:"a"`
+		B int    `json:"b"`
+		C []int  `json:"c,omitempty"`
+	}
+
+	tests := []struct {
+		name     string
+		eth      interface{}
+		realtime interface{}
+		fields   []string
+		diffs    []string
+	}{
+		{"equal", object{A: "x", B: 1}, object{A: "x", B: 1}, nil, []string{}},
+		{"all fields sorted", object{A: "x", B: 1, C: []int{1}}, object{A: "y", B: 2, C: []int{2}}, nil, []string{"a", "b", "c"}},
+		{"field missing on eth", object{A: "x"}, object{A: "x", C: []int{1}}, nil, []string{"c"}},
+		{"field missing on realtime", object{A: "x", C: []int{1}}, object{A: "x"}, nil, []string{"c"}},
+		{"selected fields only", object{A: "x", B: 1}, object{A: "y", B: 2}, []string{"b"}, []string{"b"}},
+		{"selected fields in order", object{A: "x", B: 1}, object{A: "y", B: 2}, []string{"b", "a"}, []string{"b", "a"}},
+		{"map and struct", map[string]interface{}{"a": "x", "b": 1}, object{A: "x", B: 1}, nil, []string{}},
+	}
+	for _, test := range tests {
+		diffs, err := diffJSONFields(test.eth, test.realtime, test.fields...)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(diffs, test.diffs) {
+			t.Errorf("%s: got diffs %q, want %q", test.name, diffs, test.diffs)
+		}
+	}
+}
+
+func TestDiffJSONFieldsInvalidValue(t *testing.T) {
+	if _, err := diffJSONFields(make(chan int), struct{}{}); err == nil {
+		t.Error("got nil error for a value that cannot be encoded")
+	}
+}
+
+func TestMergeErrors(t *testing.T) {
+	ethErr := errors.New("eth")
+	realtimeErr := errors.New("realtime")
+
+	tests := []struct {
+		name         string
+		ethErrs      []error
+		realtimeErrs []error
+		errs         []error
+	}{
+		{"no errors", []error{nil, nil}, []error{nil, nil}, []error{nil, nil}},
+		{"eth error", []error{ethErr, nil}, []error{nil, nil}, []error{ethErr, nil}},
+		{"realtime error", []error{nil, nil}, []error{nil, realtimeErr}, []error{nil, realtimeErr}},
+		{"eth error preferred", []error{ethErr}, []error{realtimeErr}, []error{ethErr}},
+		{"empty", []error{}, []error{}, []error{}},
+	}
+	for _, test := range tests {
+		errs := mergeErrors(test.ethErrs, test.realtimeErrs)
+		if !reflect.DeepEqual(errs, test.errs) {
+			t.Errorf("%s: got %v, want %v", test.name, errs, test.errs)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package compare
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/sieniven/realtime-compare-tool/rpc"
+)
+
+// subscribeWs keeps the websocket subscription alive until the context is cancelled, reconnecting and
+// resubscribing whenever the connection drops. If live is set, it holds the number of the current subscription
+// while subscribed, and zero while the subscription is down
+func (service *CompareService) subscribeWs(ctx context.Context, method string, resultChan chan<- json.RawMessage, live *atomic.Uint64, params ...interface{}) {
+	var subscription uint64
+	for {
+		err := service.WsClient.Subscribe(ctx, method, resultChan, func() {
+			subscription++
+			if live != nil {
+				live.Store(subscription)
+			}
+		}, params...)
+		if live != nil {
+			live.Store(0)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		service.Logger.Printf("websocket %s subscription %v dropped, resubscribing: %v\n", method, params, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(DefaultWsReconnectMS) * time.Millisecond):
+		}
+	}
+}
+
+// ProcessNewHeads subscribes to the websocket newHeads. The heads either drive the height tracking and block level
+// comparisons as an alternative source to the kafka block messages, or are cross-checked against the kafka block
+// height
+func (service *CompareService) ProcessNewHeads(ctx context.Context) {
+	headChan := make(chan json.RawMessage, DefaultChannelSize)
+	go service.subscribeWs(ctx, "eth_subscribe", headChan, nil, "newHeads")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case result := <-headChan:
+			var header rpc.Header
+			if err := json.Unmarshal(result, &header); err != nil {
+				service.Logger.Printf("error unmarshalling newHeads notification: %v\n", err)
+				continue
+			}
+			height := int64(header.Number)
+			if service.Config.Rpc.WsHeightSource {
+				select {
+				case <-ctx.Done():
+					return
+				case service.WsHeightChan <- height:
+				}
+				continue
+			}
+
+			// Cross-check the head against the kafka block height
+			if !service.InitFlag.Load() {
+				continue
+			}
+			kafkaHeight := service.NodeHeight.Load()
+			if abs(height-kafkaHeight) > int64(service.Config.LagThreshold) {
+				service.Logger.Printf("Error in height comparator: websocket head height %d (hash %s) diverges from kafka block height %d\n", height, header.Hash, kafkaHeight)
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package compare
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/sieniven/realtime-compare-tool/rpc"
+)
+
+func TestProcessNewHeadsDrivesHeights(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var request struct {
+			ID uint64 `json:"id"`
+		}
+		if err := conn.ReadJSON(&request); err != nil {
+			return
+		}
+		conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": request.ID, "result": "0x1"})
+		for _, head := range []string{"0xa", "0xb"} {
+			conn.WriteJSON(map[string]interface{}{
+				"jsonrpc": "2.0",
+				"method":  "eth_subscription",
+				"params":  map[string]interface{}{"subscription": "0x1", "result": map[string]string{"number": head}},
+			})
+		}
+		// Keep the subscription open until the client disconnects
+		conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	service, _ := newTestService(t, func(method string, params []json.RawMessage) interface{} { return nil })
+	service.Config.Rpc.WsHeightSource = true
+	service.WsClient = rpc.NewWsClient("ws" + strings.TrimPrefix(server.URL, "http"))
+
+	heights := []int64{}
+	runComparisons(t, service.ProcessNewHeads, func() bool {
+		select {
+		case height := <-service.WsHeightChan:
+			heights = append(heights, height)
+		default:
+		}
+		return len(heights) == 2
+	})
+	if heights[0] != 10 || heights[1] != 11 {
+		t.Errorf("got heights %v, want the newHeads heights [10 11]", heights)
+	}
+}
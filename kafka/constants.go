@@ -2,10 +2,23 @@ package kafka
 
 const (
 	// Block parsing default message fields
-	BlockMessageType          = "block"
-	AddressMessageType        = "address"
-	TokenHolderMessageType    = "tokenHolder"
-	AddressField              = "address"
-	HolderAddressField        = "holderAddress"
-	TokenContractAddressField = "tokenContractAddress"
+	BlockMessageType           = "block"
+	AddressMessageType         = "address"
+	TokenHolderMessageType     = "tokenHolder"
+	ContractMessageType        = "contract"
+	StorageMessageType         = "storage"
+	TransactionMessageType     = "transaction"
+	ApprovalMessageType        = "approval"
+	ERC721TransferMessageType  = "erc721Transfer"
+	ERC1155TransferMessageType = "erc1155Transfer"
+	AddressField               = "address"
+	HolderAddressField         = "holderAddress"
+	TokenContractAddressField  = "tokenContractAddress"
+	SlotField                  = "slot"
+	HashField                  = "hash"
+	OwnerAddressField          = "ownerAddress"
+	SpenderAddressField        = "spenderAddress"
+	FromAddressField           = "fromAddress"
+	ToAddressField             = "toAddress"
+	TokenIDField               = "tokenId"
 )
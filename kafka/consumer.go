@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math/big"
 
 	"github.com/IBM/sarama"
 	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/sieniven/realtime-compare-tool/metrics"
 )
 
 type KafkaConsumer struct {
@@ -42,6 +44,11 @@ func (client *KafkaConsumer) ConsumeKafka(
 	heightChan chan int64,
 	addrBalanceChan chan common.Address,
 	tokenHolderChan chan TokenHolderData,
+	contractChan chan common.Address,
+	storageChan chan StorageData,
+	txHashChan chan common.Hash,
+	approvalChan chan ApprovalData,
+	nftTransferChan chan NftTransferData,
 	errorChan chan error,
 	logger *log.Logger,
 ) {
@@ -50,6 +57,11 @@ func (client *KafkaConsumer) ConsumeKafka(
 		heightChan:      heightChan,
 		addrBalanceChan: addrBalanceChan,
 		tokenHolderChan: tokenHolderChan,
+		contractChan:    contractChan,
+		storageChan:     storageChan,
+		txHashChan:      txHashChan,
+		approvalChan:    approvalChan,
+		nftTransferChan: nftTransferChan,
 		errorChan:       errorChan,
 		logger:          logger,
 	}
@@ -71,6 +83,11 @@ type consumerGroupHandler struct {
 	heightChan      chan int64
 	addrBalanceChan chan common.Address
 	tokenHolderChan chan TokenHolderData
+	contractChan    chan common.Address
+	storageChan     chan StorageData
+	txHashChan      chan common.Hash
+	approvalChan    chan ApprovalData
+	nftTransferChan chan NftTransferData
 	errorChan       chan error
 	logger          *log.Logger
 }
@@ -101,6 +118,7 @@ func (h *consumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 				}
 				continue
 			}
+			metrics.KafkaMessages.WithLabelValues(messageTypeLabel(kafkaData.Type)).Inc()
 			switch kafkaData.Type {
 			case BlockMessageType:
 				heightData, exists := kafkaData.Data["height"]
@@ -121,11 +139,10 @@ func (h *consumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 					return err
 				}
 			case AddressMessageType:
-				addressData, exists := kafkaData.Data[AddressField]
-				if !exists {
-					return fmt.Errorf("missing address field in address messagae")
+				addressStr, ok := h.stringField(kafkaData, AddressField)
+				if !ok {
+					continue
 				}
-				addressStr := addressData.(string)
 				address := common.HexToAddress(addressStr)
 				// Send address to address channel
 				select {
@@ -136,18 +153,16 @@ func (h *consumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 					return err
 				}
 			case TokenHolderMessageType:
-				holderData, exists := kafkaData.Data[HolderAddressField]
-				if !exists {
-					return fmt.Errorf("missing holder address field in token holder message")
+				holderAddressStr, ok := h.stringField(kafkaData, HolderAddressField)
+				if !ok {
+					continue
 				}
-				holderAddressStr := holderData.(string)
 				holderAddress := common.HexToAddress(holderAddressStr)
 
-				tokenAddressData, exists := kafkaData.Data[TokenContractAddressField]
-				if !exists {
-					return fmt.Errorf("missing token contract address field in token holder message")
+				tokenAddressStr, ok := h.stringField(kafkaData, TokenContractAddressField)
+				if !ok {
+					continue
 				}
-				tokenAddressStr := tokenAddressData.(string)
 				tokenAddress := common.HexToAddress(tokenAddressStr)
 				// Send token holder data to token holder channel
 				tokenHolderData := TokenHolderData{
@@ -161,7 +176,191 @@ func (h *consumerGroupHandler) ConsumeClaim(session sarama.ConsumerGroupSession,
 					h.errorChan <- err
 					return err
 				}
+			case ContractMessageType:
+				// Contract messages are emitted on contract creation (CREATE/CREATE2) and self-destruction
+				addressStr, ok := h.stringField(kafkaData, AddressField)
+				if !ok {
+					continue
+				}
+				address := common.HexToAddress(addressStr)
+				// Send address to contract channel
+				select {
+				case h.contractChan <- address:
+				case <-h.ctx.Done():
+					err := fmt.Errorf("context cancelled - stopping consume claim")
+					h.errorChan <- err
+					return err
+				}
+			case StorageMessageType:
+				addressStr, ok := h.stringField(kafkaData, AddressField)
+				if !ok {
+					continue
+				}
+				address := common.HexToAddress(addressStr)
+
+				slotStr, ok := h.stringField(kafkaData, SlotField)
+				if !ok {
+					continue
+				}
+				slot := common.HexToHash(slotStr)
+				// Send storage data to storage channel
+				storageData := StorageData{
+					Address: address,
+					Slot:    slot,
+				}
+				select {
+				case h.storageChan <- storageData:
+				case <-h.ctx.Done():
+					err := fmt.Errorf("context cancelled - stopping consume claim")
+					h.errorChan <- err
+					return err
+				}
+			case TransactionMessageType:
+				hashStr, ok := h.stringField(kafkaData, HashField)
+				if !ok {
+					continue
+				}
+				txHash := common.HexToHash(hashStr)
+				// Send tx hash to tx hash channel
+				select {
+				case h.txHashChan <- txHash:
+				case <-h.ctx.Done():
+					err := fmt.Errorf("context cancelled - stopping consume claim")
+					h.errorChan <- err
+					return err
+				}
+			case ApprovalMessageType:
+				ownerAddressStr, ok := h.stringField(kafkaData, OwnerAddressField)
+				if !ok {
+					continue
+				}
+				ownerAddress := common.HexToAddress(ownerAddressStr)
+
+				spenderAddressStr, ok := h.stringField(kafkaData, SpenderAddressField)
+				if !ok {
+					continue
+				}
+				spenderAddress := common.HexToAddress(spenderAddressStr)
+
+				tokenAddressStr, ok := h.stringField(kafkaData, TokenContractAddressField)
+				if !ok {
+					continue
+				}
+				tokenAddress := common.HexToAddress(tokenAddressStr)
+				// Send approval data to approval channel
+				approvalData := ApprovalData{
+					Owner:        ownerAddress,
+					Spender:      spenderAddress,
+					TokenAddress: tokenAddress,
+				}
+				select {
+				case h.approvalChan <- approvalData:
+				case <-h.ctx.Done():
+					err := fmt.Errorf("context cancelled - stopping consume claim")
+					h.errorChan <- err
+					return err
+				}
+			case ERC721TransferMessageType, ERC1155TransferMessageType:
+				fromAddressStr, ok := h.stringField(kafkaData, FromAddressField)
+				if !ok {
+					continue
+				}
+				fromAddress := common.HexToAddress(fromAddressStr)
+
+				toAddressStr, ok := h.stringField(kafkaData, ToAddressField)
+				if !ok {
+					continue
+				}
+				toAddress := common.HexToAddress(toAddressStr)
+
+				tokenAddressStr, ok := h.stringField(kafkaData, TokenContractAddressField)
+				if !ok {
+					continue
+				}
+				tokenAddress := common.HexToAddress(tokenAddressStr)
+
+				tokenIDData, exists := kafkaData.Data[TokenIDField]
+				if !exists {
+					h.logMissingField(kafkaData.Type, TokenIDField)
+					continue
+				}
+				tokenID, err := parseTokenID(tokenIDData)
+				if err != nil {
+					h.logMalformedMessage(kafkaData.Type, TokenIDField, tokenIDData)
+					continue
+				}
+				// Send nft transfer data to nft transfer channel
+				nftTransferData := NftTransferData{
+					Type:         kafkaData.Type,
+					From:         fromAddress,
+					To:           toAddress,
+					TokenAddress: tokenAddress,
+					TokenID:      tokenID,
+				}
+				select {
+				case h.nftTransferChan <- nftTransferData:
+				case <-h.ctx.Done():
+					err := fmt.Errorf("context cancelled - stopping consume claim")
+					h.errorChan <- err
+					return err
+				}
 			}
 		}
 	}
 }
+
+// messageTypeLabel returns the metrics label of the kafka message type. The message type is set by the producer,
+// so the unknown types share a single label to bound the metric series
+func messageTypeLabel(messageType string) string {
+	switch messageType {
+	case BlockMessageType, AddressMessageType, TokenHolderMessageType, ContractMessageType, StorageMessageType,
+		TransactionMessageType, ApprovalMessageType, ERC721TransferMessageType, ERC1155TransferMessageType:
+		return messageType
+	default:
+		return metrics.UnknownLabel
+	}
+}
+
+// stringField returns the string field of the kafka message. A missing or malformed field is logged, so the
+// message is skipped instead of stopping the consume claim
+func (h *consumerGroupHandler) stringField(kafkaData KafkaData, field string) (string, bool) {
+	data, exists := kafkaData.Data[field]
+	if !exists {
+		h.logMissingField(kafkaData.Type, field)
+		return "", false
+	}
+	value, ok := data.(string)
+	if !ok {
+		h.logMalformedMessage(kafkaData.Type, field, data)
+		return "", false
+	}
+	return value, true
+}
+
+// logMissingField logs the missing field of the kafka message, so the message is skipped
+func (h *consumerGroupHandler) logMissingField(messageType string, field string) {
+	if h.logger != nil {
+		h.logger.Printf("kafka consume claim error, missing %s field in %s message\n", field, messageType)
+	}
+}
+
+// logMalformedMessage logs the malformed field of the kafka message, so the message is skipped
+func (h *consumerGroupHandler) logMalformedMessage(messageType string, field string, value interface{}) {
+	if h.logger != nil {
+		h.logger.Printf("kafka consume claim error, malformed %s field in %s message: %T, value: %v\n", field, messageType, value, value)
+	}
+}
+
+// parseTokenID parses the nft token id, which is sent as a decimal or hex string to preserve uint256 precision.
+// Json numbers are rejected, as token ids above 2^53 lose precision in the float64 decoding
+func parseTokenID(tokenIDData interface{}) (*big.Int, error) {
+	tokenID, ok := tokenIDData.(string)
+	if !ok {
+		return nil, fmt.Errorf("token id field is not a string: %T, value: %v", tokenIDData, tokenIDData)
+	}
+	value, ok := new(big.Int).SetString(tokenID, 0)
+	if !ok || value.Sign() < 0 {
+		return nil, fmt.Errorf("token id field is not a valid integer: %s", tokenID)
+	}
+	return value, nil
+}
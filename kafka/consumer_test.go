@@ -0,0 +1,120 @@
+package kafka
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/sieniven/realtime-compare-tool/metrics"
+)
+
+// testClaim is a consumer group claim that serves the queued kafka messages
+type testClaim struct {
+	messages chan *sarama.ConsumerMessage
+}
+
+func newTestClaim(values ...string) *testClaim {
+	claim := &testClaim{messages: make(chan *sarama.ConsumerMessage, len(values))}
+	for _, value := range values {
+		claim.messages <- &sarama.ConsumerMessage{Value: []byte(value)}
+	}
+	close(claim.messages)
+	return claim
+}
+
+func (claim *testClaim) Topic() string                            { return "" }
+func (claim *testClaim) Partition() int32                         { return 0 }
+func (claim *testClaim) InitialOffset() int64                     { return 0 }
+func (claim *testClaim) HighWaterMarkOffset() int64               { return 0 }
+func (claim *testClaim) Messages() <-chan *sarama.ConsumerMessage { return claim.messages }
+
+func TestParseTokenID(t *testing.T) {
+	tests := []struct {
+		name    string
+		tokenID interface{}
+		want    *big.Int
+		err     bool
+	}{
+		{"decimal string", "42", big.NewInt(42), false},
+		{"hex string", "0x2a", big.NewInt(42), false},
+		{"uint256 string", "115792089237316195423570985008687907853269984665640564039457584007913129639935", new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1)), false},
+		{"json number", float64(42), nil, true},
+		{"negative string", "-1", nil, true},
+		{"invalid string", "token", nil, true},
+		{"invalid type", true, nil, true},
+		{"missing", nil, nil, true},
+	}
+	for _, test := range tests {
+		got, err := parseTokenID(test.tokenID)
+		if (err != nil) != test.err {
+			t.Errorf("%s: got error %v, want error %v", test.name, err, test.err)
+			continue
+		}
+		if !test.err && got.Cmp(test.want) != 0 {
+			t.Errorf("%s: got %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestConsumeClaimSkipsMalformedMessages(t *testing.T) {
+	handler := &consumerGroupHandler{
+		ctx:             context.Background(),
+		addrBalanceChan: make(chan common.Address, 4),
+		tokenHolderChan: make(chan TokenHolderData, 4),
+		contractChan:    make(chan common.Address, 4),
+		storageChan:     make(chan StorageData, 4),
+		txHashChan:      make(chan common.Hash, 4),
+		approvalChan:    make(chan ApprovalData, 4),
+		nftTransferChan: make(chan NftTransferData, 4),
+		errorChan:       make(chan error, 4),
+	}
+	claim := newTestClaim(
+		`{"type":"address","data":{"address":42}}`,
+		`{"type":"tokenHolder","data":{"holderAddress":"0x01","tokenContractAddress":["0x02"]}}`,
+		`{"type":"address","data":{}}`,
+		`{"type":"tokenHolder","data":{"holderAddress":"0x01"}}`,
+		`{"type":"transaction","data":{}}`,
+		`{"type":"contract","data":{"address":{"hex":"0x03"}}}`,
+		`{"type":"contract","data":{"address":"0x04"}}`,
+		`{"type":"storage","data":{"address":"0x05","slot":1}}`,
+		`{"type":"transaction","data":{"hash":null}}`,
+		`{"type":"approval","data":{"ownerAddress":"0x06","spenderAddress":true,"tokenContractAddress":"0x07"}}`,
+		`{"type":"erc721Transfer","data":{"fromAddress":"0x08","toAddress":9,"tokenContractAddress":"0x0a","tokenId":"1"}}`,
+		`{"type":"erc721Transfer","data":{"fromAddress":"0x08","toAddress":"0x09","tokenContractAddress":"0x0a"}}`,
+		`{"type":"erc721Transfer","data":{"fromAddress":"0x08","toAddress":"0x09","tokenContractAddress":"0x0a","tokenId":"token"}}`,
+		`{"type":"erc1155Transfer","data":{"fromAddress":"0x08","toAddress":"0x09","tokenContractAddress":"0x0a","tokenId":18014398509481985}}`,
+	)
+	if err := handler.ConsumeClaim(nil, claim); err != nil {
+		t.Fatalf("got error %v, want the missing and malformed messages skipped", err)
+	}
+
+	if len(handler.addrBalanceChan) != 0 || len(handler.tokenHolderChan) != 0 || len(handler.storageChan) != 0 {
+		t.Errorf("got %d addresses, %d token holders and %d storage slots, want the missing and malformed messages dropped", len(handler.addrBalanceChan), len(handler.tokenHolderChan), len(handler.storageChan))
+	}
+	if len(handler.txHashChan) != 0 {
+		t.Errorf("got %d tx hashes, want the transaction messages with a missing or null hash dropped", len(handler.txHashChan))
+	}
+	if len(handler.approvalChan) != 0 {
+		t.Errorf("got %d approvals, want the approval message with a boolean spender dropped", len(handler.approvalChan))
+	}
+	if len(handler.nftTransferChan) != 0 {
+		t.Errorf("got %d nft transfers, want the transfer messages with a numeric recipient and missing, invalid or numeric token ids dropped", len(handler.nftTransferChan))
+	}
+	if len(handler.contractChan) != 1 {
+		t.Fatalf("got %d contracts, want only the well formed contract message", len(handler.contractChan))
+	}
+	if address := <-handler.contractChan; address != common.HexToAddress("0x04") {
+		t.Errorf("got contract %s, want %s", address, common.HexToAddress("0x04"))
+	}
+}
+
+func TestMessageTypeLabel(t *testing.T) {
+	if label := messageTypeLabel(ERC721TransferMessageType); label != ERC721TransferMessageType {
+		t.Errorf("got label %q for a known message type, want %q", label, ERC721TransferMessageType)
+	}
+	if label := messageTypeLabel("producer-defined"); label != metrics.UnknownLabel {
+		t.Errorf("got label %q for an unknown message type, want %q", label, metrics.UnknownLabel)
+	}
+}
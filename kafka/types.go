@@ -1,6 +1,10 @@
 package kafka
 
-import "github.com/ledgerwatch/erigon-lib/common"
+import (
+	"math/big"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+)
 
 type KafkaData struct {
 	Topic string                 `json:"topic"`
@@ -12,3 +16,22 @@ type TokenHolderData struct {
 	Address      common.Address
 	TokenAddress common.Address
 }
+
+type StorageData struct {
+	Address common.Address
+	Slot    common.Hash
+}
+
+type ApprovalData struct {
+	Owner        common.Address
+	Spender      common.Address
+	TokenAddress common.Address
+}
+
+type NftTransferData struct {
+	Type         string
+	From         common.Address
+	To           common.Address
+	TokenAddress common.Address
+	TokenID      *big.Int
+}
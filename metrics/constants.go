@@ -0,0 +1,11 @@
+package metrics
+
+import "time"
+
+const (
+	namespace = "realtime_compare"
+	// Label of the endpoints and kafka message types that cannot be labelled by value
+	UnknownLabel = "unknown"
+
+	DefaultReadHeaderTimeout = 10 * time.Second
+)
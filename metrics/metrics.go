@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Comparison results
+const (
+	ResultMatch             = "match"
+	ResultTransientMismatch = "transient_mismatch"
+	ResultConfirmedMismatch = "confirmed_mismatch"
+	ResultSkipped           = "skipped"
+)
+
+// Height sources
+const (
+	HeightRealtime  = "realtime"
+	HeightCanonical = "canonical"
+	HeightKafka     = "kafka"
+)
+
+var (
+	// Comparisons performed by each comparator of each realtime node
+	Comparisons = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "comparisons_total",
+		Help:      "Comparisons performed per comparator",
+	}, []string{"node", "comparator"})
+	// Comparison results by each comparator of each realtime node. A mismatch is transient until it persists beyond
+	// the mismatch count, after which it is confirmed. Entries that cannot be compared are skipped
+	ComparisonResults = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "comparison_results_total",
+		Help:      "Comparison results per comparator, one of match, transient_mismatch, confirmed_mismatch or skipped",
+	}, []string{"node", "comparator", "result"})
+	// Pending entries in the compare caches
+	CacheSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "cache_size",
+		Help:      "Pending entries in the compare cache",
+	}, []string{"node", "cache"})
+	// Latency of each RPC request attempt
+	RpcLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rpc_latency_seconds",
+		Help:      "Latency of the RPC requests per endpoint and method",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint", "method"})
+	// Kafka messages consumed per message type
+	KafkaMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "kafka_messages_total",
+		Help:      "Kafka messages consumed per message type",
+	}, []string{"type"})
+	// Latest realtime, canonical and kafka block heights seen by each realtime node
+	Heights = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "height",
+		Help:      "Latest block height per source, one of realtime, canonical or kafka",
+	}, []string{"node", "source"})
+)
+
+// ObserveComparison counts the comparison and its result
+func ObserveComparison(node string, comparator string, result string) {
+	Comparisons.WithLabelValues(node, comparator).Inc()
+	ComparisonResults.WithLabelValues(node, comparator, result).Inc()
+}
+
+// ObserveRpcLatency records the latency of the RPC request to the endpoint url since the start time
+func ObserveRpcLatency(endpointUrl string, method string, start time.Time) {
+	RpcLatency.WithLabelValues(EndpointLabel(endpointUrl), method).Observe(time.Since(start).Seconds())
+}
+
+// EndpointLabel returns the host of the endpoint url as the metrics label. The path, query and user info are
+// dropped, as RPC urls often carry API keys that must not be published on the metrics endpoint
+func EndpointLabel(endpointUrl string) string {
+	parsed, err := url.Parse(endpointUrl)
+	if err != nil || parsed.Host == "" {
+		return UnknownLabel
+	}
+	return parsed.Host
+}
+
+// StartServer serves the /metrics endpoint on the address until the context is cancelled
+func StartServer(ctx context.Context, addr string, logger *log.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: DefaultReadHeaderTimeout,
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go func() {
+		logger.Printf("serving metrics on %s/metrics\n", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Printf("error serving metrics: %v\n", err)
+		}
+	}()
+}
@@ -0,0 +1,297 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+	"github.com/ledgerwatch/erigon-lib/common/hexutility"
+	"github.com/ledgerwatch/erigon/core/types"
+)
+
+// BatchElem is a single request of a JSON-RPC batch call. The result is unmarshalled into Result, and Error is set
+// if the request failed
+type BatchElem struct {
+	Method string
+	Params []interface{}
+	Result interface{}
+	Error  error
+}
+
+// BatchCallMsg is a contract call packed into a realtime_call or eth_call batch request
+type BatchCallMsg struct {
+	To   common.Address
+	Data []byte
+}
+
+// blockTransaction is the hash of the transaction returned by the transaction by block number and index request
+type blockTransaction struct {
+	Hash common.Hash `json:"hash"`
+}
+
+// RealtimeBatchCall sends the requests to the realtime node in JSON-RPC batches of the configured batch size
+func (c *RealtimeClient) RealtimeBatchCall(ctx context.Context, elems []BatchElem) error {
+	return c.batchCall(ctx, c.realtimeUrl, elems)
+}
+
+// EthBatchCall sends the requests to the reference node in JSON-RPC batches of the configured batch size
+func (c *RealtimeClient) EthBatchCall(ctx context.Context, elems []BatchElem) error {
+	return c.batchCall(ctx, c.referenceUrl, elems)
+}
+
+func (c *RealtimeClient) batchCall(ctx context.Context, url string, elems []BatchElem) error {
+	batchSize := c.batchSize
+	if batchSize <= 0 {
+		// Send all requests in a single batch
+		batchSize = len(elems)
+	}
+	for start := 0; start < len(elems); start += batchSize {
+		end := start + batchSize
+		if end > len(elems) {
+			end = len(elems)
+		}
+		if err := c.sendBatch(ctx, url, elems[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendBatch sends the requests in a single JSON-RPC batch, matching the responses back to the requests by id
+func (c *RealtimeClient) sendBatch(ctx context.Context, url string, elems []BatchElem) error {
+	requests := make([]rpcRequest, 0, len(elems))
+	for i, elem := range elems {
+		requests = append(requests, rpcRequest{
+			JSONRPC: "2.0",
+			ID:      uint64(i),
+			Method:  elem.Method,
+			Params:  elem.Params,
+		})
+	}
+	var responses []rpcResponse
+	err := c.transport.Do(ctx, url, "batch:"+elems[0].Method, func(ctx context.Context) error {
+		return c.transport.post(ctx, url, requests, &responses)
+	})
+	if err != nil {
+		return err
+	}
+	received := make([]bool, len(elems))
+	for _, response := range responses {
+		if response.ID >= uint64(len(elems)) {
+			continue
+		}
+		elem := &elems[response.ID]
+		received[response.ID] = true
+		if response.Error != nil {
+			elem.Error = fmt.Errorf("%d - %s", response.Error.Code, response.Error.Message)
+			continue
+		}
+		if elem.Result != nil {
+			elem.Error = json.Unmarshal(response.Result, elem.Result)
+		}
+	}
+	for i := range elems {
+		if !received[i] {
+			elems[i].Error = fmt.Errorf("missing batch response for %s request", elems[i].Method)
+		}
+	}
+	return nil
+}
+
+// RealtimeBatchGetBalances returns the realtime balances of the accounts, along with the error of each request
+func (c *RealtimeClient) RealtimeBatchGetBalances(ctx context.Context, addresses []common.Address) ([]*big.Int, []error, error) {
+	elems := make([]BatchElem, 0, len(addresses))
+	for _, address := range addresses {
+		elems = append(elems, BatchElem{
+			Method: "realtime_getBalance",
+			Params: []interface{}{address},
+			Result: new(hexutil.Big),
+		})
+	}
+	if err := c.RealtimeBatchCall(ctx, elems); err != nil {
+		return nil, nil, err
+	}
+
+	balances, errs := batchBalances(elems)
+	return balances, errs, nil
+}
+
+// EthBatchGetBalances returns the balances of the accounts at the block, along with the error of each request
+func (c *RealtimeClient) EthBatchGetBalances(ctx context.Context, addresses []common.Address, block string) ([]*big.Int, []error, error) {
+	elems := make([]BatchElem, 0, len(addresses))
+	for _, address := range addresses {
+		elems = append(elems, BatchElem{
+			Method: "eth_getBalance",
+			Params: []interface{}{address, block},
+			Result: new(hexutil.Big),
+		})
+	}
+	if err := c.EthBatchCall(ctx, elems); err != nil {
+		return nil, nil, err
+	}
+
+	balances, errs := batchBalances(elems)
+	return balances, errs, nil
+}
+
+// RealtimeBatchGetBlockTransactionHashes returns the realtime hashes of the first count transactions of the block by
+// index, along with the error of each request. The hash is empty if the block has no transaction at the index
+func (c *RealtimeClient) RealtimeBatchGetBlockTransactionHashes(ctx context.Context, blockNumber uint64, count uint64) ([]common.Hash, []error, error) {
+	elems := make([]BatchElem, 0, count)
+	for index := uint64(0); index < count; index++ {
+		elems = append(elems, BatchElem{
+			Method: "realtime_getTransactionByBlockNumberAndIndex",
+			Params: []interface{}{BlockNumberToHex(blockNumber), hexutil.Uint64(index)},
+			Result: new(blockTransaction),
+		})
+	}
+	if err := c.RealtimeBatchCall(ctx, elems); err != nil {
+		return nil, nil, err
+	}
+
+	txHashes := make([]common.Hash, len(elems))
+	errs := make([]error, len(elems))
+	for i, elem := range elems {
+		if elem.Error != nil {
+			errs[i] = elem.Error
+			continue
+		}
+		txHashes[i] = elem.Result.(*blockTransaction).Hash
+	}
+	return txHashes, errs, nil
+}
+
+// RealtimeBatchGetTransactionReceipts returns the realtime receipts of the transactions, along with the error of
+// each request. The receipt is nil if the transaction is not in the realtime cache
+func (c *RealtimeClient) RealtimeBatchGetTransactionReceipts(ctx context.Context, txHashes []common.Hash) ([]*types.Receipt, []error, error) {
+	elems := make([]BatchElem, 0, len(txHashes))
+	for _, txHash := range txHashes {
+		elems = append(elems, BatchElem{
+			Method: "realtime_getTransactionReceipt",
+			Params: []interface{}{txHash},
+			Result: new(*types.Receipt),
+		})
+	}
+	if err := c.RealtimeBatchCall(ctx, elems); err != nil {
+		return nil, nil, err
+	}
+
+	receipts := make([]*types.Receipt, len(elems))
+	errs := make([]error, len(elems))
+	for i, elem := range elems {
+		if elem.Error != nil {
+			errs[i] = elem.Error
+			continue
+		}
+		receipts[i] = *elem.Result.(**types.Receipt)
+	}
+	return receipts, errs, nil
+}
+
+// RealtimeBatchCallContracts executes the contract calls in real-time, returning the output and error of each call
+func (c *RealtimeClient) RealtimeBatchCallContracts(ctx context.Context, calls []BatchCallMsg) ([][]byte, []error, error) {
+	elems := make([]BatchElem, 0, len(calls))
+	for _, call := range calls {
+		txParams := map[string]any{
+			"to":    call.To,
+			"value": "0x0",
+			"data":  fmt.Sprintf("0x%x", call.Data),
+		}
+		elems = append(elems, BatchElem{
+			Method: "realtime_call",
+			Params: []interface{}{txParams},
+			Result: new(hexutility.Bytes),
+		})
+	}
+	if err := c.RealtimeBatchCall(ctx, elems); err != nil {
+		return nil, nil, err
+	}
+
+	outputs, errs := batchOutputs(elems)
+	return outputs, errs, nil
+}
+
+// EthBatchCallContracts executes the contract calls at the block, returning the output and error of each call
+func (c *RealtimeClient) EthBatchCallContracts(ctx context.Context, calls []BatchCallMsg, block string) ([][]byte, []error, error) {
+	elems := make([]BatchElem, 0, len(calls))
+	for _, call := range calls {
+		txParams := map[string]any{
+			"to":   call.To,
+			"data": fmt.Sprintf("0x%x", call.Data),
+		}
+		elems = append(elems, BatchElem{
+			Method: "eth_call",
+			Params: []interface{}{txParams, block},
+			Result: new(hexutility.Bytes),
+		})
+	}
+	if err := c.EthBatchCall(ctx, elems); err != nil {
+		return nil, nil, err
+	}
+
+	outputs, errs := batchOutputs(elems)
+	return outputs, errs, nil
+}
+
+// RealtimeBatchGetBalancesAtHeight returns the realtime balances of the accounts, with the realtime height they
+// were read at
+func (c *RealtimeClient) RealtimeBatchGetBalancesAtHeight(ctx context.Context, addresses []common.Address) ([]*big.Int, []error, uint64, error) {
+	var balances []*big.Int
+	var errs []error
+	height, err := c.RealtimeReadAtHeight(ctx, func() error {
+		var err error
+		balances, errs, err = c.RealtimeBatchGetBalances(ctx, addresses)
+		return err
+	})
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return balances, errs, height, nil
+}
+
+// RealtimeBatchCallContractsAtHeight executes the contract calls in real-time, with the realtime height they were
+// executed at
+func (c *RealtimeClient) RealtimeBatchCallContractsAtHeight(ctx context.Context, calls []BatchCallMsg) ([][]byte, []error, uint64, error) {
+	var outputs [][]byte
+	var errs []error
+	height, err := c.RealtimeReadAtHeight(ctx, func() error {
+		var err error
+		outputs, errs, err = c.RealtimeBatchCallContracts(ctx, calls)
+		return err
+	})
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return outputs, errs, height, nil
+}
+
+func batchBalances(elems []BatchElem) ([]*big.Int, []error) {
+	balances := make([]*big.Int, len(elems))
+	errs := make([]error, len(elems))
+	for i, elem := range elems {
+		if elem.Error != nil {
+			errs[i] = elem.Error
+			continue
+		}
+		balances[i] = elem.Result.(*hexutil.Big).ToInt()
+	}
+	return balances, errs
+}
+
+func batchOutputs(elems []BatchElem) ([][]byte, []error) {
+	outputs := make([][]byte, len(elems))
+	errs := make([]error, len(elems))
+	for i, elem := range elems {
+		if elem.Error != nil {
+			errs[i] = elem.Error
+			continue
+		}
+		outputs[i] = *elem.Result.(*hexutility.Bytes)
+	}
+	return outputs, errs
+}
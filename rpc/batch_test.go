@@ -0,0 +1,149 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon/core/types"
+)
+
+// newBatchServer serves the JSON-RPC batches, answering each request with its method name in reverse order,
+// failing the "fail" requests, dropping the "skip" requests and adding a response with an unknown id
+func newBatchServer(t *testing.T, batchSizes *[]int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requests []rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			t.Errorf("invalid batch request: %v", err)
+			return
+		}
+		*batchSizes = append(*batchSizes, len(requests))
+		responses := []rpcResponse{{ID: 99, Result: json.RawMessage(`"unknown"`)}}
+		for i := len(requests) - 1; i >= 0; i-- {
+			request := requests[i]
+			switch request.Method {
+			case "skip":
+			case "fail":
+				responses = append(responses, rpcResponse{ID: request.ID, Error: &rpcError{Code: -32000, Message: "execution reverted"}})
+			default:
+				result, _ := json.Marshal(request.Method)
+				responses = append(responses, rpcResponse{ID: request.ID, Result: result})
+			}
+		}
+		json.NewEncoder(w).Encode(responses)
+	}))
+}
+
+func TestBatchCallMatchesResponses(t *testing.T) {
+	batchSizes := []int{}
+	server := newBatchServer(t, &batchSizes)
+	defer server.Close()
+	client := &RealtimeClient{
+		transport:   NewTransport(TransportConfig{}),
+		realtimeUrl: server.URL,
+		batchSize:   2,
+	}
+
+	methods := []string{"a", "fail", "b", "skip", "c"}
+	elems := make([]BatchElem, 0, len(methods))
+	for _, method := range methods {
+		elems = append(elems, BatchElem{Method: method, Result: new(string)})
+	}
+	if err := client.RealtimeBatchCall(context.Background(), elems); err != nil {
+		t.Fatalf("batch call: %v", err)
+	}
+
+	if len(batchSizes) != 3 || batchSizes[0] != 2 || batchSizes[1] != 2 || batchSizes[2] != 1 {
+		t.Errorf("got batch sizes %v, want [2 2 1]", batchSizes)
+	}
+	tests := []struct {
+		method string
+		result string
+		err    string
+	}{
+		{"a", "a", ""},
+		{"fail", "", "-32000 - execution reverted"},
+		{"b", "b", ""},
+		{"skip", "", "missing batch response for skip request"},
+		{"c", "c", ""},
+	}
+	for i, test := range tests {
+		elem := elems[i]
+		if test.err != "" {
+			if elem.Error == nil || elem.Error.Error() != test.err {
+				t.Errorf("%s: got error %v, want %s", test.method, elem.Error, test.err)
+			}
+			continue
+		}
+		if elem.Error != nil {
+			t.Errorf("%s: got error %v", test.method, elem.Error)
+			continue
+		}
+		if result := *elem.Result.(*string); result != test.result {
+			t.Errorf("%s: got result %s, want %s", test.method, result, test.result)
+		}
+	}
+}
+
+func TestBatchCallHttpError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+	client := &RealtimeClient{
+		transport:   NewTransport(TransportConfig{}),
+		realtimeUrl: server.URL,
+	}
+
+	elems := []BatchElem{{Method: "a", Result: new(string)}}
+	if err := client.RealtimeBatchCall(context.Background(), elems); err == nil {
+		t.Error("got nil error for the failed batch request")
+	}
+}
+
+func TestRealtimeBatchGetTransactionReceipts(t *testing.T) {
+	minedHash := common.HexToHash("0x01")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requests []struct {
+			ID     uint64        `json:"id"`
+			Params []common.Hash `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&requests); err != nil {
+			t.Errorf("invalid batch request: %v", err)
+			return
+		}
+		responses := make([]rpcResponse, 0, len(requests))
+		for _, request := range requests {
+			result := json.RawMessage("null")
+			if request.Params[0] == minedHash {
+				result, _ = json.Marshal(&types.Receipt{Status: 1, GasUsed: 21000, Logs: types.Logs{}, TxHash: minedHash, BlockNumber: big.NewInt(7)})
+			}
+			responses = append(responses, rpcResponse{ID: request.ID, Result: result})
+		}
+		json.NewEncoder(w).Encode(responses)
+	}))
+	defer server.Close()
+	client := &RealtimeClient{
+		transport:   NewTransport(TransportConfig{}),
+		realtimeUrl: server.URL,
+		batchSize:   10,
+	}
+
+	receipts, errs, err := client.RealtimeBatchGetTransactionReceipts(context.Background(), []common.Hash{minedHash, common.HexToHash("0x02")})
+	if err != nil {
+		t.Fatalf("batch receipts: %v", err)
+	}
+	if errs[0] != nil || errs[1] != nil {
+		t.Fatalf("got request errors %v", errs)
+	}
+	if receipts[0] == nil || receipts[0].TxHash != minedHash || receipts[0].BlockNumber.Uint64() != 7 {
+		t.Errorf("got receipt %+v, want the receipt of the mined tx at height 7", receipts[0])
+	}
+	if receipts[1] != nil {
+		t.Errorf("got receipt %+v for the tx missing from the realtime cache, want nil", receipts[1])
+	}
+}
@@ -0,0 +1,44 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+)
+
+// EndpointLimiter bounds the number of concurrent requests in flight to each endpoint url. The limiter is shared
+// across the clients calling the same endpoints
+type EndpointLimiter struct {
+	mu    sync.Mutex
+	limit int
+	slots map[string]chan struct{}
+}
+
+// NewEndpointLimiter creates the endpoint limiter. A non-positive limit does not bound the requests
+func NewEndpointLimiter(limit int) *EndpointLimiter {
+	return &EndpointLimiter{
+		limit: limit,
+		slots: make(map[string]chan struct{}),
+	}
+}
+
+// Acquire blocks until a request slot of the endpoint is free, and returns the function releasing the slot
+func (limiter *EndpointLimiter) Acquire(ctx context.Context, url string) (func(), error) {
+	if limiter == nil || limiter.limit <= 0 {
+		return func() {}, nil
+	}
+
+	limiter.mu.Lock()
+	slots, ok := limiter.slots[url]
+	if !ok {
+		slots = make(chan struct{}, limiter.limit)
+		limiter.slots[url] = slots
+	}
+	limiter.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	}
+}
@@ -0,0 +1,65 @@
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestEndpointLimiter(t *testing.T) {
+	tests := []struct {
+		name     string
+		limiter  *EndpointLimiter
+		acquires int
+		blocked  bool
+	}{
+		{"within limit", NewEndpointLimiter(2), 2, false},
+		{"over limit", NewEndpointLimiter(2), 3, true},
+		{"no limit", NewEndpointLimiter(0), 10, false},
+		{"nil limiter", nil, 10, false},
+	}
+	for _, test := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		blocked := false
+		for i := 0; i < test.acquires; i++ {
+			if _, err := test.limiter.Acquire(ctx, "http://node"); err != nil {
+				blocked = true
+			}
+		}
+		cancel()
+		if blocked != test.blocked {
+			t.Errorf("%s: got blocked %v, want %v", test.name, blocked, test.blocked)
+		}
+	}
+}
+
+func TestEndpointLimiterRelease(t *testing.T) {
+	limiter := NewEndpointLimiter(1)
+	release, err := limiter.Acquire(context.Background(), "http://node")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	// The slots are per endpoint
+	if _, err := limiter.Acquire(context.Background(), "http://other"); err != nil {
+		t.Fatalf("acquire other endpoint: %v", err)
+	}
+
+	acquired := make(chan struct{})
+	go func() {
+		if _, err := limiter.Acquire(context.Background(), "http://node"); err == nil {
+			close(acquired)
+		}
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired the slot before it was released")
+	case <-time.After(20 * time.Millisecond):
+	}
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("slot not acquired after it was released")
+	}
+}
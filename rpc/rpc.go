@@ -8,29 +8,72 @@ import (
 
 	ethereum "github.com/ledgerwatch/erigon"
 	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutility"
 	"github.com/ledgerwatch/erigon/core/types"
 	"github.com/ledgerwatch/erigon/ethclient"
 	rpcTypes "github.com/ledgerwatch/erigon/zk/rpcdaemon"
 	zktypes "github.com/ledgerwatch/erigon/zk/types"
-	"github.com/ledgerwatch/erigon/zkevm/jsonrpc/client"
+	jsonrpcTypes "github.com/ledgerwatch/erigon/zkevm/jsonrpc/types"
 )
 
+// RealtimeClient routes the realtime_* calls to the realtime node, and the eth_* calls to the reference node
+// that the realtime state is compared against. Both may be the same node. Batch calls are split into JSON-RPC
+// batches of up to the batch size, and all requests are sent through the transport policy
 type RealtimeClient struct {
-	client ethClienter
-	rpcUrl string
+	client       ethClienter
+	transport    *Transport
+	realtimeUrl  string
+	referenceUrl string
+	batchSize    int
 }
 
-func NewRealtimeClient(rpcUrl string) (*RealtimeClient, error) {
-	client, err := ethclient.Dial(rpcUrl)
+func NewRealtimeClient(realtimeUrl string, referenceUrl string, batchSize int, transport *Transport) (*RealtimeClient, error) {
+	client, err := ethclient.Dial(referenceUrl)
 	if err != nil {
 		return nil, err
 	}
-	return &RealtimeClient{client: client, rpcUrl: rpcUrl}, nil
+	return &RealtimeClient{
+		client:       client,
+		transport:    transport,
+		realtimeUrl:  realtimeUrl,
+		referenceUrl: referenceUrl,
+		batchSize:    batchSize,
+	}, nil
+}
+
+// jsonRPCCall sends the JSON-RPC request to the endpoint through the transport
+func (c *RealtimeClient) jsonRPCCall(ctx context.Context, url string, method string, parameters ...interface{}) (jsonrpcTypes.Response, error) {
+	return c.transport.Call(ctx, url, method, parameters...)
+}
+
+// IsAvailable returns false while the circuit breaker of the realtime or reference endpoint is open
+func (c *RealtimeClient) IsAvailable() bool {
+	return c.IsRealtimeAvailable() && c.IsReferenceAvailable()
+}
+
+// IsRealtimeAvailable returns false while the circuit breaker of the realtime endpoint is open
+func (c *RealtimeClient) IsRealtimeAvailable() bool {
+	return c.transport.IsAvailable(c.realtimeUrl)
+}
+
+// IsReferenceAvailable returns false while the circuit breaker of the reference endpoint is open
+func (c *RealtimeClient) IsReferenceAvailable() bool {
+	return c.transport.IsAvailable(c.referenceUrl)
+}
+
+// RealtimeUrl returns the url of the realtime node
+func (c *RealtimeClient) RealtimeUrl() string {
+	return c.realtimeUrl
+}
+
+// ReferenceUrl returns the url of the reference node
+func (c *RealtimeClient) ReferenceUrl() string {
+	return c.referenceUrl
 }
 
 // RealtimeBlockNumber returns the number of the most recent block in real-time
-func (c *RealtimeClient) RealtimeBlockNumber() (uint64, error) {
-	response, err := client.JSONRPCCall(c.rpcUrl, "realtime_blockNumber")
+func (c *RealtimeClient) RealtimeBlockNumber(ctx context.Context) (uint64, error) {
+	response, err := c.jsonRPCCall(ctx, c.realtimeUrl, "realtime_blockNumber")
 	if err != nil {
 		return 0, err
 	}
@@ -42,8 +85,8 @@ func (c *RealtimeClient) RealtimeBlockNumber() (uint64, error) {
 }
 
 // RealtimeGetBlockTransactionCountByNumber returns the number of transactions in a block by number in real-time
-func (c *RealtimeClient) RealtimeGetBlockTransactionCountByNumber(blockNumber uint64) (uint64, error) {
-	response, err := client.JSONRPCCall(c.rpcUrl, "realtime_getBlockTransactionCountByNumber", blockNumber)
+func (c *RealtimeClient) RealtimeGetBlockTransactionCountByNumber(ctx context.Context, blockNumber uint64) (uint64, error) {
+	response, err := c.jsonRPCCall(ctx, c.realtimeUrl, "realtime_getBlockTransactionCountByNumber", blockNumber)
 	if err != nil {
 		return 0, err
 	}
@@ -54,28 +97,32 @@ func (c *RealtimeClient) RealtimeGetBlockTransactionCountByNumber(blockNumber ui
 	return transHexToUint64(response.Result)
 }
 
-// RealtimeGetTransactionByHash returns the information about a transaction requested by transaction hash in real-time
-func (c *RealtimeClient) RealtimeGetTransactionByHash(txHash common.Hash, includeExtraInfo *bool) (rpcTypes.Transaction, error) {
-	response, err := client.JSONRPCCall(c.rpcUrl, "realtime_getTransactionByHash", txHash, includeExtraInfo)
+// RealtimeGetTransactionByHash returns the information about a transaction requested by transaction hash in real-time,
+// or nil if the transaction does not exist
+func (c *RealtimeClient) RealtimeGetTransactionByHash(ctx context.Context, txHash common.Hash, includeExtraInfo *bool) (*rpcTypes.Transaction, error) {
+	response, err := c.jsonRPCCall(ctx, c.realtimeUrl, "realtime_getTransactionByHash", txHash, includeExtraInfo)
 	if err != nil {
-		return rpcTypes.Transaction{}, err
+		return nil, err
 	}
 	if response.Error != nil {
-		return rpcTypes.Transaction{}, fmt.Errorf("%d - %s", response.Error.Code, response.Error.Message)
+		return nil, fmt.Errorf("%d - %s", response.Error.Code, response.Error.Message)
+	}
+	if isNullResult(response.Result) {
+		return nil, nil
 	}
 
 	result := rpcTypes.Transaction{}
 	err = json.Unmarshal(response.Result, &result)
 	if err != nil {
-		return rpcTypes.Transaction{}, err
+		return nil, err
 	}
 
-	return result, nil
+	return &result, nil
 }
 
 // RealtimeGetTransactionByHash returns raw information about a transaction requested by transaction hash in real-time
-func (c *RealtimeClient) RealtimeGetRawTransactionByHash(txHash common.Hash) ([]byte, error) {
-	response, err := client.JSONRPCCall(c.rpcUrl, "realtime_getRawTransactionByHash", txHash)
+func (c *RealtimeClient) RealtimeGetRawTransactionByHash(ctx context.Context, txHash common.Hash) ([]byte, error) {
+	response, err := c.jsonRPCCall(ctx, c.realtimeUrl, "realtime_getRawTransactionByHash", txHash)
 	if err != nil {
 		return nil, err
 	}
@@ -83,7 +130,8 @@ func (c *RealtimeClient) RealtimeGetRawTransactionByHash(txHash common.Hash) ([]
 		return nil, fmt.Errorf("%d - %s", response.Error.Code, response.Error.Message)
 	}
 
-	var result []byte
+	// The raw transaction is returned as a hex string, not base64
+	var result hexutility.Bytes
 	err = json.Unmarshal(response.Result, &result)
 	if err != nil {
 		return nil, err
@@ -92,15 +140,19 @@ func (c *RealtimeClient) RealtimeGetRawTransactionByHash(txHash common.Hash) ([]
 	return result, nil
 }
 
-// RealtimeGetTransactionReceipt returns the receipt of a transaction by transaction hash in real-time
-func (c *RealtimeClient) RealtimeGetTransactionReceipt(txHash common.Hash) (*types.Receipt, error) {
-	response, err := client.JSONRPCCall(c.rpcUrl, "realtime_getTransactionReceipt", txHash)
+// RealtimeGetTransactionReceipt returns the receipt of a transaction by transaction hash in real-time, or nil if
+// the transaction is not in the realtime cache
+func (c *RealtimeClient) RealtimeGetTransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	response, err := c.jsonRPCCall(ctx, c.realtimeUrl, "realtime_getTransactionReceipt", txHash)
 	if err != nil {
 		return nil, err
 	}
 	if response.Error != nil {
 		return nil, fmt.Errorf("%d - %s", response.Error.Code, response.Error.Message)
 	}
+	if isNullResult(response.Result) {
+		return nil, nil
+	}
 
 	var result types.Receipt
 	err = json.Unmarshal(response.Result, &result)
@@ -112,8 +164,8 @@ func (c *RealtimeClient) RealtimeGetTransactionReceipt(txHash common.Hash) (*typ
 }
 
 // RealtimeGetInternalTransactions returns the internal transactions for a given transaction hash in real-time
-func (c *RealtimeClient) RealtimeGetInternalTransactions(txHash common.Hash) ([]zktypes.InnerTx, error) {
-	response, err := client.JSONRPCCall(c.rpcUrl, "realtime_getInternalTransactions", txHash)
+func (c *RealtimeClient) RealtimeGetInternalTransactions(ctx context.Context, txHash common.Hash) ([]zktypes.InnerTx, error) {
+	response, err := c.jsonRPCCall(ctx, c.realtimeUrl, "realtime_getInternalTransactions", txHash)
 	if err != nil {
 		return nil, err
 	}
@@ -131,8 +183,8 @@ func (c *RealtimeClient) RealtimeGetInternalTransactions(txHash common.Hash) ([]
 }
 
 // RealtimeGetBalance returns the balance of an account in real-time
-func (c *RealtimeClient) RealtimeGetBalance(address common.Address) (*big.Int, error) {
-	response, err := client.JSONRPCCall(c.rpcUrl, "realtime_getBalance", address)
+func (c *RealtimeClient) RealtimeGetBalance(ctx context.Context, address common.Address) (*big.Int, error) {
+	response, err := c.jsonRPCCall(ctx, c.realtimeUrl, "realtime_getBalance", address)
 	if err != nil {
 		return nil, err
 	}
@@ -160,8 +212,8 @@ func (c *RealtimeClient) RealtimeGetBalance(address common.Address) (*big.Int, e
 }
 
 // RealtimeGetCode returns the code at a given address in real-time
-func (c *RealtimeClient) RealtimeGetCode(address common.Address) (string, error) {
-	response, err := client.JSONRPCCall(c.rpcUrl, "realtime_getCode", address)
+func (c *RealtimeClient) RealtimeGetCode(ctx context.Context, address common.Address) (string, error) {
+	response, err := c.jsonRPCCall(ctx, c.realtimeUrl, "realtime_getCode", address)
 	if err != nil {
 		return "", err
 	}
@@ -179,8 +231,8 @@ func (c *RealtimeClient) RealtimeGetCode(address common.Address) (string, error)
 }
 
 // RealtimeGetTransactionCount returns the number of transactions sent from an address in real-time
-func (c *RealtimeClient) RealtimeGetTransactionCount(address common.Address) (uint64, error) {
-	response, err := client.JSONRPCCall(c.rpcUrl, "realtime_getTransactionCount", address)
+func (c *RealtimeClient) RealtimeGetTransactionCount(ctx context.Context, address common.Address) (uint64, error) {
+	response, err := c.jsonRPCCall(ctx, c.realtimeUrl, "realtime_getTransactionCount", address)
 	if err != nil {
 		return 0, err
 	}
@@ -192,8 +244,8 @@ func (c *RealtimeClient) RealtimeGetTransactionCount(address common.Address) (ui
 }
 
 // RealtimeGetStorageAt returns the value from a storage position at a given address in real-time
-func (c *RealtimeClient) RealtimeGetStorageAt(address common.Address, position string) (string, error) {
-	response, err := client.JSONRPCCall(c.rpcUrl, "realtime_getStorageAt", address, position)
+func (c *RealtimeClient) RealtimeGetStorageAt(ctx context.Context, address common.Address, position string) (string, error) {
+	response, err := c.jsonRPCCall(ctx, c.realtimeUrl, "realtime_getStorageAt", address, position)
 	if err != nil {
 		return "", err
 	}
@@ -211,14 +263,14 @@ func (c *RealtimeClient) RealtimeGetStorageAt(address common.Address, position s
 }
 
 // RealtimeCall executes a new message call immediately without creating a transaction in real-time
-func (c *RealtimeClient) RealtimeCall(to common.Address, value string, data string) (string, error) {
+func (c *RealtimeClient) RealtimeCall(ctx context.Context, to common.Address, value string, data string) (string, error) {
 	txParams := map[string]any{
 		"to":    to,
 		"value": value,
 		"data":  data,
 	}
 
-	response, err := client.JSONRPCCall(c.rpcUrl, "realtime_call", txParams)
+	response, err := c.jsonRPCCall(ctx, c.realtimeUrl, "realtime_call", txParams)
 	if err != nil {
 		return "", err
 	}
@@ -247,7 +299,7 @@ func (c *RealtimeClient) RealtimeGetTokenBalance(
 	}
 
 	// Make the realtime_call
-	result, err := c.RealtimeCall(erc20Addr, "0x0", fmt.Sprintf("0x%x", data))
+	result, err := c.RealtimeCall(ctx, erc20Addr, "0x0", fmt.Sprintf("0x%x", data))
 	if err != nil {
 		return nil, fmt.Errorf("failed to call contract: %v", err)
 	}
@@ -266,9 +318,127 @@ func (c *RealtimeClient) RealtimeGetTokenBalance(
 	return balance, nil
 }
 
+// RealtimeReadAtHeight executes the realtime read and returns the realtime height it was served at. The realtime
+// height is sampled before and after the read, and ErrRealtimeHeightChanged is returned if a block landed in between
+func (c *RealtimeClient) RealtimeReadAtHeight(ctx context.Context, read func() error) (uint64, error) {
+	startHeight, err := c.RealtimeBlockNumber(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := read(); err != nil {
+		return 0, err
+	}
+	endHeight, err := c.RealtimeBlockNumber(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if startHeight != endHeight {
+		return 0, ErrRealtimeHeightChanged
+	}
+
+	return startHeight, nil
+}
+
+// RealtimeGetBalanceAtHeight returns the balance of an account in real-time, with the realtime height it was read at
+func (c *RealtimeClient) RealtimeGetBalanceAtHeight(ctx context.Context, address common.Address) (*big.Int, uint64, error) {
+	var balance *big.Int
+	height, err := c.RealtimeReadAtHeight(ctx, func() error {
+		var err error
+		balance, err = c.RealtimeGetBalance(ctx, address)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return balance, height, nil
+}
+
+// RealtimeGetTokenBalanceAtHeight returns the erc20 token balance of an account in real-time, with the realtime
+// height it was read at
+func (c *RealtimeClient) RealtimeGetTokenBalanceAtHeight(
+	ctx context.Context,
+	toAddress common.Address,
+	erc20Addr common.Address,
+) (*big.Int, uint64, error) {
+	var balance *big.Int
+	height, err := c.RealtimeReadAtHeight(ctx, func() error {
+		var err error
+		balance, err = c.RealtimeGetTokenBalance(ctx, toAddress, erc20Addr)
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return balance, height, nil
+}
+
+// RealtimeGetTransactionCountAtHeight returns the nonce of an account in real-time, with the realtime height it
+// was read at
+func (c *RealtimeClient) RealtimeGetTransactionCountAtHeight(ctx context.Context, address common.Address) (uint64, uint64, error) {
+	var nonce uint64
+	height, err := c.RealtimeReadAtHeight(ctx, func() error {
+		var err error
+		nonce, err = c.RealtimeGetTransactionCount(ctx, address)
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return nonce, height, nil
+}
+
+// RealtimeGetCodeAtHeight returns the code at a given address in real-time, with the realtime height it was read at
+func (c *RealtimeClient) RealtimeGetCodeAtHeight(ctx context.Context, address common.Address) (string, uint64, error) {
+	var code string
+	height, err := c.RealtimeReadAtHeight(ctx, func() error {
+		var err error
+		code, err = c.RealtimeGetCode(ctx, address)
+		return err
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	return code, height, nil
+}
+
+// RealtimeGetStorageAtHeight returns the value from a storage position at a given address in real-time, with the
+// realtime height it was read at
+func (c *RealtimeClient) RealtimeGetStorageAtHeight(ctx context.Context, address common.Address, position string) (string, uint64, error) {
+	var value string
+	height, err := c.RealtimeReadAtHeight(ctx, func() error {
+		var err error
+		value, err = c.RealtimeGetStorageAt(ctx, address, position)
+		return err
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	return value, height, nil
+}
+
+// RealtimeCallAtHeight executes a new message call in real-time, with the realtime height it was executed at
+func (c *RealtimeClient) RealtimeCallAtHeight(ctx context.Context, to common.Address, value string, data string) (string, uint64, error) {
+	var result string
+	height, err := c.RealtimeReadAtHeight(ctx, func() error {
+		var err error
+		result, err = c.RealtimeCall(ctx, to, value, data)
+		return err
+	})
+	if err != nil {
+		return "", 0, err
+	}
+
+	return result, height, nil
+}
+
 // RealtimeDumpStateCache dumps the state cache
-func (c *RealtimeClient) RealtimeDumpStateCache() error {
-	response, err := client.JSONRPCCall(c.rpcUrl, "realtime_dumpStateCache")
+func (c *RealtimeClient) RealtimeDumpStateCache(ctx context.Context) error {
+	response, err := c.jsonRPCCall(ctx, c.realtimeUrl, "realtime_dumpStateCache")
 	if err != nil {
 		return err
 	}
@@ -280,8 +450,8 @@ func (c *RealtimeClient) RealtimeDumpStateCache() error {
 }
 
 // EthGetBalance returns the balance of an account
-func (c *RealtimeClient) EthGetBalance(address common.Address, block string) (*big.Int, error) {
-	response, err := client.JSONRPCCall(c.rpcUrl, "eth_getBalance", address, block)
+func (c *RealtimeClient) EthGetBalance(ctx context.Context, address common.Address, block string) (*big.Int, error) {
+	response, err := c.jsonRPCCall(ctx, c.referenceUrl, "eth_getBalance", address, block)
 	if err != nil {
 		return nil, err
 	}
@@ -308,9 +478,184 @@ func (c *RealtimeClient) EthGetBalance(address common.Address, block string) (*b
 	return balance, nil
 }
 
+// EthGetCode returns the code at a given address
+func (c *RealtimeClient) EthGetCode(ctx context.Context, address common.Address, block string) (string, error) {
+	response, err := c.jsonRPCCall(ctx, c.referenceUrl, "eth_getCode", address, block)
+	if err != nil {
+		return "", err
+	}
+	if response.Error != nil {
+		return "", fmt.Errorf("%d - %s", response.Error.Code, response.Error.Message)
+	}
+
+	var code string
+	err = json.Unmarshal(response.Result, &code)
+	if err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// EthGetStorageAt returns the value from a storage position at a given address
+func (c *RealtimeClient) EthGetStorageAt(ctx context.Context, address common.Address, position string, block string) (string, error) {
+	response, err := c.jsonRPCCall(ctx, c.referenceUrl, "eth_getStorageAt", address, position, block)
+	if err != nil {
+		return "", err
+	}
+	if response.Error != nil {
+		return "", fmt.Errorf("%d - %s", response.Error.Code, response.Error.Message)
+	}
+
+	var result string
+	err = json.Unmarshal(response.Result, &result)
+	if err != nil {
+		return "", err
+	}
+
+	return result, nil
+}
+
+// EthGetTransactionReceipt returns the receipt of a transaction by transaction hash, or nil if the transaction
+// has not been mined
+func (c *RealtimeClient) EthGetTransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	response, err := c.jsonRPCCall(ctx, c.referenceUrl, "eth_getTransactionReceipt", txHash)
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("%d - %s", response.Error.Code, response.Error.Message)
+	}
+	if isNullResult(response.Result) {
+		return nil, nil
+	}
+
+	var result types.Receipt
+	err = json.Unmarshal(response.Result, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// EthGetInternalTransactions returns the internal transactions for a given transaction hash
+func (c *RealtimeClient) EthGetInternalTransactions(ctx context.Context, txHash common.Hash) ([]zktypes.InnerTx, error) {
+	response, err := c.jsonRPCCall(ctx, c.referenceUrl, "eth_getInternalTransactions", txHash)
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("%d - %s", response.Error.Code, response.Error.Message)
+	}
+
+	result := []zktypes.InnerTx{}
+	err = json.Unmarshal(response.Result, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// EthGetBlockByNumber returns the block with its transaction hashes by block number, or nil if the block does
+// not exist
+func (c *RealtimeClient) EthGetBlockByNumber(ctx context.Context, blockNumber uint64) (*Block, error) {
+	response, err := c.jsonRPCCall(ctx, c.referenceUrl, "eth_getBlockByNumber", BlockNumberToHex(blockNumber), false)
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("%d - %s", response.Error.Code, response.Error.Message)
+	}
+	if isNullResult(response.Result) {
+		return nil, nil
+	}
+
+	var result Block
+	err = json.Unmarshal(response.Result, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// EthGetTransactionByHash returns the information about a transaction requested by transaction hash, or nil if
+// the transaction does not exist
+func (c *RealtimeClient) EthGetTransactionByHash(ctx context.Context, txHash common.Hash, includeExtraInfo *bool) (*rpcTypes.Transaction, error) {
+	response, err := c.jsonRPCCall(ctx, c.referenceUrl, "eth_getTransactionByHash", txHash, includeExtraInfo)
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("%d - %s", response.Error.Code, response.Error.Message)
+	}
+	if isNullResult(response.Result) {
+		return nil, nil
+	}
+
+	result := rpcTypes.Transaction{}
+	err = json.Unmarshal(response.Result, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// EthGetRawTransactionByHash returns raw information about a transaction requested by transaction hash
+func (c *RealtimeClient) EthGetRawTransactionByHash(ctx context.Context, txHash common.Hash) ([]byte, error) {
+	response, err := c.jsonRPCCall(ctx, c.referenceUrl, "eth_getRawTransactionByHash", txHash)
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("%d - %s", response.Error.Code, response.Error.Message)
+	}
+
+	var result hexutility.Bytes
+	err = json.Unmarshal(response.Result, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// EthGetLogs returns the logs of the block, optionally filtered by contract addresses and first topics
+func (c *RealtimeClient) EthGetLogs(ctx context.Context, blockNumber uint64, addresses []common.Address, topics []common.Hash) ([]*types.Log, error) {
+	filter := map[string]any{
+		"fromBlock": BlockNumberToHex(blockNumber),
+		"toBlock":   BlockNumberToHex(blockNumber),
+	}
+	if len(addresses) > 0 {
+		filter["address"] = addresses
+	}
+	if len(topics) > 0 {
+		filter["topics"] = [][]common.Hash{topics}
+	}
+
+	response, err := c.jsonRPCCall(ctx, c.referenceUrl, "eth_getLogs", filter)
+	if err != nil {
+		return nil, err
+	}
+	if response.Error != nil {
+		return nil, fmt.Errorf("%d - %s", response.Error.Code, response.Error.Message)
+	}
+
+	result := []*types.Log{}
+	err = json.Unmarshal(response.Result, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
 // EthGetTransactionCount returns the number of transactions sent from an address
-func (c *RealtimeClient) EthGetTransactionCount(address common.Address, block string) (uint64, error) {
-	response, err := client.JSONRPCCall(c.rpcUrl, "eth_getTransactionCount", address, block)
+func (c *RealtimeClient) EthGetTransactionCount(ctx context.Context, address common.Address, block string) (uint64, error) {
+	response, err := c.jsonRPCCall(ctx, c.referenceUrl, "eth_getTransactionCount", address, block)
 	if err != nil {
 		return 0, err
 	}
@@ -321,10 +666,13 @@ func (c *RealtimeClient) EthGetTransactionCount(address common.Address, block st
 	return transHexToUint64(response.Result)
 }
 
+// EthGetTokenBalance returns the erc20 token balance of an account at the block number, or at the latest
+// block if the block number is nil
 func (c *RealtimeClient) EthGetTokenBalance(
 	ctx context.Context,
 	addr common.Address,
 	erc20Addr common.Address,
+	blockNumber *big.Int,
 ) (*big.Int, error) {
 	// Pack the balanceOf function call
 	data, err := erc20ABI.Pack("balanceOf", addr)
@@ -333,10 +681,15 @@ func (c *RealtimeClient) EthGetTokenBalance(
 	}
 
 	// Make the eth_call
-	result, err := c.client.CallContract(ctx, ethereum.CallMsg{
-		To:   &erc20Addr,
-		Data: data,
-	}, nil)
+	var result []byte
+	err = c.transport.Do(ctx, c.referenceUrl, "eth_call", func(ctx context.Context) error {
+		var err error
+		result, err = c.client.CallContract(ctx, ethereum.CallMsg{
+			To:   &erc20Addr,
+			Data: data,
+		}, blockNumber)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to call contract: %v", err)
 	}
@@ -351,8 +704,31 @@ func (c *RealtimeClient) EthGetTokenBalance(
 	return balance, nil
 }
 
+// EthCall executes a new message call at the block number, or at the latest block if the block number is nil
+func (c *RealtimeClient) EthCall(
+	ctx context.Context,
+	to common.Address,
+	data []byte,
+	blockNumber *big.Int,
+) ([]byte, error) {
+	var result []byte
+	err := c.transport.Do(ctx, c.referenceUrl, "eth_call", func(ctx context.Context) error {
+		var err error
+		result, err = c.client.CallContract(ctx, ethereum.CallMsg{
+			To:   &to,
+			Data: data,
+		}, blockNumber)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to call contract: %v", err)
+	}
+
+	return result, nil
+}
+
 func (c *RealtimeClient) EthGetBlockNumber(ctx context.Context) (uint64, error) {
-	response, err := client.JSONRPCCall(c.rpcUrl, "eth_blockNumber")
+	response, err := c.jsonRPCCall(ctx, c.referenceUrl, "eth_blockNumber")
 	if err != nil {
 		return 0, err
 	}
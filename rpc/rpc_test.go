@@ -0,0 +1,77 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+)
+
+// newMethodRecorder serves every JSON-RPC request with a one result, recording the called methods
+func newMethodRecorder(t *testing.T) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	methods := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("invalid request: %v", err)
+			return
+		}
+		mu.Lock()
+		methods = append(methods, request.Method)
+		mu.Unlock()
+		result := "0x1"
+		if request.Method == "eth_call" {
+			result = "0x01"
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": request.ID, "result": result})
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, methods...)
+	}
+}
+
+func TestRealtimeClientRoutesEndpoints(t *testing.T) {
+	realtimeServer, realtimeMethods := newMethodRecorder(t)
+	referenceServer, referenceMethods := newMethodRecorder(t)
+	client, err := NewRealtimeClient(realtimeServer.URL, referenceServer.URL, 0, NewTransport(TransportConfig{}))
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+
+	ctx := context.Background()
+	address := common.HexToAddress("0x01")
+	if _, err := client.RealtimeBlockNumber(ctx); err != nil {
+		t.Fatalf("realtime block number: %v", err)
+	}
+	if _, err := client.RealtimeGetBalance(ctx, address); err != nil {
+		t.Fatalf("realtime balance: %v", err)
+	}
+	if _, err := client.EthGetBlockNumber(ctx); err != nil {
+		t.Fatalf("eth block number: %v", err)
+	}
+	if _, err := client.EthGetBalance(ctx, address, "latest"); err != nil {
+		t.Fatalf("eth balance: %v", err)
+	}
+	if _, err := client.EthCall(ctx, address, []byte{1}, nil); err != nil {
+		t.Fatalf("eth call: %v", err)
+	}
+
+	if got, want := realtimeMethods(), []string{"realtime_blockNumber", "realtime_getBalance"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got realtime node methods %v, want %v", got, want)
+	}
+	if got, want := referenceMethods(), []string{"eth_blockNumber", "eth_getBalance", "eth_call"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got reference node methods %v, want %v", got, want)
+	}
+	if client.RealtimeUrl() != realtimeServer.URL || client.ReferenceUrl() != referenceServer.URL {
+		t.Errorf("got realtime url %s and reference url %s, want %s and %s", client.RealtimeUrl(), client.ReferenceUrl(), realtimeServer.URL, referenceServer.URL)
+	}
+}
@@ -0,0 +1,60 @@
+package rpc
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CallTiming is the timing of an RPC call attempt
+type CallTiming struct {
+	Url        string    `json:"url"`
+	Method     string    `json:"method"`
+	Start      time.Time `json:"start"`
+	DurationMS int64     `json:"durationMs"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// CallRecorder records the timings of the RPC calls made with a context, e.g. the calls of a single comparison
+type CallRecorder struct {
+	mu      sync.Mutex
+	timings []CallTiming
+}
+
+type callRecorderKey struct{}
+
+// WithCallRecorder returns a copy of the context recording the timings of the RPC calls made with it
+func WithCallRecorder(ctx context.Context) context.Context {
+	return context.WithValue(ctx, callRecorderKey{}, &CallRecorder{})
+}
+
+// CallTimings returns the timings of the RPC calls recorded so far on the context, empty if the context has no
+// recorder
+func CallTimings(ctx context.Context) []CallTiming {
+	recorder, ok := ctx.Value(callRecorderKey{}).(*CallRecorder)
+	if !ok {
+		return []CallTiming{}
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return append([]CallTiming{}, recorder.timings...)
+}
+
+func recordCallTiming(ctx context.Context, url string, method string, start time.Time, err error) {
+	recorder, ok := ctx.Value(callRecorderKey{}).(*CallRecorder)
+	if !ok {
+		return
+	}
+	timing := CallTiming{
+		Url:        url,
+		Method:     method,
+		Start:      start.UTC(),
+		DurationMS: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		timing.Error = err.Error()
+	}
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.timings = append(recorder.timings, timing)
+}
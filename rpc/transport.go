@@ -0,0 +1,304 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	jsonrpcTypes "github.com/ledgerwatch/erigon/zkevm/jsonrpc/types"
+	"github.com/sieniven/realtime-compare-tool/metrics"
+)
+
+// TransportConfig is the timeout, retry and circuit breaker policy of the RPC transport
+type TransportConfig struct {
+	// Deadline of each request attempt
+	Timeout time.Duration
+	// Retries of a request after a transient error, with the backoff doubling after every retry
+	MaxRetries   int
+	RetryBackoff time.Duration
+	// Consecutive transient errors that open the circuit breaker of an endpoint, and how long the breaker stays
+	// open before a trial request is let through
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// Maximum concurrent requests in flight to each endpoint, 0 for no limit
+	EndpointLimit int
+}
+
+// httpStatusError is returned when the endpoint responds with a non 200 http status
+type httpStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (err *httpStatusError) Error() string {
+	return fmt.Sprintf("http request failed with status %s", err.Status)
+}
+
+// circuitBreaker tracks the consecutive transient errors of an endpoint. Once the cooldown of the open breaker has
+// passed, the breaker is half-open and admits a single trial request, which closes the breaker on success or
+// restarts the cooldown on failure
+type circuitBreaker struct {
+	mu        sync.Mutex
+	failures  int
+	open      bool
+	openUntil time.Time
+	// Set while the trial request of the half-open breaker is in flight
+	probing bool
+}
+
+// allow returns true if the breaker is closed, or if the breaker is half-open and no trial request is in flight.
+// The second return value is true if the admitted request is the trial request
+func (breaker *circuitBreaker) allow() (bool, bool) {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	if !breaker.open {
+		return true, false
+	}
+	if breaker.probing || time.Now().Before(breaker.openUntil) {
+		return false, false
+	}
+	breaker.probing = true
+	return true, true
+}
+
+// releaseProbe lets another trial request through, when the trial request was abandoned by its caller
+func (breaker *circuitBreaker) releaseProbe() {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	breaker.probing = false
+}
+
+func (breaker *circuitBreaker) isOpen() bool {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	return breaker.open
+}
+
+// onSuccess closes the breaker, and returns true if the breaker was open
+func (breaker *circuitBreaker) onSuccess() bool {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	breaker.failures = 0
+	breaker.probing = false
+	if breaker.open {
+		breaker.open = false
+		return true
+	}
+	return false
+}
+
+// onFailure opens the breaker once the failures reach the threshold, and returns true if the breaker was closed
+func (breaker *circuitBreaker) onFailure(threshold int, cooldown time.Duration) bool {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+
+	breaker.failures++
+	breaker.probing = false
+	if threshold <= 0 || breaker.failures < threshold {
+		return false
+	}
+	wasOpen := breaker.open
+	breaker.open = true
+	breaker.openUntil = time.Now().Add(cooldown)
+	return !wasOpen
+}
+
+// Transport sends the JSON-RPC requests over http with per request deadlines, exponential backoff retries on
+// transient errors, and a circuit breaker per endpoint. The transport is shared across the clients calling the
+// same endpoints
+type Transport struct {
+	config     TransportConfig
+	httpClient *http.Client
+	limiter    *EndpointLimiter
+
+	mu              sync.Mutex
+	breakers        map[string]*circuitBreaker
+	onBreakerChange func(url string, open bool)
+}
+
+func NewTransport(config TransportConfig) *Transport {
+	// The client timeout bounds every request, including the batch requests and the response body reads, even if
+	// the caller context has no deadline
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = time.Duration(DefaultHttpTimeoutMS) * time.Millisecond
+	}
+	return &Transport{
+		config:     config,
+		httpClient: &http.Client{Timeout: timeout},
+		limiter:    NewEndpointLimiter(config.EndpointLimit),
+		breakers:   make(map[string]*circuitBreaker),
+	}
+}
+
+// OnBreakerChange sets the callback reporting when the circuit breaker of an endpoint opens or closes
+func (t *Transport) OnBreakerChange(callback func(url string, open bool)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.onBreakerChange = callback
+}
+
+// IsAvailable returns false while the circuit breaker of the endpoint is open
+func (t *Transport) IsAvailable(url string) bool {
+	return !t.breaker(url).isOpen()
+}
+
+func (t *Transport) breaker(url string) *circuitBreaker {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	breaker, ok := t.breakers[url]
+	if !ok {
+		breaker = &circuitBreaker{}
+		t.breakers[url] = breaker
+	}
+	return breaker
+}
+
+func (t *Transport) reportBreakerChange(url string, open bool) {
+	t.mu.Lock()
+	callback := t.onBreakerChange
+	t.mu.Unlock()
+
+	if callback != nil {
+		callback(url, open)
+	}
+}
+
+// Do runs the call of the method against the endpoint with the transport policy. Each attempt holds a request slot
+// of the endpoint, is bounded by the request timeout and has its latency recorded, also on the call recorder of the
+// context. Only transient errors are retried and count towards the circuit breaker, errors returned by the node
+// itself mean the endpoint is up
+func (t *Transport) Do(ctx context.Context, url string, method string, call func(ctx context.Context) error) error {
+	breaker := t.breaker(url)
+	backoff := t.config.RetryBackoff
+	var err error
+	for attempt := 0; attempt <= t.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		allowed, probe := breaker.allow()
+		if !allowed {
+			return fmt.Errorf("%w: %s", ErrCircuitOpen, url)
+		}
+
+		err = t.attempt(ctx, url, method, call)
+		if ctx.Err() != nil {
+			// The caller gave up, which says nothing about the endpoint health
+			if probe {
+				breaker.releaseProbe()
+			}
+			return err
+		}
+		if !isTransientError(err) {
+			if breaker.onSuccess() {
+				t.reportBreakerChange(url, false)
+			}
+			return err
+		}
+		if breaker.onFailure(t.config.BreakerThreshold, t.config.BreakerCooldown) {
+			t.reportBreakerChange(url, true)
+		}
+	}
+	return err
+}
+
+func (t *Transport) attempt(ctx context.Context, url string, method string, call func(ctx context.Context) error) error {
+	release, err := t.limiter.Acquire(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer release()
+	start := time.Now()
+	defer metrics.ObserveRpcLatency(url, method, start)
+
+	if t.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.config.Timeout)
+		defer cancel()
+	}
+	err = call(ctx)
+	recordCallTiming(ctx, url, method, start, err)
+	return err
+}
+
+// Call sends the JSON-RPC request to the endpoint
+func (t *Transport) Call(ctx context.Context, url string, method string, parameters ...interface{}) (jsonrpcTypes.Response, error) {
+	if parameters == nil {
+		parameters = []interface{}{}
+	}
+	request := rpcRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  method,
+		Params:  parameters,
+	}
+	var response jsonrpcTypes.Response
+	err := t.Do(ctx, url, method, func(ctx context.Context) error {
+		return t.post(ctx, url, request, &response)
+	})
+	if err != nil {
+		return jsonrpcTypes.Response{}, err
+	}
+	return response, nil
+}
+
+// post sends the json request body to the endpoint and decodes the json response
+func (t *Transport) post(ctx context.Context, url string, request interface{}, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+
+	httpResponse, err := t.httpClient.Do(httpRequest)
+	if err != nil {
+		return err
+	}
+	defer httpResponse.Body.Close()
+	if httpResponse.StatusCode != http.StatusOK {
+		return &httpStatusError{StatusCode: httpResponse.StatusCode, Status: httpResponse.Status}
+	}
+
+	return json.NewDecoder(httpResponse.Body).Decode(response)
+}
+
+// isTransientError returns true for the network, timeout and server side http errors that are worth retrying
+func isTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package rpc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	const threshold = 2
+	const cooldown = 20 * time.Millisecond
+
+	type step struct {
+		action  string
+		allowed bool
+		probe   bool
+		changed bool
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "stays closed below the threshold",
+			steps: []step{
+				{action: "failure", changed: false},
+				{action: "allow", allowed: true},
+				{action: "success", changed: false},
+				{action: "failure", changed: false},
+				{action: "allow", allowed: true},
+			},
+		},
+		{
+			name: "opens at the threshold",
+			steps: []step{
+				{action: "failure"},
+				{action: "failure", changed: true},
+				{action: "allow", allowed: false},
+			},
+		},
+		{
+			name: "half-open admits a single probe that closes on success",
+			steps: []step{
+				{action: "failure"},
+				{action: "failure", changed: true},
+				{action: "wait"},
+				{action: "allow", allowed: true, probe: true},
+				{action: "allow", allowed: false},
+				{action: "success", changed: true},
+				{action: "allow", allowed: true},
+			},
+		},
+		{
+			name: "failed probe restarts the cooldown",
+			steps: []step{
+				{action: "failure"},
+				{action: "failure", changed: true},
+				{action: "wait"},
+				{action: "allow", allowed: true, probe: true},
+				{action: "failure", changed: false},
+				{action: "allow", allowed: false},
+				{action: "wait"},
+				{action: "allow", allowed: true, probe: true},
+			},
+		},
+		{
+			name: "released probe admits another probe",
+			steps: []step{
+				{action: "failure"},
+				{action: "failure", changed: true},
+				{action: "wait"},
+				{action: "allow", allowed: true, probe: true},
+				{action: "release"},
+				{action: "allow", allowed: true, probe: true},
+			},
+		},
+	}
+	for _, test := range tests {
+		breaker := &circuitBreaker{}
+		for i, step := range test.steps {
+			switch step.action {
+			case "allow":
+				allowed, probe := breaker.allow()
+				if allowed != step.allowed || probe != step.probe {
+					t.Errorf("%s: step %d: got allowed %v probe %v, want allowed %v probe %v", test.name, i, allowed, probe, step.allowed, step.probe)
+				}
+			case "success":
+				if changed := breaker.onSuccess(); changed != step.changed {
+					t.Errorf("%s: step %d: got changed %v on success, want %v", test.name, i, changed, step.changed)
+				}
+			case "failure":
+				if changed := breaker.onFailure(threshold, cooldown); changed != step.changed {
+					t.Errorf("%s: step %d: got changed %v on failure, want %v", test.name, i, changed, step.changed)
+				}
+			case "release":
+				breaker.releaseProbe()
+			case "wait":
+				time.Sleep(cooldown + 5*time.Millisecond)
+			}
+		}
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	breaker := &circuitBreaker{}
+	for i := 0; i < 10; i++ {
+		if breaker.onFailure(0, time.Second) {
+			t.Fatal("breaker opened without a threshold")
+		}
+	}
+	if allowed, _ := breaker.allow(); !allowed || breaker.isOpen() {
+		t.Error("breaker without a threshold rejected the request")
+	}
+}
@@ -0,0 +1,54 @@
+package rpc
+
+import (
+	"encoding/json"
+
+	"github.com/ledgerwatch/erigon-lib/common"
+	"github.com/ledgerwatch/erigon-lib/common/hexutil"
+)
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Block is the block returned by eth_getBlockByNumber without full transaction objects
+type Block struct {
+	Hash         common.Hash    `json:"hash"`
+	Number       hexutil.Uint64 `json:"number"`
+	Transactions []common.Hash  `json:"transactions"`
+}
+
+// Header is the block header returned by the newHeads subscription
+type Header struct {
+	Hash       common.Hash    `json:"hash"`
+	ParentHash common.Hash    `json:"parentHash"`
+	Number     hexutil.Uint64 `json:"number"`
+}
+
+// RealtimeTxNotification is the realtime transaction subscription notification. The block number is only set once
+// the transaction is confirmed
+type RealtimeTxNotification struct {
+	Hash        common.Hash     `json:"hash"`
+	BlockNumber *hexutil.Uint64 `json:"blockNumber"`
+}
+
+// RealtimeBalanceNotification is the realtime balance change subscription notification
+type RealtimeBalanceNotification struct {
+	Address     common.Address  `json:"address"`
+	Balance     *hexutil.Big    `json:"balance"`
+	BlockNumber *hexutil.Uint64 `json:"blockNumber"`
+}
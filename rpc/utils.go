@@ -16,7 +16,9 @@ import (
 )
 
 var (
-	erc20ABI, _ = abi.JSON(strings.NewReader(erc20ABIJson))
+	erc20ABI, _   = abi.JSON(strings.NewReader(erc20ABIJson))
+	erc721ABI, _  = abi.JSON(strings.NewReader(erc721ABIJson))
+	erc1155ABI, _ = abi.JSON(strings.NewReader(erc1155ABIJson))
 )
 
 type ethClienter interface {
@@ -59,6 +61,61 @@ func RevertReason(
 	return unpackedMsg, nil
 }
 
+// ERC20Method returns the erc20 abi method by name
+func ERC20Method(name string) (*abi.Method, error) {
+	return abiMethod(erc20ABI, "erc20", name)
+}
+
+// PackERC20 packs the call data of the erc20 method
+func PackERC20(name string, args ...interface{}) ([]byte, error) {
+	return packABI(erc20ABI, name, args...)
+}
+
+// ERC721Method returns the erc721 abi method by name
+func ERC721Method(name string) (*abi.Method, error) {
+	return abiMethod(erc721ABI, "erc721", name)
+}
+
+// PackERC721 packs the call data of the erc721 method
+func PackERC721(name string, args ...interface{}) ([]byte, error) {
+	return packABI(erc721ABI, name, args...)
+}
+
+// ERC1155Method returns the erc1155 abi method by name
+func ERC1155Method(name string) (*abi.Method, error) {
+	return abiMethod(erc1155ABI, "erc1155", name)
+}
+
+// PackERC1155 packs the call data of the erc1155 method
+func PackERC1155(name string, args ...interface{}) ([]byte, error) {
+	return packABI(erc1155ABI, name, args...)
+}
+
+func abiMethod(contractABI abi.ABI, abiName string, name string) (*abi.Method, error) {
+	method, ok := contractABI.Methods[name]
+	if !ok {
+		return nil, fmt.Errorf("method %s not found in %s abi", name, abiName)
+	}
+	return &method, nil
+}
+
+func packABI(contractABI abi.ABI, name string, args ...interface{}) ([]byte, error) {
+	data, err := contractABI.Pack(name, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack %s call: %v", name, err)
+	}
+	return data, nil
+}
+
+// BlockNumberToHex returns the hex encoded block number used as the block parameter in eth rpc calls
+func BlockNumberToHex(blockNumber uint64) string {
+	return fmt.Sprintf("0x%x", blockNumber)
+}
+
+func isNullResult(result json.RawMessage) bool {
+	return len(result) == 0 || string(result) == "null"
+}
+
 func transHexToUint64(hex json.RawMessage) (uint64, error) {
 	var result string
 	err := json.Unmarshal(hex, &result)
@@ -0,0 +1,135 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsMessage is either the response to the subscribe request, or a subscription notification
+type wsMessage struct {
+	ID     *uint64         `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+	Method string          `json:"method"`
+	Params struct {
+		Subscription string          `json:"subscription"`
+		Result       json.RawMessage `json:"result"`
+	} `json:"params"`
+}
+
+// WsClient is the websocket client for the node subscriptions
+type WsClient struct {
+	wsUrl string
+	// Keepalive of the subscription connections
+	pingInterval time.Duration
+	pongWait     time.Duration
+	writeWait    time.Duration
+}
+
+func NewWsClient(wsUrl string) *WsClient {
+	return &WsClient{
+		wsUrl:        wsUrl,
+		pingInterval: time.Duration(DefaultWsPingIntervalMS) * time.Millisecond,
+		pongWait:     time.Duration(DefaultWsPongWaitMS) * time.Millisecond,
+		writeWait:    time.Duration(DefaultWsWriteWaitMS) * time.Millisecond,
+	}
+}
+
+// Subscribe dials the node and subscribes with the namespace subscribe method, e.g. eth_subscribe, sending the
+// notification results to the result channel. onSubscribe, if set, is called once the node acknowledges the
+// subscription. It blocks until the connection drops or the context is cancelled, so callers are expected to
+// resubscribe on error. The connection is pinged on every ping interval, and every read fails once neither a pong
+// nor a message is received within the pong wait, so a half-open connection also returns an error
+func (c *WsClient) Subscribe(ctx context.Context, method string, resultChan chan<- json.RawMessage, onSubscribe func(), params ...interface{}) error {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, c.wsUrl, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Each pong extends the read deadline, so an idle but healthy subscription stays open
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(c.pongWait))
+	})
+
+	// Ping the node, and unblock the reads on context cancellation or when the ping cannot be sent
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(c.pingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.writeWait)); err != nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	request := rpcRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  method,
+		Params:  params,
+	}
+	if err := conn.SetWriteDeadline(time.Now().Add(c.writeWait)); err != nil {
+		return err
+	}
+	if err := conn.WriteJSON(request); err != nil {
+		return err
+	}
+	var response wsMessage
+	if err := c.readMessage(conn, &response); err != nil {
+		return err
+	}
+	if response.Error != nil {
+		return fmt.Errorf("%d - %s", response.Error.Code, response.Error.Message)
+	}
+	var subscriptionID string
+	if err := json.Unmarshal(response.Result, &subscriptionID); err != nil {
+		return err
+	}
+	if onSubscribe != nil {
+		onSubscribe()
+	}
+
+	notificationMethod := strings.TrimSuffix(method, "_subscribe") + "_subscription"
+	for {
+		var message wsMessage
+		if err := c.readMessage(conn, &message); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if message.Method != notificationMethod || message.Params.Subscription != subscriptionID {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case resultChan <- message.Params.Result:
+		}
+	}
+}
+
+// readMessage reads the next message of the connection, failing if nothing is received within the pong wait
+func (c *WsClient) readMessage(conn *websocket.Conn, message *wsMessage) error {
+	if err := conn.SetReadDeadline(time.Now().Add(c.pongWait)); err != nil {
+		return err
+	}
+	return conn.ReadJSON(message)
+}
@@ -0,0 +1,118 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWsClientSubscribe(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		defer conn.Close()
+		var request rpcRequest
+		if err := conn.ReadJSON(&request); err != nil {
+			t.Errorf("read subscribe request: %v", err)
+			return
+		}
+		if request.Method != "eth_subscribe" || len(request.Params) != 1 || request.Params[0] != "newHeads" {
+			t.Errorf("got subscribe request %s %v, want eth_subscribe [newHeads]", request.Method, request.Params)
+		}
+		conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": request.ID, "result": "0xa"})
+		// Only the notifications of the subscription are forwarded
+		for _, subscription := range []string{"0xb", "0xa"} {
+			conn.WriteJSON(map[string]interface{}{
+				"jsonrpc": "2.0",
+				"method":  "eth_subscription",
+				"params":  map[string]interface{}{"subscription": subscription, "result": map[string]string{"number": "0x" + subscription[2:]}},
+			})
+		}
+	}))
+	defer server.Close()
+
+	client := NewWsClient("ws" + strings.TrimPrefix(server.URL, "http"))
+	resultChan := make(chan json.RawMessage, 2)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// The subscription returns once the server drops the connection
+	if err := client.Subscribe(ctx, "eth_subscribe", resultChan, nil, "newHeads"); err == nil {
+		t.Error("got nil error for the dropped connection")
+	}
+
+	if len(resultChan) != 1 {
+		t.Fatalf("got %d notifications, want only the notification of the subscription", len(resultChan))
+	}
+	var header Header
+	if err := json.Unmarshal(<-resultChan, &header); err != nil || header.Number != 10 {
+		t.Errorf("got header %+v and error %v, want the head at height 10", header, err)
+	}
+}
+
+func TestWsClientSubscribeKeepalive(t *testing.T) {
+	// The server answers the subscription, then either keeps reading, which answers the pings, or goes silent
+	newServer := func(silent bool) *httptest.Server {
+		upgrader := websocket.Upgrader{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				t.Errorf("upgrade: %v", err)
+				return
+			}
+			defer conn.Close()
+			var request rpcRequest
+			if err := conn.ReadJSON(&request); err != nil {
+				return
+			}
+			conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": request.ID, "result": "0xa"})
+			if silent {
+				<-r.Context().Done()
+				return
+			}
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	newClient := func(server *httptest.Server) *WsClient {
+		client := NewWsClient("ws" + strings.TrimPrefix(server.URL, "http"))
+		client.pingInterval = 20 * time.Millisecond
+		client.pongWait = 100 * time.Millisecond
+		return client
+	}
+
+	t.Run("idle connection answering pings", func(t *testing.T) {
+		client := newClient(newServer(false))
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		if err := client.Subscribe(ctx, "eth_subscribe", make(chan json.RawMessage), nil, "newHeads"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got error %v, want the subscription kept open until the context deadline", err)
+		}
+	})
+
+	t.Run("silent connection", func(t *testing.T) {
+		client := newClient(newServer(true))
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		start := time.Now()
+		err := client.Subscribe(ctx, "eth_subscribe", make(chan json.RawMessage), nil, "newHeads")
+		if err == nil || ctx.Err() != nil {
+			t.Fatalf("got error %v after %v, want the read deadline error", err, time.Since(start))
+		}
+	})
+}
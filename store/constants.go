@@ -0,0 +1,8 @@
+package store
+
+import "time"
+
+const (
+	// DefaultOpenTimeout is how long to wait for the database file lock held by another process
+	DefaultOpenTimeout = 5 * time.Second
+)
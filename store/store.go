@@ -0,0 +1,203 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var mismatchBucket = []byte("mismatches")
+
+// ErrReadOnly is returned when adding a mismatch to a store opened read only
+var ErrReadOnly = errors.New("mismatch store is opened read only")
+
+// Mismatch is a confirmed mismatch found by a comparator
+type Mismatch struct {
+	ID         uint64 `json:"id"`
+	Comparator string `json:"comparator"`
+	Address    string `json:"address,omitempty"`
+	Token      string `json:"token,omitempty"`
+	// Key identifies the compared item beyond the address and token, e.g. the storage slot or tx hash
+	Key      string `json:"key,omitempty"`
+	Height   uint64 `json:"height"`
+	Eth      string `json:"eth,omitempty"`
+	Realtime string `json:"realtime,omitempty"`
+	Diffs    string `json:"diffs,omitempty"`
+	// Consecutive comparison rounds that mismatched before the mismatch was confirmed
+	Attempts     int       `json:"attempts"`
+	RealtimeUrl  string    `json:"realtimeUrl"`
+	ReferenceUrl string    `json:"referenceUrl"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// Filter selects the mismatches to list. Zero valued fields match all mismatches
+type Filter struct {
+	Comparator string
+	Address    string
+	Token      string
+	FromHeight uint64
+	ToHeight   uint64
+	Since      time.Time
+	Limit      int
+}
+
+func (filter Filter) match(mismatch Mismatch) bool {
+	if filter.Comparator != "" && mismatch.Comparator != filter.Comparator {
+		return false
+	}
+	if filter.Address != "" && mismatch.Address != filter.Address {
+		return false
+	}
+	if filter.Token != "" && mismatch.Token != filter.Token {
+		return false
+	}
+	if filter.FromHeight != 0 && mismatch.Height < filter.FromHeight {
+		return false
+	}
+	if filter.ToHeight != 0 && mismatch.Height > filter.ToHeight {
+		return false
+	}
+	if !filter.Since.IsZero() && mismatch.Timestamp.Before(filter.Since) {
+		return false
+	}
+	return true
+}
+
+// MismatchStore persists the confirmed mismatches to an embedded bolt database file. The file is only opened for
+// the duration of each operation, so the mismatches can be queried while the comparator is running
+type MismatchStore struct {
+	mu       sync.Mutex
+	path     string
+	readOnly bool
+}
+
+func NewMismatchStore(path string) (*MismatchStore, error) {
+	store := &MismatchStore{
+		path: path,
+	}
+	// Create the database file and bucket upfront to fail fast on a bad path
+	err := store.update(func(bucket *bolt.Bucket) error {
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// OpenMismatchStore opens an existing mismatch store read only, failing if the database file does not exist
+func OpenMismatchStore(path string) (*MismatchStore, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	store := &MismatchStore{
+		path:     path,
+		readOnly: true,
+	}
+	// Open the database upfront to fail fast on a file that is not a bolt database
+	err := store.view(func(bucket *bolt.Bucket) error {
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Add stores the mismatch, assigning it the next mismatch id, and returns the id
+func (store *MismatchStore) Add(mismatch Mismatch) (uint64, error) {
+	err := store.update(func(bucket *bolt.Bucket) error {
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		mismatch.ID = id
+		value, err := json.Marshal(mismatch)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(idToKey(id), value)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return mismatch.ID, nil
+}
+
+// List returns the mismatches matching the filter, most recent first
+func (store *MismatchStore) List(filter Filter) ([]Mismatch, error) {
+	mismatches := []Mismatch{}
+	err := store.view(func(bucket *bolt.Bucket) error {
+		cursor := bucket.Cursor()
+		for key, value := cursor.Last(); key != nil; key, value = cursor.Prev() {
+			var mismatch Mismatch
+			if err := json.Unmarshal(value, &mismatch); err != nil {
+				return err
+			}
+			if !filter.match(mismatch) {
+				continue
+			}
+			mismatches = append(mismatches, mismatch)
+			if filter.Limit > 0 && len(mismatches) >= filter.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return mismatches, nil
+}
+
+func (store *MismatchStore) update(fn func(bucket *bolt.Bucket) error) error {
+	if store.readOnly {
+		return ErrReadOnly
+	}
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	db, err := bolt.Open(store.path, 0600, &bolt.Options{Timeout: DefaultOpenTimeout})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(mismatchBucket)
+		if err != nil {
+			return err
+		}
+		return fn(bucket)
+	})
+}
+
+func (store *MismatchStore) view(fn func(bucket *bolt.Bucket) error) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	db, err := bolt.Open(store.path, 0600, &bolt.Options{Timeout: DefaultOpenTimeout, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(mismatchBucket)
+		if bucket == nil {
+			return nil
+		}
+		return fn(bucket)
+	})
+}
+
+func idToKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
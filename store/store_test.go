@@ -0,0 +1,121 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFilterMatch(t *testing.T) {
+	now := time.Now().UTC()
+	mismatch := Mismatch{
+		Comparator: "token balance",
+		Address:    "0x01",
+		Token:      "0x02",
+		Height:     100,
+		Timestamp:  now,
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		match  bool
+	}{
+		{"empty filter", Filter{}, true},
+		{"comparator", Filter{Comparator: "token balance"}, true},
+		{"other comparator", Filter{Comparator: "balance"}, false},
+		{"address", Filter{Address: "0x01"}, true},
+		{"other address", Filter{Address: "0x03"}, false},
+		{"token", Filter{Token: "0x02"}, true},
+		{"other token", Filter{Token: "0x03"}, false},
+		{"height range", Filter{FromHeight: 100, ToHeight: 100}, true},
+		{"below from height", Filter{FromHeight: 101}, false},
+		{"above to height", Filter{ToHeight: 99}, false},
+		{"since", Filter{Since: now.Add(-time.Minute)}, true},
+		{"before since", Filter{Since: now.Add(time.Minute)}, false},
+		{"all fields", Filter{Comparator: "token balance", Address: "0x01", Token: "0x02", FromHeight: 1, ToHeight: 1000, Since: now}, true},
+	}
+	for _, test := range tests {
+		if match := test.filter.match(mismatch); match != test.match {
+			t.Errorf("%s: got match %v, want %v", test.name, match, test.match)
+		}
+	}
+}
+
+func TestMismatchStoreRoundTrip(t *testing.T) {
+	store, err := NewMismatchStore(filepath.Join(t.TempDir(), "mismatches.db"))
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+
+	timestamp := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	added := []Mismatch{
+		{Comparator: "balance", Address: "0x01", Height: 10, Eth: "1", Realtime: "2", Attempts: 3, RealtimeUrl: "http://realtime", ReferenceUrl: "http://eth", Timestamp: timestamp},
+		{Comparator: "block", Height: 11, Diffs: "hash", Attempts: 3, Timestamp: timestamp},
+		{Comparator: "balance", Address: "0x02", Height: 12, Eth: "3", Realtime: "4", Attempts: 3, Timestamp: timestamp},
+	}
+	for i := range added {
+		id, err := store.Add(added[i])
+		if err != nil {
+			t.Fatalf("add mismatch %d: %v", i, err)
+		}
+		if id != uint64(i+1) {
+			t.Errorf("got id %d for mismatch %d, want %d", id, i, i+1)
+		}
+		added[i].ID = id
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []Mismatch
+	}{
+		{"all, most recent first", Filter{}, []Mismatch{added[2], added[1], added[0]}},
+		{"comparator", Filter{Comparator: "balance"}, []Mismatch{added[2], added[0]}},
+		{"limit", Filter{Limit: 1}, []Mismatch{added[2]}},
+		{"height range", Filter{FromHeight: 11, ToHeight: 11}, []Mismatch{added[1]}},
+		{"no match", Filter{Address: "0x03"}, []Mismatch{}},
+	}
+	for _, test := range tests {
+		mismatches, err := store.List(test.filter)
+		if err != nil {
+			t.Fatalf("%s: list: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(mismatches, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, mismatches, test.want)
+		}
+	}
+}
+
+func TestOpenMismatchStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mismatches.db")
+	if _, err := OpenMismatchStore(path); err == nil {
+		t.Fatal("opened a mismatch store that does not exist")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("opening a missing store created the file: %v", err)
+	}
+
+	writable, err := NewMismatchStore(path)
+	if err != nil {
+		t.Fatalf("create store: %v", err)
+	}
+	if _, err := writable.Add(Mismatch{Comparator: "nonce", Height: 5}); err != nil {
+		t.Fatalf("add mismatch: %v", err)
+	}
+
+	readOnly, err := OpenMismatchStore(path)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	mismatches, err := readOnly.List(Filter{})
+	if err != nil || len(mismatches) != 1 || mismatches[0].Comparator != "nonce" {
+		t.Errorf("got mismatches %+v with error %v, want the nonce mismatch", mismatches, err)
+	}
+	if _, err := readOnly.Add(Mismatch{Comparator: "nonce"}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("got error %v adding to the read only store, want %v", err, ErrReadOnly)
+	}
+}